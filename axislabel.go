@@ -0,0 +1,164 @@
+package packetdiagram
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// AxisLabelFormat is how the numbers labelling an axis are written.
+type AxisLabelFormat string
+
+const (
+	AxisLabelFormatDecimal AxisLabelFormat = "decimal"  // 12
+	AxisLabelFormatHex     AxisLabelFormat = "hex"      // 0x0C
+	AxisLabelFormatOctal   AxisLabelFormat = "octal"    // 014
+	AxisLabelFormatByteBit AxisLabelFormat = "byte.bit" // 1.4, the byte and the bit within it
+)
+
+func (AxisLabelFormat) schemaEnum() []string {
+	return []string{
+		string(AxisLabelFormatDecimal),
+		string(AxisLabelFormatHex),
+		string(AxisLabelFormatOctal),
+		string(AxisLabelFormatByteBit),
+	}
+}
+
+const defaultAxisLabelFormat = AxisLabelFormatDecimal
+
+// wordBitsChoices are the sizes of the words octet axes may count instead.
+var wordBitsChoices = []uint{16, 32, 64}
+
+// formatAxisLabel writes value, counted in units of unitBits bits, in the
+// format. byte.bit gives where the value starts in bytes and bits.
+func formatAxisLabel(value, unitBits uint, format AxisLabelFormat) string {
+	switch format {
+	case AxisLabelFormatHex:
+		return fmt.Sprintf("0x%02X", value)
+	case AxisLabelFormatOctal:
+		return fmt.Sprintf("%#o", value)
+	case AxisLabelFormatByteBit:
+		bits := value * unitBits
+		return fmt.Sprintf("%d.%d", bits/8, bits%8)
+	default:
+		return fmt.Sprintf("%d", value)
+	}
+}
+
+func getAxisLabelFormat(format *AxisLabelFormat) AxisLabelFormat {
+	if format == nil {
+		return defaultAxisLabelFormat
+	}
+	return *format
+}
+
+func (d *Definition) GetXAxisBitsFormat() AxisLabelFormat {
+	if d.XAxis.Bits == nil {
+		return defaultAxisLabelFormat
+	}
+	return getAxisLabelFormat(d.XAxis.Bits.Format)
+}
+
+func (d *Definition) GetXAxisOctetsFormat() AxisLabelFormat {
+	if d.XAxis.Octets == nil {
+		return defaultAxisLabelFormat
+	}
+	return getAxisLabelFormat(d.XAxis.Octets.Format)
+}
+
+func (d *Definition) GetYAxisBitsFormat() AxisLabelFormat {
+	if d.YAxis.Bits == nil {
+		return defaultAxisLabelFormat
+	}
+	return getAxisLabelFormat(d.YAxis.Bits.Format)
+}
+
+func (d *Definition) GetYAxisOctetsFormat() AxisLabelFormat {
+	if d.YAxis.Octets == nil {
+		return defaultAxisLabelFormat
+	}
+	return getAxisLabelFormat(d.YAxis.Octets.Format)
+}
+
+// GetXAxisOctetsUnitBits returns the bits each mark of the x-axis octets
+// counts: an octet, or a word in word mode.
+func (d *Definition) GetXAxisOctetsUnitBits() uint {
+	if d.XAxis.Octets == nil || d.XAxis.Octets.WordBits == nil {
+		return 8
+	}
+	return *d.XAxis.Octets.WordBits
+}
+
+// GetYAxisOctetsUnitBits returns the bits each label of the y-axis octets
+// counts: an octet, or a word in word mode.
+func (d *Definition) GetYAxisOctetsUnitBits() uint {
+	if d.YAxis.Octets == nil || d.YAxis.Octets.WordBits == nil {
+		return 8
+	}
+	return *d.YAxis.Octets.WordBits
+}
+
+func getOctetsTitle(unitBits uint) string {
+	if unitBits == 8 {
+		return "octet"
+	}
+	return "word"
+}
+
+// formatYAxisOctetLabel labels the row starting octets into the diagram,
+// counting from the origin in the units of the axis.
+func (d *Definition) formatYAxisOctetLabel(octets uint) string {
+	unit := d.GetYAxisOctetsUnitBits()
+	return formatAxisLabel(d.GetYAxisOctetsOrigin()+octets*8/unit, unit, d.GetYAxisOctetsFormat())
+}
+
+func (d *Definition) validateAxes() error {
+	type axis struct {
+		name     string
+		format   *AxisLabelFormat
+		wordBits *uint
+	}
+	axes := make([]axis, 0, 4)
+	if d.XAxis.Bits != nil {
+		axes = append(axes, axis{"x-axis bits", d.XAxis.Bits.Format, nil})
+	}
+	if d.XAxis.Octets != nil {
+		axes = append(axes, axis{"x-axis octets", d.XAxis.Octets.Format, d.XAxis.Octets.WordBits})
+	}
+	if d.YAxis.Bits != nil {
+		axes = append(axes, axis{"y-axis bits", d.YAxis.Bits.Format, nil})
+	}
+	if d.YAxis.Octets != nil {
+		axes = append(axes, axis{"y-axis octets", d.YAxis.Octets.Format, d.YAxis.Octets.WordBits})
+	}
+
+	for _, a := range axes {
+		if a.format != nil {
+			switch *a.format {
+			case AxisLabelFormatDecimal, AxisLabelFormatHex, AxisLabelFormatOctal, AxisLabelFormatByteBit:
+			default:
+				return errors.Errorf("%s: unsupported label format: %s", a.name, *a.format)
+			}
+		}
+		if a.wordBits == nil {
+			continue
+		}
+		if !isValidWordBits(*a.wordBits) {
+			return errors.Errorf("%s: words must be 16, 32 or 64 bits, not %d", a.name, *a.wordBits)
+		}
+		if d.GetBitsPerLine()%*a.wordBits != 0 {
+			return errors.Errorf("%s: a line of %d bits is not a whole number of %d-bit words", a.name, d.GetBitsPerLine(), *a.wordBits)
+		}
+	}
+	return nil
+}
+
+func isValidWordBits(bits uint) bool {
+	for _, b := range wordBitsChoices {
+		if bits == b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package packetdiagram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestFormatAxisLabel(t *testing.T) {
+	testData := []struct {
+		Value    uint
+		UnitBits uint
+		Format   AxisLabelFormat
+		Expected string
+	}{
+		{12, 1, AxisLabelFormatDecimal, "12"},
+		{12, 1, AxisLabelFormatHex, "0x0C"},
+		{300, 1, AxisLabelFormatHex, "0x12C"},
+		{12, 1, AxisLabelFormatOctal, "014"},
+		{0, 1, AxisLabelFormatOctal, "0"},
+		{12, 1, AxisLabelFormatByteBit, "1.4"},
+		{3, 8, AxisLabelFormatByteBit, "3.0"},
+		{3, 32, AxisLabelFormatByteBit, "12.0"},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Expected, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, data.Expected, formatAxisLabel(data.Value, data.UnitBits, data.Format))
+		})
+	}
+}
+
+func TestAxisLabelFormats(t *testing.T) {
+	t.Parallel()
+	hex, byteBit := AxisLabelFormatHex, AxisLabelFormatByteBit
+	def := &Definition{
+		OctetsPerLine: uintp(4),
+		XAxis: XAxisSpec{
+			Bits:   &XAxisBitsSpec{Format: &byteBit},
+			Octets: &XAxisOctetsSpec{Format: &hex, WordBits: uintp(16)},
+		},
+		YAxis: YAxisSpec{
+			Bits:   &YAxisBitsSpec{Format: &hex},
+			Octets: &YAxisOctetsSpec{Format: &hex, WordBits: uintp(32), Origin: uintp(4)},
+		},
+		Placements: []Placement{
+			{Label: "a", Bits: uintp(32)},
+			{Label: "b", Bits: uintp(32)},
+		},
+	}
+	dim := Dimensions{Cell: Dimension{Width: 10, Height: 10}}
+
+	labels := getXAxisBitLabels(def)
+	assert.Equal(t, []string{"0.0", "0.1"}, labels[:2])
+	assert.Equal(t, "3.7", labels[31])
+
+	xs, _, labels := calculateXAxisOctetLabelDimensions(def, dim)
+	assert.Equal(t, []int{0, 160}, xs)
+	assert.Equal(t, []string{"0x00", "0x01"}, labels)
+
+	_, _, labels = calculateYAxisBitLabelDimensions(def, dim)
+	assert.Equal(t, []string{"0x00", "0x20"}, labels)
+
+	_, _, labels = calculateYAxisOctetLabelDimensions(def, dim)
+	assert.Equal(t, []string{"0x04", "0x05"}, labels)
+}
+
+func TestGetXAxisBitLabelsRightToLeft(t *testing.T) {
+	rtl := XAxisBitsDirectionRightToLeft
+	unit8, unit16 := XAxisBitsUnit(8), XAxisBitsUnit(16)
+	testData := []struct {
+		Name     string
+		Bits     *XAxisBitsSpec
+		Expected []string
+	}{
+		{"bytes", &XAxisBitsSpec{Direction: &rtl, Unit: &unit8}, []string{"7", "6", "5", "4", "3", "2", "1", "0", "7", "6", "5", "4", "3", "2", "1", "0"}},
+		{"words", &XAxisBitsSpec{Direction: &rtl, Unit: &unit16}, []string{"15", "14", "13", "12", "11", "10", "9", "8", "7", "6", "5", "4", "3", "2", "1", "0"}},
+		{"origin", &XAxisBitsSpec{Direction: &rtl, Unit: &unit8, Origin: uintp(1)}, []string{"8", "7", "6", "5", "4", "3", "2", "1", "8", "7", "6", "5", "4", "3", "2", "1"}},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+			def := &Definition{OctetsPerLine: uintp(2), XAxis: XAxisSpec{Bits: data.Bits}}
+			assert.Equal(t, data.Expected, getXAxisBitLabels(def))
+		})
+	}
+}
+
+func TestValidateAxes(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "unknown format",
+			Source: "y-axis:\n  bits:\n    format: roman\nplacements:\n  - label: a\n    bits: 8\n",
+			Error:  "y-axis bits: unsupported label format: roman",
+		},
+		{
+			Name:   "unknown word size",
+			Source: "y-axis:\n  octets:\n    word-bits: 24\nplacements:\n  - label: a\n    bits: 8\n",
+			Error:  "y-axis octets: words must be 16, 32 or 64 bits, not 24",
+		},
+		{
+			Name:   "words across lines",
+			Source: "x-axis:\n  octets:\n    word-bits: 64\nplacements:\n  - label: a\n    bits: 8\n",
+			Error:  "x-axis octets: a line of 32 bits is not a whole number of 64-bit words",
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadDefinition(strings.NewReader(data.Source))
+			assert.EqualError(t, err, data.Error)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package packetdiagram
+
+// bitWriter packs fields one after another with no padding between them, as
+// placements lay them out. The last byte of buf is padded with zeros.
+type bitWriter struct {
+	buf  []byte
+	bits uint
+}
+
+// write appends the low n bits of the big-endian value MSB-first, filling
+// bytes from their most significant bit.
+func (w *bitWriter) write(value []byte, n uint) {
+	for i := uint(0); i < n; i++ {
+		// the bit of value that goes out i-th, counted from the least
+		// significant one
+		w.writeBit(bitOf(value, n-1-i), 7-w.bits%8)
+	}
+}
+
+// writeLSBFirst appends the low n bits of the big-endian value LSB-first,
+// filling bytes from their least significant bit.
+func (w *bitWriter) writeLSBFirst(value []byte, n uint) {
+	for i := uint(0); i < n; i++ {
+		w.writeBit(bitOf(value, i), w.bits%8)
+	}
+}
+
+// writeField appends the low n bits of the big-endian value of a field laid
+// out in order.
+func (w *bitWriter) writeField(value []byte, n uint, order fieldOrder) {
+	if order.swapsBytes() {
+		value = reverseBytes(value)
+	}
+	if order.bits == BitOrderLSBFirst {
+		w.writeLSBFirst(value, n)
+		return
+	}
+	w.write(value, n)
+}
+
+// writeBit sets the bit at the given position of the current byte, counted
+// from its least significant bit, to bit.
+func (w *bitWriter) writeBit(bit uint, at uint) {
+	if w.bits%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	w.buf[len(w.buf)-1] |= byte(bit << at)
+	w.bits++
+}
+
+// bitOf returns the bit of the big-endian value at i, counted from the least
+// significant one.
+func bitOf(value []byte, i uint) uint {
+	idx := len(value) - 1 - int(i/8)
+	if idx < 0 {
+		return 0
+	}
+	return uint(value[idx]>>(i%8)) & 1
+}
+
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, c := range b {
+		r[len(b)-1-i] = c
+	}
+	return r
+}
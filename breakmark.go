@@ -0,0 +1,219 @@
+package packetdiagram
+
+import (
+	"github.com/pkg/errors"
+)
+
+// BreakMarkStyle is how the edges of a variable-length placement are broken.
+type BreakMarkStyle string
+
+const (
+	BreakMarkStyleSCurve       BreakMarkStyle = "s-curve"       // two Bézier "S" curves across the edge
+	BreakMarkStyleZigZag       BreakMarkStyle = "zig-zag"       // two zig-zag lines across the edge
+	BreakMarkStyleWave         BreakMarkStyle = "wave"          // two wavy lines across the edge
+	BreakMarkStyleDashedGap    BreakMarkStyle = "dashed-gap"    // the edge dashed over the row
+	BreakMarkStyleDiagonalTear BreakMarkStyle = "diagonal-tear" // a slanted cut through the edge
+	BreakMarkStyleEllipsis     BreakMarkStyle = "ellipsis"      // the edge replaced by a vertical ellipsis
+)
+
+func (BreakMarkStyle) schemaEnum() []string {
+	return []string{
+		string(BreakMarkStyleSCurve),
+		string(BreakMarkStyleZigZag),
+		string(BreakMarkStyleWave),
+		string(BreakMarkStyleDashedGap),
+		string(BreakMarkStyleDiagonalTear),
+		string(BreakMarkStyleEllipsis),
+	}
+}
+
+const defaultBreakMarkStyle = BreakMarkStyleSCurve
+
+func (d *Definition) GetBreakMarkStyle() BreakMarkStyle {
+	if d.BreakMark.Style == nil {
+		return defaultBreakMarkStyle
+	}
+	return *d.BreakMark.Style
+}
+
+// GetBreakMarkRow returns the row of a variable-length placement its break
+// marks go on, if one is given.
+func (d *Definition) GetBreakMarkRow() (uint, bool) {
+	if d.BreakMark.Row == nil {
+		return 0, false
+	}
+	return *d.BreakMark.Row, true
+}
+
+func (d *Definition) GetBreakMarkStroke() string {
+	return d.GetTheme().GetBreakMarkStroke()
+}
+
+func (d *Definition) GetBreakMarkGap() uint {
+	return d.GetTheme().GetBreakMarkGap()
+}
+
+func (d *Definition) validateBreakMark() error {
+	if s := d.BreakMark.Style; s != nil {
+		switch *s {
+		case BreakMarkStyleSCurve, BreakMarkStyleZigZag, BreakMarkStyleWave,
+			BreakMarkStyleDashedGap, BreakMarkStyleDiagonalTear, BreakMarkStyleEllipsis:
+		default:
+			return errors.Errorf("break-mark: unsupported style: %s", *s)
+		}
+	}
+	return nil
+}
+
+// drawBreakMarks marks the edges of a variable-length placement: on the row
+// the break mark spec asks for, else on the row standing for the bits it
+// leaves out, else halfway down each of its polygons.
+func drawBreakMarks(def *Definition, dim Dimensions, l placementLayout, polygons []Polygon, canvas surface) {
+	i := -1
+	if row, ok := def.GetBreakMarkRow(); ok {
+		i = int(row)
+		if i >= len(l.segments) {
+			i = len(l.segments) - 1
+		}
+	} else {
+		for j, s := range l.segments {
+			if int(s.row) == l.elided {
+				i = j
+			}
+		}
+	}
+
+	if i >= 0 {
+		s := l.segments[i]
+		left := dim.YAxis.Width + s.x*dim.Cell.Width
+		y := dim.XAxis.Height + s.row*dim.Cell.Height + dim.Cell.Height/2
+		drawBreakMark(def, dim, left, left+s.bits*dim.Cell.Width, y, canvas)
+		return
+	}
+	for _, polygon := range polygons {
+		left, top, right, bottom := polygon.findBoundingBox()
+		drawBreakMark(def, dim, left, right, (top+bottom)/2, canvas)
+	}
+}
+
+// drawBreakMark breaks the left and right edges of a placement around y.
+func drawBreakMark(def *Definition, dim Dimensions, left, right, y uint, canvas surface) {
+	for _, x := range []uint{left, right} {
+		switch def.GetBreakMarkStyle() {
+		case BreakMarkStyleZigZag:
+			drawZigZagBreakMark(def, x, y, canvas)
+		case BreakMarkStyleWave:
+			drawWaveBreakMark(def, x, y, canvas)
+		case BreakMarkStyleDashedGap:
+			drawDashedGapBreakMark(def, dim, x, y, canvas)
+		case BreakMarkStyleDiagonalTear:
+			drawDiagonalTearBreakMark(def, x, y, canvas)
+		case BreakMarkStyleEllipsis:
+			drawEllipsisBreakMark(def, dim, x, y, canvas)
+		default:
+			drawSCurveBreakMark(def, x, y, canvas)
+		}
+	}
+}
+
+// getBreakMarkLines returns where the two lines of a break mark around y
+// cross the edge, the gap of the theme apart.
+func getBreakMarkLines(def *Definition, y uint) []uint {
+	g := def.GetBreakMarkGap() / 2
+	return []uint{y - g, y + g}
+}
+
+func drawSCurveBreakMark(def *Definition, x, y uint, canvas surface) {
+	for _, ly := range getBreakMarkLines(def, y) {
+		sx, sy, cx, cy, px, py, ex, ey := getBreakMarkPoints(def, x, ly)
+		canvas.Bezier(sx, sy, cx, cy, px, py, ex, ey, `class="breakmark"`)
+	}
+}
+
+func getBreakMarkPoints(def *Definition, x, y uint) (sx, sy, cx, cy, px, py, ex, ey int) {
+	w := def.GetBreakMarkWidth() / 2
+	sx = int(x - w)
+	cx = int(x)
+	px = int(x)
+	ex = int(x + w)
+
+	h := def.GetBreakMarkHeight() / 2
+	sy = int(y)
+	cy = int(y - h)
+	py = int(y + h)
+	ey = int(y)
+	return
+}
+
+func drawZigZagBreakMark(def *Definition, x, y uint, canvas surface) {
+	w := int(def.GetBreakMarkWidth() / 2)
+	h := int(def.GetBreakMarkHeight() / 4)
+	xs := []int{int(x) - w, int(x) - w/2, int(x), int(x) + w/2, int(x) + w}
+	for _, ly := range getBreakMarkLines(def, y) {
+		ys := []int{int(ly) + h, int(ly) - h, int(ly) + h, int(ly) - h, int(ly) + h}
+		for i := 1; i < len(xs); i++ {
+			canvas.Line(xs[i-1], ys[i-1], xs[i], ys[i], `class="breakmark"`)
+		}
+	}
+}
+
+func drawWaveBreakMark(def *Definition, x, y uint, canvas surface) {
+	w := int(def.GetBreakMarkWidth() / 2)
+	h := int(def.GetBreakMarkHeight() / 2)
+	for _, ly := range getBreakMarkLines(def, y) {
+		for _, sx := range []int{int(x) - w, int(x)} {
+			cx := sx + w/2
+			canvas.Bezier(sx, int(ly), cx, int(ly)-h, cx, int(ly)+h, sx+w, int(ly), `class="breakmark"`)
+		}
+	}
+}
+
+// drawDashedGapBreakMark rubs the edge out over the row and dashes it,
+// with dashes as long as the gaps between them.
+func drawDashedGapBreakMark(def *Definition, dim Dimensions, x, y uint, canvas surface) {
+	top := int(y - dim.Cell.Height/2 + 1)
+	bottom := int(y + dim.Cell.Height/2 - 1)
+	canvas.Rect(int(x)-1, top, 3, bottom-top, `class="breakmark-gap"`)
+
+	g := int(def.GetBreakMarkGap())
+	if g == 0 {
+		g = 1
+	}
+	for dy := top; dy < bottom; dy += 2 * g {
+		end := dy + g
+		if end > bottom {
+			end = bottom
+		}
+		canvas.Line(int(x), dy, int(x), end, `class="breakmark"`)
+	}
+}
+
+// drawDiagonalTearBreakMark cuts the edge with a band slanting up to the
+// right, the gap of the theme high.
+func drawDiagonalTearBreakMark(def *Definition, x, y uint, canvas surface) {
+	w := int(def.GetBreakMarkWidth() / 2)
+	h := int(def.GetBreakMarkHeight() / 4)
+	lines := getBreakMarkLines(def, y)
+	upper, lower := int(lines[0]), int(lines[1])
+	left, right := int(x)-w, int(x)+w
+
+	canvas.Polygon(
+		[]int{left, right, right, left},
+		[]int{upper + h, upper - h, lower - h, lower + h},
+		`class="breakmark-gap"`,
+	)
+	canvas.Line(left, upper+h, right, upper-h, `class="breakmark"`)
+	canvas.Line(left, lower+h, right, lower-h, `class="breakmark"`)
+}
+
+// drawEllipsisBreakMark rubs the edge out over the row and puts three dots,
+// the gap of the theme apart, in its place.
+func drawEllipsisBreakMark(def *Definition, dim Dimensions, x, y uint, canvas surface) {
+	top := int(y - dim.Cell.Height/2 + 1)
+	canvas.Rect(int(x)-1, top, 3, int(dim.Cell.Height)-2, `class="breakmark-gap"`)
+
+	g := int(def.GetBreakMarkGap())
+	for _, dy := range []int{-g, 0, g} {
+		canvas.Rect(int(x)-1, int(y)+dy-1, 3, 3, `class="breakmark-dot"`)
+	}
+}
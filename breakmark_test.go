@@ -0,0 +1,105 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestDrawBreakMarkStyles(t *testing.T) {
+	testData := []struct {
+		Style    BreakMarkStyle
+		Expected []string
+	}{
+		{BreakMarkStyleSCurve, []string{`<path d="M-5,42 C0,37 0,47 5,42" class="breakmark"`}},
+		{BreakMarkStyleZigZag, []string{`<line x1="-5" y1="44" x2="-2" y2="40" class="breakmark"`}},
+		{BreakMarkStyleWave, []string{`<path d="M-5,42 C-3,37 -3,47 0,42" class="breakmark"`}},
+		{BreakMarkStyleDashedGap, []string{
+			`<rect x="-1" y="31" width="3" height="28" class="breakmark-gap"`,
+			`<line x1="0" y1="31" x2="0" y2="37" class="breakmark"`,
+		}},
+		{BreakMarkStyleDiagonalTear, []string{
+			`<polygon points="-5,44 5,40 5,46 -5,50" class="breakmark-gap"`,
+			`<line x1="-5" y1="44" x2="5" y2="40" class="breakmark"`,
+		}},
+		{BreakMarkStyleEllipsis, []string{
+			`<rect x="-1" y="31" width="3" height="28" class="breakmark-gap"`,
+			`<rect x="-1" y="44" width="3" height="3" class="breakmark-dot"`,
+		}},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(string(data.Style), func(t *testing.T) {
+			t.Parallel()
+			def := &Definition{
+				BreakMark: BreakMarkSpec{Style: &data.Style},
+				Placements: []Placement{
+					{Label: "a", Bits: uintp(8)},
+					{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320}},
+					{Label: "b", Bits: uintp(16)},
+				},
+			}
+
+			var buf bytes.Buffer
+			err := Draw(def, &buf)
+			assert.NoError(t, err)
+			for _, e := range data.Expected {
+				assert.Contains(t, buf.String(), e)
+			}
+		})
+	}
+}
+
+func TestDrawBreakMarkRow(t *testing.T) {
+	t.Parallel()
+	placements := []Placement{
+		{Label: "Kind", Bits: uintp(24)},
+		{Label: "Short", VariableLength: &VariableLengthPlacementSpec{MaxBits: 16}},
+		{Label: "After", Bits: uintp(24)},
+	}
+
+	// halfway down each of its polygons
+	var buf bytes.Buffer
+	err := Draw(&Definition{Placements: placements}, &buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `<path d="M715,12 C720,7 720,17 725,12" class="breakmark"`)
+	assert.Contains(t, buf.String(), `<path d="M-5,42 C0,37 0,47 5,42" class="breakmark"`)
+
+	// on its second row only
+	row := uint(1)
+	buf.Reset()
+	err = Draw(&Definition{BreakMark: BreakMarkSpec{Row: &row}, Placements: placements}, &buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `<path d="M-5,42 C0,37 0,47 5,42" class="breakmark"`)
+	assert.Contains(t, buf.String(), `<path d="M235,42 C240,37 240,47 245,42" class="breakmark"`)
+	assert.NotContains(t, buf.String(), `<path d="M715,12`)
+}
+
+func TestBreakMarkTheme(t *testing.T) {
+	t.Parallel()
+	stroke, gap := "red", uint(10)
+	def := &Definition{
+		Theme: &ThemeSpec{BreakMark: &BreakMarkTheme{Stroke: &stroke, Gap: &gap}},
+		Placements: []Placement{
+			{Label: "a", Bits: uintp(8)},
+			{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Draw(def, &buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `path.breakmark{fill:none;stroke:red;}`)
+	assert.Contains(t, buf.String(), `<path d="M-5,40 C0,35 0,45 5,40" class="breakmark"`)
+	assert.Contains(t, buf.String(), `<path d="M-5,50 C0,45 0,55 5,50" class="breakmark"`)
+}
+
+func TestValidateBreakMark(t *testing.T) {
+	t.Parallel()
+	source := "break-mark:\n  style: torn\nplacements:\n  - label: a\n    bits: 8\n"
+	_, err := LoadDefinition(strings.NewReader(source))
+	assert.EqualError(t, err, "break-mark: unsupported style: torn")
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+	"github.com/pkg/errors"
+)
+
+type batchJob struct {
+	Input  string
+	Output string
+}
+
+type batchStatus int
+
+const (
+	batchStatusRendered batchStatus = iota
+	batchStatusSkipped
+	batchStatusFailed
+)
+
+type batchResult struct {
+	Job     batchJob
+	Outputs []string
+	Status  batchStatus
+	Err     error
+}
+
+// isBatch tells whether the inputs name anything else than a single
+// definition file, in which case the diagrams are written to files rather
+// than to stdout.
+func isBatch(inputs []string) bool {
+	if len(inputs) != 1 || opts.OutputDir != "" {
+		return true
+	}
+
+	fi, err := os.Stat(inputs[0])
+	if err != nil {
+		return hasGlobMeta(inputs[0])
+	}
+	return fi.IsDir()
+}
+
+func renderBatch(inputs []string, format packetdiagram.Format) error {
+	jobs, err := collectBatchJobs(inputs, opts.OutputDir, format)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return errors.New("no definition files found")
+	}
+
+	parallelism := opts.Jobs
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	results := runBatchJobs(jobs, format, parallelism, opts.Force)
+
+	rendered, skipped, failed := 0, 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case batchStatusRendered:
+			rendered++
+			log.Printf("rendered %s -> %s", r.Job.Input, strings.Join(r.Outputs, ", "))
+		case batchStatusSkipped:
+			skipped++
+		case batchStatusFailed:
+			failed++
+			log.Printf("failed %s: %v", r.Job.Input, r.Err)
+		}
+	}
+	log.Printf("%d rendered, %d up to date, %d failed", rendered, skipped, failed)
+
+	if failed > 0 {
+		return errors.Errorf("failed to render %d of %d definitions", failed, len(results))
+	}
+	return nil
+}
+
+// collectBatchJobs expands the inputs into definition files and decides
+// where each diagram goes. Files found under a directory or a glob keep
+// their path relative to it inside outputDir, so the output mirrors the
+// source tree; without outputDir the diagram is written next to its source.
+func collectBatchJobs(inputs []string, outputDir string, format packetdiagram.Format) ([]batchJob, error) {
+	jobs := make([]batchJob, 0)
+	seen := map[string]struct{}{}
+
+	add := func(root, path string) error {
+		if _, ok := seen[path]; ok {
+			return nil
+		}
+		seen[path] = struct{}{}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		out := trimDefinitionExt(path) + "." + string(format)
+		if outputDir != "" {
+			out = filepath.Join(outputDir, trimDefinitionExt(rel)+"."+string(format))
+		}
+		jobs = append(jobs, batchJob{Input: path, Output: out})
+		return nil
+	}
+
+	for _, input := range inputs {
+		fi, err := os.Stat(input)
+		switch {
+		case err == nil && fi.IsDir():
+			err = filepath.Walk(input, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() || !isDefinitionFile(path) {
+					return nil
+				}
+				return add(input, path)
+			})
+			if err != nil {
+				return nil, err
+			}
+		case err == nil:
+			err = add(filepath.Dir(input), input)
+			if err != nil {
+				return nil, err
+			}
+		case hasGlobMeta(input):
+			matches, err := filepath.Glob(input)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern %s", input)
+			}
+			root := globRoot(input)
+			for _, m := range matches {
+				if fi, err := os.Stat(m); err != nil || fi.IsDir() || !isDefinitionFile(m) {
+					continue
+				}
+				err = add(root, m)
+				if err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, err
+		}
+	}
+
+	return jobs, nil
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// globRoot returns the leading directories of pattern that contain no
+// wildcards.
+func globRoot(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for hasGlobMeta(dir) {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+func runBatchJobs(jobs []batchJob, format packetdiagram.Format, parallelism int, force bool) []batchResult {
+	results := make([]batchResult, len(jobs))
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = runBatchJob(jobs[i], format, force)
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Job.Input < results[j].Job.Input
+	})
+	return results
+}
+
+func runBatchJob(job batchJob, format packetdiagram.Format, force bool) (result batchResult) {
+	// a definition the renderer chokes on must not take the others down
+	defer func() {
+		if r := recover(); r != nil {
+			result = batchResult{Job: job, Status: batchStatusFailed, Err: errors.Errorf("panic: %v", r)}
+		}
+	}()
+
+	defs, sources, err := loadDefinitionSources(job.Input)
+	if err != nil {
+		return batchResult{Job: job, Status: batchStatusFailed, Err: err}
+	}
+
+	outputs := getDiagramOutputs(job, defs)
+	if !force && isUpToDate(sources, outputs) {
+		return batchResult{Job: job, Status: batchStatusSkipped}
+	}
+
+	for i, def := range defs {
+		err = renderToFile(def, format, outputs[i])
+		if err != nil {
+			return batchResult{Job: job, Status: batchStatusFailed, Err: err}
+		}
+	}
+	return batchResult{Job: job, Outputs: outputs, Status: batchStatusRendered}
+}
+
+// getDiagramOutputs returns the file each diagram of a job is written to.
+// Files holding several diagrams get one output per diagram, named after it.
+func getDiagramOutputs(job batchJob, defs []*packetdiagram.Definition) []string {
+	if len(defs) == 1 {
+		return []string{job.Output}
+	}
+
+	ext := filepath.Ext(job.Output)
+	base := strings.TrimSuffix(job.Output, ext)
+	outputs := make([]string, len(defs))
+	for i, def := range defs {
+		outputs[i] = base + "." + def.Name + ext
+	}
+	return outputs
+}
+
+// isUpToDate tells whether every output is newer than every source, the
+// definition file and the files it imports.
+func isUpToDate(sources []string, outputs []string) bool {
+	var newest time.Time
+	for _, source := range sources {
+		in, err := os.Stat(source)
+		if err != nil {
+			return false
+		}
+		if in.ModTime().After(newest) {
+			newest = in.ModTime()
+		}
+	}
+	for _, output := range outputs {
+		out, err := os.Stat(output)
+		if err != nil || !out.ModTime().After(newest) {
+			return false
+		}
+	}
+	return true
+}
+
+func renderToFile(def *packetdiagram.Definition, format packetdiagram.Format, path string) error {
+	out, err := renderDefinition(def, format)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
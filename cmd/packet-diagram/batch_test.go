@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestRunBatchJobUpToDate(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	job := batchJob{Input: filepath.Join(dir, "a.pd"), Output: filepath.Join(dir, "out", "a.svg")}
+	assert.NoError(t, ioutil.WriteFile(job.Input, []byte(serveTestDefinition), 0644))
+
+	// the times are set by hand as file systems may not tell writes in
+	// quick succession apart
+	past := time.Now().Add(-time.Hour)
+	setModTime := func(path string, t0 time.Time) {
+		assert.NoError(t, os.Chtimes(path, t0, t0))
+	}
+	setModTime(job.Input, past)
+
+	result := runBatchJob(job, "svg", false)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, batchStatusRendered, result.Status)
+	assert.Equal(t, []string{job.Output}, result.Outputs)
+	assert.FileExists(t, job.Output)
+
+	result = runBatchJob(job, "svg", false)
+	assert.Equal(t, batchStatusSkipped, result.Status)
+
+	result = runBatchJob(job, "svg", true)
+	assert.Equal(t, batchStatusRendered, result.Status)
+
+	// an output as old as its input is out of date
+	setModTime(job.Output, past)
+	result = runBatchJob(job, "svg", false)
+	assert.Equal(t, batchStatusRendered, result.Status)
+
+	setModTime(job.Input, time.Now().Add(time.Hour))
+	result = runBatchJob(job, "svg", false)
+	assert.Equal(t, batchStatusRendered, result.Status)
+}
+
+func TestRunBatchJobImportsUpToDate(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.pd")
+	job := batchJob{Input: filepath.Join(dir, "a.pd"), Output: filepath.Join(dir, "a.svg")}
+	assert.NoError(t, ioutil.WriteFile(lib, []byte("structures:\n  header:\n    - label: Version\n      bits: 8\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(job.Input, []byte("import: lib.pd\nplacements:\n  - structure: header\n"), 0644))
+	past := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(job.Input, past, past))
+	assert.NoError(t, os.Chtimes(lib, past, past))
+
+	result := runBatchJob(job, "svg", false)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, batchStatusRendered, result.Status)
+	result = runBatchJob(job, "svg", false)
+	assert.Equal(t, batchStatusSkipped, result.Status)
+
+	// editing the library alone makes the output stale
+	future := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(lib, future, future))
+	result = runBatchJob(job, "svg", false)
+	assert.Equal(t, batchStatusRendered, result.Status)
+}
+
+func TestRunBatchJobSeveralDiagrams(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	job := batchJob{Input: filepath.Join(dir, "a.pd"), Output: filepath.Join(dir, "a.svg")}
+	source := "name: first\n" + serveTestDefinition + "---\nname: second\n" + serveTestDefinition
+	assert.NoError(t, ioutil.WriteFile(job.Input, []byte(source), 0644))
+	past := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(job.Input, past, past))
+
+	result := runBatchJob(job, "svg", false)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, batchStatusRendered, result.Status)
+	assert.Equal(t, []string{filepath.Join(dir, "a.first.svg"), filepath.Join(dir, "a.second.svg")}, result.Outputs)
+
+	// one missing diagram is enough to render the file again
+	assert.NoError(t, os.Remove(result.Outputs[1]))
+	result = runBatchJob(job, "svg", false)
+	assert.Equal(t, batchStatusRendered, result.Status)
+	result = runBatchJob(job, "svg", false)
+	assert.Equal(t, batchStatusSkipped, result.Status)
+}
+
+func TestRunBatchJobFailure(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	job := batchJob{Input: filepath.Join(dir, "a.pd"), Output: filepath.Join(dir, "a.svg")}
+	assert.NoError(t, ioutil.WriteFile(job.Input, []byte("placements: [\n"), 0644))
+
+	result := runBatchJob(job, "svg", false)
+	assert.Error(t, result.Err)
+	assert.Equal(t, batchStatusFailed, result.Status)
+	assert.NoFileExists(t, job.Output)
+}
+
+func TestCollectBatchJobs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, name := range []string{"a.pd", "sub/b.pd", "sub/notes.txt"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(serveTestDefinition), 0644))
+	}
+
+	jobs, err := collectBatchJobs([]string{dir, filepath.Join(dir, "sub", "*.pd")}, "", "png")
+	assert.NoError(t, err)
+	assert.Equal(t, []batchJob{
+		{Input: filepath.Join(dir, "a.pd"), Output: filepath.Join(dir, "a.png")},
+		{Input: filepath.Join(dir, "sub", "b.pd"), Output: filepath.Join(dir, "sub", "b.png")},
+	}, jobs)
+
+	// globs pick definition files only, as directories do
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b.svg"), []byte("<svg/>"), 0644))
+	jobs, err = collectBatchJobs([]string{filepath.Join(dir, "sub", "*")}, "", "svg")
+	assert.NoError(t, err)
+	assert.Equal(t, []batchJob{
+		{Input: filepath.Join(dir, "sub", "b.pd"), Output: filepath.Join(dir, "sub", "b.svg")},
+	}, jobs)
+
+	jobs, err = collectBatchJobs([]string{dir}, "out", "svg")
+	assert.NoError(t, err)
+	assert.Equal(t, []batchJob{
+		{Input: filepath.Join(dir, "a.pd"), Output: filepath.Join("out", "a.svg")},
+		{Input: filepath.Join(dir, "sub", "b.pd"), Output: filepath.Join("out", "sub", "b.svg")},
+	}, jobs)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+)
+
+type diffCommand struct {
+	SVG string `long:"svg" description:"also write a diagram highlighting the changes to this file"`
+	All bool   `short:"a" long:"all" description:"list unchanged placements as well"`
+
+	Args struct {
+		Old string `positional-arg-name:"old"`
+		New string `positional-arg-name:"new"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *diffCommand) Execute(args []string) error {
+	old, err := loadDefinitionFile(c.Args.Old)
+	if err != nil {
+		return err
+	}
+	new, err := loadDefinitionFile(c.Args.New)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range packetdiagram.DiffDefinitions(old, new) {
+		if d.Status == packetdiagram.DiffStatusUnchanged && !c.All {
+			continue
+		}
+		fmt.Println(d)
+	}
+
+	if c.SVG != "" {
+		var buf bytes.Buffer
+		err = packetdiagram.DrawDiff(old, new, &buf)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(c.SVG, buf.Bytes(), 0644)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+)
+
+type exportCommand struct {
+	To     string `long:"to" choice:"kaitai" choice:"wireshark" choice:"scapy" choice:"rust" choice:"p4" required:"yes" description:"format to export to"`
+	Output string `short:"o" long:"output" description:"file to write to instead of stdout"`
+
+	Args struct {
+		File string `positional-arg-name:"file"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var exporters = map[string]func(*packetdiagram.Definition, io.Writer) error{
+	"kaitai":    packetdiagram.ExportKaitai,
+	"wireshark": packetdiagram.ExportWiresharkLua,
+	"scapy":     packetdiagram.ExportScapy,
+	"rust":      packetdiagram.ExportRust,
+	"p4":        packetdiagram.ExportP4,
+}
+
+func (c *exportCommand) Execute(args []string) error {
+	def, err := loadDefinitionFile(c.Args.File)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = exporters[c.To](def, &buf)
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(c.Output, buf.Bytes(), 0644)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+	"github.com/pkg/errors"
+)
+
+type fmtCommand struct {
+	Write bool `short:"w" long:"write" description:"write the result to the source file instead of stdout"`
+	List  bool `short:"l" long:"list" description:"list files whose formatting differs from canonical"`
+
+	Args struct {
+		Files []string `positional-arg-name:"file" required:"1"`
+	} `positional-args:"yes"`
+}
+
+func (c *fmtCommand) Execute(args []string) error {
+	for _, path := range c.Args.Files {
+		err := c.formatFile(path)
+		if err != nil {
+			return errors.Wrap(err, path)
+		}
+	}
+	return nil
+}
+
+func (c *fmtCommand) formatFile(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = packetdiagram.FormatDefinition(bytes.NewReader(src), &buf)
+	if err != nil {
+		return err
+	}
+	formatted := buf.Bytes()
+
+	if !c.List && !c.Write {
+		_, err = os.Stdout.Write(formatted)
+		return err
+	}
+
+	if bytes.Equal(src, formatted) {
+		return nil
+	}
+	if c.List {
+		fmt.Println(path)
+	}
+	if c.Write {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, formatted, fi.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+	"github.com/pkg/errors"
+)
+
+type importCommand struct {
+	From          string `long:"from" choice:"systemrdl" choice:"ipxact" choice:"go" choice:"c" choice:"kaitai" choice:"p4" description:"format of the source; guessed from its extension (.rdl, .xml, .go, .h, .c, .ksy, .p4) by default"`
+	Type          string `short:"t" long:"type" description:"name of the struct type to import from Go or C sources holding several"`
+	BitfieldOrder string `long:"bitfield-order" choice:"little-endian" choice:"big-endian" default:"little-endian" description:"how the C ABI allocates bit-fields within their storage unit"`
+	Output        string `short:"o" long:"output" description:"file to write the definitions to instead of stdout"`
+
+	Args struct {
+		File string `positional-arg-name:"file"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var importerExts = map[string]string{
+	".rdl":    "systemrdl",
+	".xml":    "ipxact",
+	".ipxact": "ipxact",
+	".go":     "go",
+	".h":      "c",
+	".c":      "c",
+	".ksy":    "kaitai",
+	".p4":     "p4",
+}
+
+func (c *importCommand) importer(from string) func(io.Reader) ([]*packetdiagram.Definition, error) {
+	switch from {
+	case "systemrdl":
+		return packetdiagram.ImportSystemRDL
+	case "ipxact":
+		return packetdiagram.ImportIPXACT
+	case "p4":
+		return packetdiagram.ImportP4
+	case "go":
+		return func(r io.Reader) ([]*packetdiagram.Definition, error) {
+			def, err := packetdiagram.ImportGoStruct(r, c.Type)
+			if err != nil {
+				return nil, err
+			}
+			return []*packetdiagram.Definition{def}, nil
+		}
+	case "c":
+		return func(r io.Reader) ([]*packetdiagram.Definition, error) {
+			def, err := packetdiagram.ImportCStruct(r, c.Type, packetdiagram.BitfieldOrder(c.BitfieldOrder))
+			if err != nil {
+				return nil, err
+			}
+			return []*packetdiagram.Definition{def}, nil
+		}
+	case "kaitai":
+		return func(r io.Reader) ([]*packetdiagram.Definition, error) {
+			def, err := packetdiagram.ImportKaitai(r)
+			if err != nil {
+				return nil, err
+			}
+			return []*packetdiagram.Definition{def}, nil
+		}
+	default:
+		return nil
+	}
+}
+
+func (c *importCommand) Execute(args []string) error {
+	from := c.From
+	if from == "" {
+		from = importerExts[strings.ToLower(filepath.Ext(c.Args.File))]
+		if from == "" {
+			return errors.Errorf("cannot tell the format of %s; use --from", c.Args.File)
+		}
+	}
+
+	f, err := os.Open(c.Args.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	defs, err := c.importer(from)(f)
+	if err != nil {
+		return errors.Wrap(err, c.Args.File)
+	}
+
+	var buf bytes.Buffer
+	err = packetdiagram.EncodeDefinitions(defs, &buf)
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(c.Output, buf.Bytes(), 0644)
+}
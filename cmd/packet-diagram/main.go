@@ -1,15 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	packetdiagram "github.com/bitbears-dev/packet-diagram"
 	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
 )
 
 var opts struct {
-	InputFile string `short:"i" long:"input" required:"true"`
+	InputFiles []string `short:"i" long:"input" description:"definition file, directory or glob to render; may be repeated"`
+	Format     string   `short:"f" long:"format" default:"svg" choice:"svg" choice:"png" choice:"txt" description:"output format"`
+	Output     string   `short:"o" long:"output" description:"file to write the diagram to instead of stdout"`
+	Diagram    string   `short:"d" long:"diagram" description:"name of the diagram to render from files holding several"`
+
+	OutputDir string `short:"O" long:"output-dir" description:"directory to write diagrams to when rendering several definitions; defaults to next to each definition"`
+	Jobs      int    `short:"j" long:"jobs" description:"number of definitions rendered in parallel; defaults to the number of CPUs"`
+	Force     bool   `long:"force" description:"render definitions even if their output is up to date"`
+
+	Watch         bool          `short:"w" long:"watch" description:"re-render whenever the input file changes"`
+	WatchInterval time.Duration `long:"watch-interval" default:"500ms" description:"how often the input file is checked for changes"`
+	Preview       bool          `long:"preview" description:"serve a live-reloading preview page (implies --watch)"`
+	PreviewListen string        `long:"preview-listen" default:"127.0.0.1:8000" description:"address the preview page is served on"`
 }
 
 func main() {
@@ -20,27 +38,175 @@ func main() {
 }
 
 func run() error {
-	_, err := flags.Parse(&opts)
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.SubcommandsOptional = true
+
+	_, err := parser.AddCommand("serve", "Serve diagrams over HTTP", "Run an HTTP service that renders definitions posted to it or encoded in the URL.", &serveCommand{})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("schema", "Print the JSON Schema of definitions", "Print the JSON Schema describing definition files, for editors to validate and complete them.", &schemaCommand{})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("fmt", "Format definitions", "Rewrite YAML definitions with canonical key spelling, key order and indentation, keeping comments.", &fmtCommand{})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("diff", "Compare two definitions", "Report placements added, removed, resized or shifted between two revisions of a definition.", &diffCommand{})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("stack", "Draw a protocol stack", "Draw the headers of a protocol stack nested in one another, with the detailed diagram of each.", &stackCommand{})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("import", "Import definitions from other formats", "Convert the registers of a SystemRDL or IP-XACT description into definitions, one diagram per register, a Go or C struct type or Kaitai Struct type into a definition, or the header types of a P4 program into definitions.", &importCommand{})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("export", "Export a definition to other formats", "Convert a definition into a Kaitai Struct type, a Wireshark Lua dissector, a Scapy layer, a Rust struct with parse and write functions or a P4 header type for the packets it describes.", &exportCommand{})
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Open(opts.InputFile)
+	_, err = parser.AddCommand("testvectors", "Generate test vectors of a definition", "Print field assignments of a definition and the bytes they encode to as JSON, for testing code generated from it: \"zeros\" with every bit clear, \"one\" with every field 1, \"ones\" with every bit set, \"max\" with every bit set and variable-length fields at their longest, then random ones.", &testVectorsCommand{})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	def, err := packetdiagram.LoadDefinition(f)
+	args, err := parser.Parse()
 	if err != nil {
 		return err
 	}
 
-	w := os.Stdout
-	err = packetdiagram.Draw(def, w)
+	if parser.Active != nil {
+		// the subcommand has already been executed by the parser
+		return nil
+	}
+
+	return render(append(opts.InputFiles, args...))
+}
+
+func render(inputs []string) error {
+	if len(inputs) == 0 {
+		return errors.New("the required flag `-i, --input' was not specified")
+	}
+
+	format, err := packetdiagram.ParseFormat(opts.Format)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if isBatch(inputs) {
+		if opts.Watch || opts.Preview || opts.Output != "" {
+			return errors.New("--watch, --preview and --output support a single input file only")
+		}
+		return renderBatch(inputs, format)
+	}
+
+	if opts.Watch || opts.Preview {
+		return watch(inputs[0], format)
+	}
+
+	defs, err := loadDefinitionFiles(inputs[0])
+	if err != nil {
+		return err
+	}
+	if len(defs) > 1 && opts.Output == "" {
+		// every diagram goes to a file of its own
+		return renderBatch(inputs, format)
+	}
+
+	def, err := packetdiagram.SelectDefinition(defs, opts.Diagram)
+	if err != nil {
+		return err
+	}
+
+	out, err := renderDefinition(def, format)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(out)
+}
+
+// Definitions are YAML by default. JSON and TOML definitions are told apart
+// by their extension, conventionally .pd.json and .pd.toml.
+var definitionFileExts = []string{".pd", ".pd.json", ".pd.toml"}
+
+func isDefinitionFile(path string) bool {
+	for _, ext := range definitionFileExts {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimDefinitionExt(path string) string {
+	for _, ext := range definitionFileExts {
+		if strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// loadDefinitionFiles loads the diagrams of a definition file, or only the
+// one chosen with --diagram.
+func loadDefinitionFiles(path string) ([]*packetdiagram.Definition, error) {
+	defs, _, err := loadDefinitionSources(path)
+	return defs, err
+}
+
+// loadDefinitionSources is like loadDefinitionFiles but also returns the
+// files the diagrams are read from, the file itself and its imports.
+func loadDefinitionSources(path string) ([]*packetdiagram.Definition, []string, error) {
+	defs, sources, err := packetdiagram.LoadDefinitionsFileSources(path)
+	if err != nil {
+		return nil, sources, err
+	}
+	if opts.Diagram == "" {
+		return defs, sources, nil
+	}
+
+	def, err := packetdiagram.SelectDefinition(defs, opts.Diagram)
+	if err != nil {
+		return nil, sources, err
+	}
+	return []*packetdiagram.Definition{def}, sources, nil
+}
+
+// loadDefinitionFile loads the one diagram of a definition file, or the one
+// chosen with --diagram.
+func loadDefinitionFile(path string) (*packetdiagram.Definition, error) {
+	defs, err := loadDefinitionFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	return packetdiagram.SelectDefinition(defs, "")
+}
+
+func renderDefinition(def *packetdiagram.Definition, format packetdiagram.Format) ([]byte, error) {
+	var buf bytes.Buffer
+	err := packetdiagram.Render(def, format, &buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeOutput(b []byte) error {
+	if opts.Output == "" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(opts.Output, b, 0644)
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+)
+
+// previewServer serves a page showing the latest rendering of the watched
+// definition. The page subscribes to /events and reloads the diagram from
+// /diagram every time a new version is published.
+type previewServer struct {
+	mux   *http.ServeMux
+	title string
+
+	mu          sync.Mutex
+	version     int
+	diagram     []byte
+	err         error
+	subscribers map[chan int]struct{}
+}
+
+func newPreviewServer(title string) *previewServer {
+	p := &previewServer{
+		mux:         http.NewServeMux(),
+		title:       title,
+		subscribers: map[chan int]struct{}{},
+	}
+	p.mux.HandleFunc("/", p.handlePage)
+	p.mux.HandleFunc("/diagram", p.handleDiagram)
+	p.mux.HandleFunc("/events", p.handleEvents)
+	return p
+}
+
+func (p *previewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// publish replaces the current diagram, or reports err in its place while
+// keeping the last good diagram around.
+func (p *previewServer) publish(diagram []byte, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.version++
+	if err == nil {
+		p.diagram = diagram
+	}
+	p.err = err
+
+	for ch := range p.subscribers {
+		select {
+		case ch <- p.version:
+		default:
+			// the subscriber has not picked up the previous version yet;
+			// it will fetch the latest diagram anyway
+		}
+	}
+}
+
+func (p *previewServer) subscribe() (chan int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ch := make(chan int, 1)
+	p.subscribers[ch] = struct{}{}
+	return ch, p.version
+}
+
+func (p *previewServer) unsubscribe(ch chan int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.subscribers, ch)
+}
+
+func (p *previewServer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	previewPage.Execute(w, p.title)
+}
+
+func (p *previewServer) handleDiagram(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	diagram, err := p.diagram, p.err
+	p.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	if err != nil {
+		w.Header().Set("X-Render-Error", "1")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(diagram)
+}
+
+func (p *previewServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, version := p.subscribe()
+	defer p.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for {
+		fmt.Fprintf(w, "event: update\ndata: %d\n\n", version)
+		flusher.Flush()
+
+		select {
+		case version = <-ch:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}} - packet-diagram preview</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+#error { display: none; color: #a00; background: #fee; border: 1px solid #a00; padding: 0.5em; white-space: pre-wrap; }
+#diagram.stale { opacity: 0.4; }
+</style>
+</head>
+<body>
+<pre id="error"></pre>
+<div id="diagram"></div>
+<script>
+const errorBox = document.getElementById("error");
+const diagram = document.getElementById("diagram");
+const events = new EventSource("/events");
+events.addEventListener("update", async (e) => {
+  const res = await fetch("/diagram?v=" + e.data);
+  const body = await res.text();
+  if (res.headers.get("X-Render-Error")) {
+    errorBox.textContent = body;
+    errorBox.style.display = "block";
+    diagram.classList.add("stale");
+    return;
+  }
+  errorBox.style.display = "none";
+  diagram.classList.remove("stale");
+  diagram.innerHTML = body;
+});
+</script>
+</body>
+</html>
+`))
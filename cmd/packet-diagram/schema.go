@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+)
+
+type schemaCommand struct{}
+
+func (c *schemaCommand) Execute(args []string) error {
+	return packetdiagram.WriteJSONSchema(os.Stdout)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+	"github.com/pkg/errors"
+)
+
+var errTooLarge = errors.New("definition is too large")
+
+type serveCommand struct {
+	Listen         string        `long:"listen" default:":8080" description:"address to listen on"`
+	MaxBodySize    int64         `long:"max-body-size" default:"1048576" description:"maximum size of a definition in bytes"`
+	Timeout        time.Duration `long:"timeout" default:"10s" description:"time limit for handling a single request"`
+	MaxConcurrency int           `long:"max-concurrency" default:"4" description:"maximum number of diagrams rendered at the same time"`
+}
+
+func (c *serveCommand) Execute(args []string) error {
+	if c.MaxBodySize <= 0 {
+		return errors.New("--max-body-size must be positive")
+	}
+	if c.MaxConcurrency <= 0 {
+		return errors.New("--max-concurrency must be positive")
+	}
+
+	server := &http.Server{
+		Addr:              c.Listen,
+		Handler:           c.newHandler(),
+		ReadHeaderTimeout: c.Timeout,
+		ReadTimeout:       c.Timeout,
+		WriteTimeout:      c.Timeout * 2,
+		MaxHeaderBytes:    int(c.MaxBodySize),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("listening on %s", c.Listen)
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// newHandler returns the handler of the service, which gives up on requests
+// taking longer than the timeout.
+func (c *serveCommand) newHandler() http.Handler {
+	return http.TimeoutHandler(newRenderHandler(c.MaxBodySize, c.MaxConcurrency), c.Timeout, "rendering timed out\n")
+}
+
+// renderHandler serves
+//
+//	POST /render?format=svg|png|txt            with the definition as the body,
+//	                                           in JSON or TOML if the Content-Type says so
+//	GET  /render/<encoded>?format=svg|png|txt  with the definition deflated and base64url encoded
+//
+// the latter being the encoding of Kroki URLs. PlantUML URLs are not
+// understood, as PlantUML uses an alphabet of its own rather than base64url.
+// Definitions holding several diagrams need a `diagram` query parameter
+// naming the one to render.
+type renderHandler struct {
+	maxBodySize int64
+	slots       chan struct{}
+}
+
+func newRenderHandler(maxBodySize int64, maxConcurrency int) http.Handler {
+	h := &renderHandler{
+		maxBodySize: maxBodySize,
+		slots:       make(chan struct{}, maxConcurrency),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/render", h.handlePost)
+	mux.HandleFunc("/render/", h.handleGet)
+	return mux
+}
+
+func (h *renderHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	source, err := readLimited(r.Body, h.maxBodySize)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+
+	// requests may not import files from the server
+	load := packetdiagram.LoadDefinitionsUntrusted
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case "application/json":
+		load = packetdiagram.LoadDefinitionsUntrustedJSON
+	case "application/toml":
+		load = packetdiagram.LoadDefinitionsUntrustedTOML
+	}
+
+	h.render(w, r, source, load)
+}
+
+func (h *renderHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	source, err := decodeSource(strings.TrimPrefix(r.URL.Path, "/render/"), h.maxBodySize)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+
+	h.render(w, r, source, packetdiagram.LoadDefinitionsUntrusted)
+}
+
+func (h *renderHandler) render(w http.ResponseWriter, r *http.Request, source []byte, load func(io.Reader) ([]*packetdiagram.Definition, error)) {
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = string(packetdiagram.FormatSVG)
+	}
+	format, err := packetdiagram.ParseFormat(formatName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defs, err := load(bytes.NewReader(source))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	def, err := packetdiagram.SelectDefinition(defs, r.URL.Query().Get("diagram"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+		defer func() { <-h.slots }()
+	case <-r.Context().Done():
+		// the timeout handler has already answered
+		return
+	}
+
+	var buf bytes.Buffer
+	err = packetdiagram.Render(def, format, &buf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Write(buf.Bytes())
+}
+
+func writeReadError(w http.ResponseWriter, err error) {
+	if err == errTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, errTooLarge
+	}
+	return b, nil
+}
+
+// decodeSource accepts base64url with or without padding, and both zlib
+// wrapped and raw deflate streams since encoders differ on those.
+func decodeSource(encoded string, limit int64) ([]byte, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode base64")
+	}
+
+	var inflater io.ReadCloser
+	inflater, err = zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		inflater = flate.NewReader(bytes.NewReader(compressed))
+	}
+	defer inflater.Close()
+
+	source, err := readLimited(inflater, limit)
+	if err != nil && err != errTooLarge {
+		return nil, errors.Wrap(err, "failed to inflate definition")
+	}
+	return source, err
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+const serveTestDefinition = `placements:
+  - label: Version
+    bits: 4
+  - label: IHL
+    bits: 4
+`
+
+func encodeServeTestSource(t *testing.T, source string) string {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(source))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestServe(t *testing.T) {
+	c := &serveCommand{MaxBodySize: 256, Timeout: 10 * time.Second, MaxConcurrency: 1}
+	handler := c.newHandler()
+
+	testData := []struct {
+		Name                string
+		Method              string
+		Target              string
+		ContentType         string
+		Body                string
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedBody        string
+	}{
+		{
+			Name:                "post",
+			Method:              http.MethodPost,
+			Target:              "/render",
+			Body:                serveTestDefinition,
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "image/svg+xml",
+			ExpectedBody:        "Version",
+		},
+		{
+			Name:                "post json as text",
+			Method:              http.MethodPost,
+			Target:              "/render?format=txt",
+			ContentType:         "application/json",
+			Body:                `{"placements": [{"label": "Version", "bits": 8}]}`,
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "text/plain; charset=utf-8",
+			ExpectedBody:        "Version",
+		},
+		{
+			Name:                "get",
+			Method:              http.MethodGet,
+			Target:              "/render/" + encodeServeTestSource(t, serveTestDefinition),
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "image/svg+xml",
+			ExpectedBody:        "IHL",
+		},
+		{
+			Name:           "oversized body",
+			Method:         http.MethodPost,
+			Target:         "/render",
+			Body:           serveTestDefinition + strings.Repeat("# padding\n", 30),
+			ExpectedStatus: http.StatusRequestEntityTooLarge,
+			ExpectedBody:   "definition is too large",
+		},
+		{
+			Name:           "oversized inflated definition",
+			Method:         http.MethodGet,
+			Target:         "/render/" + encodeServeTestSource(t, serveTestDefinition+strings.Repeat("# padding\n", 30)),
+			ExpectedStatus: http.StatusRequestEntityTooLarge,
+			ExpectedBody:   "definition is too large",
+		},
+		{
+			Name:           "bad base64",
+			Method:         http.MethodGet,
+			Target:         "/render/not*base64",
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedBody:   "failed to decode base64",
+		},
+		{
+			Name:           "bad deflate",
+			Method:         http.MethodGet,
+			Target:         "/render/" + base64.RawURLEncoding.EncodeToString([]byte("not deflated")),
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedBody:   "failed to inflate definition",
+		},
+		{
+			Name:           "import",
+			Method:         http.MethodPost,
+			Target:         "/render",
+			Body:           "import: /etc/passwd\n" + serveTestDefinition,
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedBody:   "imports are not allowed here",
+		},
+		{
+			Name:           "unknown format",
+			Method:         http.MethodPost,
+			Target:         "/render?format=gif",
+			Body:           serveTestDefinition,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name:           "wrong method",
+			Method:         http.MethodGet,
+			Target:         "/render",
+			ExpectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(tt.Method, tt.Target, strings.NewReader(tt.Body))
+			if tt.ContentType != "" {
+				r.Header.Set("Content-Type", tt.ContentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.ExpectedStatus, w.Code, w.Body.String())
+			if tt.ExpectedContentType != "" {
+				assert.Equal(t, tt.ExpectedContentType, w.Header().Get("Content-Type"))
+			}
+			assert.Contains(t, w.Body.String(), tt.ExpectedBody)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+)
+
+type stackCommand struct {
+	Format string `short:"f" long:"format" default:"svg" choice:"svg" choice:"png" description:"output format"`
+	Output string `short:"o" long:"output" description:"file to write the diagram to instead of stdout"`
+
+	Args struct {
+		Layers []string `positional-arg-name:"layer" description:"definition file of a layer, outermost first; file#name picks a diagram from files holding several"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *stackCommand) Execute(args []string) error {
+	format, err := packetdiagram.ParseFormat(c.Format)
+	if err != nil {
+		return err
+	}
+
+	layers := make([]packetdiagram.StackLayer, 0, len(c.Args.Layers))
+	for _, ref := range c.Args.Layers {
+		layer, err := loadStackLayer(ref)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+	}
+
+	var buf bytes.Buffer
+	err = packetdiagram.RenderStack(layers, format, &buf)
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(c.Output, buf.Bytes(), 0644)
+}
+
+// loadStackLayer loads the layer a reference of the form file[#diagram]
+// points at. Layers are labelled with the diagram name, or else the file
+// name.
+func loadStackLayer(ref string) (packetdiagram.StackLayer, error) {
+	path, name := ref, ""
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		path, name = ref[:i], ref[i+1:]
+	}
+
+	defs, err := packetdiagram.LoadDefinitionsFile(path)
+	if err != nil {
+		return packetdiagram.StackLayer{}, err
+	}
+	def, err := packetdiagram.SelectDefinition(defs, name)
+	if err != nil {
+		return packetdiagram.StackLayer{}, err
+	}
+
+	layer := packetdiagram.StackLayer{Definition: def}
+	if def.Name == "" {
+		layer.Label = filepath.Base(trimDefinitionExt(path))
+	}
+	return layer, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+)
+
+type testVectorsCommand struct {
+	Random int    `short:"n" long:"random" default:"8" description:"number of random vectors besides the boundary ones"`
+	Seed   int64  `long:"seed" default:"1" description:"seed of the random vectors"`
+	Output string `short:"o" long:"output" description:"file to write the vectors to instead of stdout"`
+
+	Args struct {
+		File string `positional-arg-name:"file"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *testVectorsCommand) Execute(args []string) error {
+	def, err := loadDefinitionFile(c.Args.File)
+	if err != nil {
+		return err
+	}
+
+	vectors, err := packetdiagram.GenerateTestVectors(def, c.Random, c.Seed)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if c.Output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(c.Output, b, 0644)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	packetdiagram "github.com/bitbears-dev/packet-diagram"
+	"github.com/pkg/errors"
+)
+
+func watch(path string, format packetdiagram.Format) error {
+	if opts.Output == "" && !opts.Preview {
+		return errors.New("--watch requires --output unless --preview is given")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var preview *previewServer
+	if opts.Preview {
+		preview = newPreviewServer(path)
+		server := &http.Server{
+			Addr:    opts.PreviewListen,
+			Handler: preview,
+		}
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+		go func() {
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				log.Printf("preview server: %v", err)
+				stop()
+			}
+		}()
+		log.Printf("preview available at http://%s/", opts.PreviewListen)
+	}
+
+	return watchFile(ctx, path, opts.WatchInterval, func() []string {
+		sources, err := renderOnChange(path, format, preview)
+		if err != nil {
+			log.Printf("error: %v", err)
+			if preview != nil {
+				preview.publish(nil, err)
+			}
+			return sources
+		}
+		log.Printf("rendered %s", path)
+		return sources
+	})
+}
+
+// renderOnChange leaves the previous output in place when the definition
+// is broken, so that a half-edited file does not wipe the last good diagram.
+// It returns the files the diagrams are read from, broken or not.
+func renderOnChange(path string, format packetdiagram.Format, preview *previewServer) ([]string, error) {
+	defs, sources, err := loadDefinitionSources(path)
+	if err != nil {
+		return sources, err
+	}
+
+	if opts.Output != "" {
+		def, err := packetdiagram.SelectDefinition(defs, "")
+		if err != nil {
+			return sources, err
+		}
+		out, err := renderDefinition(def, format)
+		if err != nil {
+			return sources, err
+		}
+		err = writeOutput(out)
+		if err != nil {
+			return sources, err
+		}
+	}
+
+	if preview != nil {
+		// the page shows every diagram of the file one below the other
+		var diagrams []byte
+		for _, def := range defs {
+			diagram, err := renderDefinition(def, packetdiagram.FormatSVG)
+			if err != nil {
+				return sources, err
+			}
+			diagrams = append(diagrams, diagram...)
+		}
+		preview.publish(diagrams, nil)
+	}
+
+	return sources, nil
+}
+
+// watchFile calls onChange once up front and then whenever the modification
+// time or the size of one of the files it returns changes, which are the
+// definition file and its imports. Polling is used rather than file system
+// notifications as editors that save by renaming a temporary file over the
+// original would otherwise drop the watch.
+func watchFile(ctx context.Context, path string, interval time.Duration, onChange func() []string) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	watched := []string{path}
+	reload := func() map[string]fileState {
+		last := statFiles(watched)
+		watched = onChange()
+		if len(watched) == 0 {
+			watched = []string{path}
+		}
+		// files new to the set are taken as they are now
+		for _, f := range watched {
+			if _, ok := last[f]; !ok {
+				last[f] = statFile(f)
+			}
+		}
+		return last
+	}
+	last := reload()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		changed := false
+		for _, f := range watched {
+			curr, prev := statFile(f), last[f]
+			last[f] = curr
+			if curr.err != nil {
+				if prev.err == nil {
+					log.Printf("error: %v", curr.err)
+				}
+				continue
+			}
+			if prev.err != nil || !curr.modTime.Equal(prev.modTime) || curr.size != prev.size {
+				changed = true
+			}
+		}
+		if changed {
+			last = reload()
+		}
+	}
+}
+
+// fileState is what watchFile compares to tell that a file changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	err     error
+}
+
+func statFile(path string) fileState {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{err: err}
+	}
+	return fileState{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+func statFiles(paths []string) map[string]fileState {
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		states[path] = statFile(path)
+	}
+	return states
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestWatchFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "a.pd")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(serveTestDefinition), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	done := make(chan error)
+	go func() {
+		done <- watchFile(ctx, path, 5*time.Millisecond, func() []string {
+			changes <- struct{}{}
+			return []string{path}
+		})
+	}()
+
+	waitForChange := func(what string) {
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change reported %s", what)
+		}
+	}
+	waitForChange("up front")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(serveTestDefinition+"# edited\n"), 0644))
+	waitForChange("after an edit")
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Empty(t, changes)
+}
+
+func TestWatchFileImports(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path, lib := filepath.Join(dir, "a.pd"), filepath.Join(dir, "lib.pd")
+	assert.NoError(t, ioutil.WriteFile(lib, []byte("structures:\n  header:\n    - label: Version\n      bits: 8\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(path, []byte("placements:\n  - label: A\n    bits: 8\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- watchFile(ctx, path, 5*time.Millisecond, func() []string {
+			_, sources, _ := loadDefinitionSources(path)
+			reloads <- sources
+			return sources
+		})
+	}()
+
+	waitForReload := func(what string) []string {
+		select {
+		case sources := <-reloads:
+			return sources
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no reload %s", what)
+			return nil
+		}
+	}
+	assert.Equal(t, []string{path}, waitForReload("up front"))
+
+	// the import is watched from the reload that finds it on
+	assert.NoError(t, ioutil.WriteFile(path, []byte("import: lib.pd\nplacements:\n  - structure: header\n"), 0644))
+	assert.Equal(t, []string{path, lib}, waitForReload("after adding the import"))
+	assert.NoError(t, ioutil.WriteFile(lib, []byte("structures:\n  header:\n    - label: Version\n      bits: 16\n"), 0644))
+	assert.Equal(t, []string{path, lib}, waitForReload("after editing the import"))
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRenderOnChange(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "a.pd")
+	preview := newPreviewServer(path)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		preview.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/diagram", nil))
+		return w
+	}
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(serveTestDefinition), 0644))
+	sources, err := renderOnChange(path, "svg", preview)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path}, sources)
+	w := get()
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Version")
+	good := w.Body.String()
+
+	// a broken definition is reported in place of the diagram, which is
+	// kept for when it is fixed
+	assert.NoError(t, ioutil.WriteFile(path, []byte("placements: [\n"), 0644))
+	_, err = renderOnChange(path, "svg", preview)
+	assert.Error(t, err)
+	preview.publish(nil, err)
+	w = get()
+	assert.Equal(t, "1", w.Header().Get("X-Render-Error"))
+	assert.Equal(t, err.Error(), w.Body.String())
+
+	assert.Equal(t, good, string(preview.diagram))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(serveTestDefinition), 0644))
+	_, err = renderOnChange(path, "svg", preview)
+	assert.NoError(t, err)
+	w = get()
+	assert.Empty(t, w.Header().Get("X-Render-Error"))
+	assert.Equal(t, good, w.Body.String())
+}
+
+func TestPreviewEvents(t *testing.T) {
+	t.Parallel()
+	preview := newPreviewServer("a.pd")
+	server := httptest.NewServer(preview)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/events")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	events := bufio.NewReader(res.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := events.ReadString('\n')
+			assert.NoError(t, err)
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, "event: update\ndata: 0\n", readEvent())
+
+	preview.publish([]byte("<svg/>"), nil)
+	assert.Equal(t, "event: update\ndata: 1\n", readEvent())
+	preview.publish(nil, errors.New("broken"))
+	assert.Equal(t, "event: update\ndata: 2\n", readEvent())
+}
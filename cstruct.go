@@ -0,0 +1,668 @@
+package packetdiagram
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	cPaddingLabel = "(padding)"
+
+	// the room given to flexible array members: one row
+	defaultCVariableLengthMaxBits = defaultOctetsPerLine * 8
+)
+
+// BitfieldOrder is how a C ABI allocates bit-fields within their storage
+// unit, which decides the order they appear in on the wire.
+type BitfieldOrder string
+
+const (
+	// BitfieldOrderLittleEndian allocates from the least significant bit,
+	// as GCC does on little-endian targets such as x86 and most ARM.
+	BitfieldOrderLittleEndian BitfieldOrder = "little-endian"
+	// BitfieldOrderBigEndian allocates from the most significant bit, as
+	// GCC does on big-endian targets.
+	BitfieldOrderBigEndian BitfieldOrder = "big-endian"
+)
+
+// ImportCStruct builds a Definition from the struct called name, by tag or
+// typedef, in C declarations, or from the only struct when name is empty.
+// Members are laid out in declaration order without alignment padding, as
+// in packed wire structures. Bit-fields sharing a storage unit are drawn in
+// the order the ABI allocates them, with the unused bits of the unit as
+// padding. Flexible array members become variable-length and members of
+// struct types are expanded in place, labelled with the path to them.
+func ImportCStruct(r io.Reader, name string, order BitfieldOrder) (*Definition, error) {
+	if order != BitfieldOrderLittleEndian && order != BitfieldOrderBigEndian {
+		return nil, errors.Errorf("unsupported bit-field order: %s", order)
+	}
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	toks, defines, err := tokenizeC(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cParser{
+		toks:     toks,
+		defines:  defines,
+		structs:  map[string]*cStruct{},
+		typedefs: map[string]*cType{},
+	}
+	err = p.parseDeclarations()
+	if err != nil {
+		return nil, err
+	}
+
+	var st *cStruct
+	if name == "" {
+		if len(p.named) != 1 {
+			names := make([]string, 0, len(p.named))
+			for _, s := range p.named {
+				names = append(names, s.name)
+			}
+			return nil, errors.Errorf("the source declares %d structs (%s); choose one by name", len(p.named), strings.Join(names, ", "))
+		}
+		st = p.named[0]
+	} else {
+		st = p.structs[name]
+		if t, ok := p.typedefs[name]; ok && t.st != nil {
+			st = t.st
+		}
+		if st == nil {
+			return nil, errors.Errorf("no struct named %s", name)
+		}
+	}
+
+	placements, err := st.layout(order, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &Definition{Name: st.name, Placements: placements}
+	err = def.validate()
+	if err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+type cToken struct {
+	text string
+	line int
+}
+
+type cType struct {
+	name string
+	bits uint
+	st   *cStruct
+}
+
+type cMember struct {
+	name     string
+	typ      *cType
+	dims     []uint
+	flexible bool
+	bitfield *uint
+	line     int
+}
+
+type cStruct struct {
+	name    string
+	members []cMember
+}
+
+// layout turns the members of a struct into placements.
+func (s *cStruct) layout(order BitfieldOrder, expanding []string) ([]Placement, error) {
+	for _, e := range expanding {
+		if e == s.name {
+			return nil, errors.Errorf("struct %s contains itself", s.name)
+		}
+	}
+	expanding = append(append([]string(nil), expanding...), s.name)
+
+	placements := make([]Placement, 0, len(s.members))
+
+	// the storage unit bit-fields are being allocated in
+	var unit []Placement
+	unitSize, unitUsed := uint(0), uint(0)
+	flush := func() {
+		if unit == nil {
+			return
+		}
+		if unitUsed < unitSize {
+			unit = append(unit, Placement{Label: cPaddingLabel, Bits: newUint(unitSize - unitUsed)})
+		}
+		if order == BitfieldOrderLittleEndian {
+			for i, j := 0, len(unit)-1; i < j; i, j = i+1, j-1 {
+				unit[i], unit[j] = unit[j], unit[i]
+			}
+		}
+		placements = append(placements, unit...)
+		unit, unitSize, unitUsed = nil, 0, 0
+	}
+
+	for _, m := range s.members {
+		if m.bitfield != nil {
+			if m.typ.st != nil || m.typ.bits == 0 {
+				return nil, errors.Errorf("line %d: bit-field %s must have an integer type", m.line, m.name)
+			}
+			w := *m.bitfield
+			if w > m.typ.bits {
+				return nil, errors.Errorf("line %d: bit-field %s is wider than its type", m.line, m.name)
+			}
+			if w == 0 {
+				flush()
+				continue
+			}
+			if unit == nil || unitSize != m.typ.bits || unitUsed+w > unitSize {
+				flush()
+				unit, unitSize = make([]Placement, 0), m.typ.bits
+			}
+			unit = append(unit, Placement{Label: m.name, Bits: newUint(w)})
+			unitUsed += w
+			continue
+		}
+		flush()
+
+		if m.flexible {
+			placements = append(placements, Placement{Label: m.name, VariableLength: &VariableLengthPlacementSpec{MaxBits: defaultCVariableLengthMaxBits}})
+			continue
+		}
+
+		if m.typ.st != nil && len(m.dims) == 0 {
+			expanded, err := m.typ.st.layout(order, expanding)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range expanded {
+				p.Label = m.name + "." + p.Label
+				placements = append(placements, p)
+			}
+			continue
+		}
+
+		bits, err := m.typ.size(order, expanding)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d: %s", m.line, m.name)
+		}
+		for _, d := range m.dims {
+			bits *= d
+		}
+		placements = append(placements, Placement{Label: m.name, Bits: newUint(bits)})
+	}
+	flush()
+
+	return placements, nil
+}
+
+func (t *cType) size(order BitfieldOrder, expanding []string) (uint, error) {
+	if t.st == nil {
+		return t.bits, nil
+	}
+	placements, err := t.st.layout(order, expanding)
+	if err != nil {
+		return 0, err
+	}
+	sum := uint(0)
+	for _, p := range placements {
+		if p.VariableLength != nil {
+			return 0, errors.Errorf("struct %s has no fixed size", t.st.name)
+		}
+		sum += *p.Bits
+	}
+	return sum, nil
+}
+
+// cFixedWidthType matches the fixed-width integer types of stdint.h and of
+// the Linux kernel, such as uint16_t, __u8 and __be32.
+var cFixedWidthType = regexp.MustCompile(`^(?:__)?(?:u|s|be|le|uint|int)(8|16|32|64)(?:_t)?$`)
+
+var cBuiltinTypes = map[string]uint{
+	"char":   8,
+	"short":  16,
+	"int":    32,
+	"long":   64,
+	"float":  32,
+	"double": 64,
+	"_Bool":  8,
+	"bool":   8,
+}
+
+var cIgnoredSpecifiers = map[string]bool{
+	"const":    true,
+	"volatile": true,
+	"signed":   true,
+	"unsigned": true,
+	"register": true,
+	"static":   true,
+	"extern":   true,
+}
+
+type cParser struct {
+	toks    []cToken
+	pos     int
+	defines map[string]string
+
+	structs  map[string]*cStruct
+	typedefs map[string]*cType
+	named    []*cStruct // structs with a tag or typedef name, in order
+}
+
+func (p *cParser) peek() cToken {
+	if p.pos >= len(p.toks) {
+		return cToken{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *cParser) next() cToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *cParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if p.pos < len(p.toks) {
+		line = p.toks[p.pos].line
+	} else if len(p.toks) > 0 {
+		line = p.toks[len(p.toks)-1].line
+	}
+	return errors.Errorf("line %d: "+format, append([]interface{}{line}, args...)...)
+}
+
+func (p *cParser) expect(text string) error {
+	if p.peek().text != text {
+		if p.pos >= len(p.toks) {
+			return p.errorf("expected `%s` but the input ended", text)
+		}
+		return p.errorf("expected `%s` but found `%s`", text, p.peek().text)
+	}
+	p.next()
+	return nil
+}
+
+// parseDeclarations reads the struct and typedef declarations at file scope,
+// skipping everything else.
+func (p *cParser) parseDeclarations() error {
+	for p.pos < len(p.toks) {
+		switch p.peek().text {
+		case "typedef":
+			p.next()
+			typ, err := p.parseType()
+			if err != nil {
+				return err
+			}
+			for {
+				p.skipAttributes()
+				name := p.next()
+				if !isCIdentifier(name.text) {
+					return errors.Errorf("line %d: expected a typedef name but found `%s`", name.line, name.text)
+				}
+				p.typedefs[name.text] = typ
+				if typ.st != nil && typ.st.name == "" {
+					typ.st.name = name.text
+					p.named = append(p.named, typ.st)
+				}
+				p.skipAttributes()
+				if p.peek().text != "," {
+					break
+				}
+				p.next()
+			}
+			err = p.expect(";")
+			if err != nil {
+				return err
+			}
+		case "struct":
+			start := p.pos
+			p.next()
+			p.skipAttributes()
+			p.next()
+			p.skipAttributes()
+			isDefinition := p.peek().text == "{"
+			p.pos = start
+			if !isDefinition {
+				p.skipStatement()
+				continue
+			}
+			_, err := p.parseType()
+			if err != nil {
+				return err
+			}
+			// variables declared along with the struct are of no interest
+			p.skipStatement()
+		default:
+			p.skipStatement()
+		}
+	}
+	return nil
+}
+
+func (p *cParser) parseType() (*cType, error) {
+	words := make([]string, 0, 2)
+	for {
+		p.skipAttributes()
+		t := p.peek()
+		switch {
+		case cIgnoredSpecifiers[t.text]:
+			if t.text == "signed" || t.text == "unsigned" {
+				words = append(words, t.text)
+			}
+			p.next()
+		case t.text == "struct" || t.text == "union":
+			if t.text == "union" {
+				return nil, p.errorf("unions are not supported")
+			}
+			p.next()
+			return p.parseStruct()
+		case t.text == "enum":
+			p.next()
+			if isCIdentifier(p.peek().text) {
+				p.next()
+			}
+			if p.peek().text == "{" {
+				p.skipBalanced("{", "}")
+			}
+			return &cType{name: "enum", bits: 32}, nil
+		case cBuiltinTypes[t.text] != 0:
+			words = append(words, t.text)
+			p.next()
+		case len(words) == 0 && isCIdentifier(t.text):
+			p.next()
+			if typ, ok := p.typedefs[t.text]; ok {
+				return typ, nil
+			}
+			if m := cFixedWidthType.FindStringSubmatch(t.text); m != nil {
+				bits, _ := strconv.Atoi(m[1])
+				return &cType{name: t.text, bits: uint(bits)}, nil
+			}
+			return nil, errors.Errorf("line %d: unknown type %s", t.line, t.text)
+		default:
+			if len(words) == 0 {
+				return nil, p.errorf("expected a type but found `%s`", t.text)
+			}
+			return builtinCType(words), nil
+		}
+	}
+}
+
+func builtinCType(words []string) *cType {
+	bits := cBuiltinTypes["int"]
+	for _, w := range words {
+		switch w {
+		case "char", "short", "float", "double", "_Bool", "bool", "long":
+			bits = cBuiltinTypes[w]
+		}
+	}
+	return &cType{name: strings.Join(words, " "), bits: bits}
+}
+
+func (p *cParser) parseStruct() (*cType, error) {
+	p.skipAttributes()
+	st := &cStruct{}
+	if isCIdentifier(p.peek().text) {
+		st.name = p.next().text
+	}
+	p.skipAttributes()
+
+	if p.peek().text != "{" {
+		if existing, ok := p.structs[st.name]; ok && st.name != "" {
+			return &cType{name: "struct " + st.name, st: existing}, nil
+		}
+		return nil, p.errorf("struct %s is not defined", st.name)
+	}
+	p.next()
+
+	for p.peek().text != "}" {
+		if p.pos >= len(p.toks) {
+			return nil, p.errorf("expected `}` but the input ended")
+		}
+		members, err := p.parseMembers()
+		if err != nil {
+			return nil, err
+		}
+		st.members = append(st.members, members...)
+	}
+	p.next()
+	p.skipAttributes()
+
+	if st.name != "" {
+		p.structs[st.name] = st
+		p.named = append(p.named, st)
+	}
+	return &cType{name: "struct " + st.name, st: st}, nil
+}
+
+// parseMembers reads one member declaration, which may declare several
+// members of the same type.
+func (p *cParser) parseMembers() ([]cMember, error) {
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]cMember, 0, 1)
+	for {
+		p.skipAttributes()
+		if p.peek().text == "*" {
+			return nil, p.errorf("pointers have no size on the wire")
+		}
+		name := p.next()
+		if !isCIdentifier(name.text) {
+			return nil, errors.Errorf("line %d: expected a member name but found `%s`", name.line, name.text)
+		}
+		m := cMember{name: name.text, typ: typ, line: name.line}
+
+		for p.peek().text == "[" {
+			p.next()
+			if p.peek().text == "]" {
+				m.flexible = true
+				p.next()
+				continue
+			}
+			n, err := p.parseConstant()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				// the old style of flexible array member
+				m.flexible = true
+			}
+			m.dims = append(m.dims, uint(n))
+			err = p.expect("]")
+			if err != nil {
+				return nil, err
+			}
+		}
+		if p.peek().text == ":" {
+			p.next()
+			n, err := p.parseConstant()
+			if err != nil {
+				return nil, err
+			}
+			w := uint(n)
+			m.bitfield = &w
+		}
+		p.skipAttributes()
+		members = append(members, m)
+
+		if p.peek().text != "," {
+			break
+		}
+		p.next()
+	}
+	return members, p.expect(";")
+}
+
+// parseConstant reads an integer literal, or a macro defined as one.
+func (p *cParser) parseConstant() (uint64, error) {
+	t := p.next()
+	text := t.text
+	if v, ok := p.defines[text]; ok {
+		text = v
+	}
+	n, err := strconv.ParseUint(strings.TrimRight(text, "uUlL"), 0, 64)
+	if err != nil {
+		return 0, errors.Errorf("line %d: expected an integer constant but found `%s`", t.line, t.text)
+	}
+	return n, nil
+}
+
+func (p *cParser) skipAttributes() {
+	for p.peek().text == "__attribute__" || p.peek().text == "__attribute" {
+		p.next()
+		p.skipBalanced("(", ")")
+	}
+}
+
+func (p *cParser) skipStatement() {
+	depth := 0
+	for p.pos < len(p.toks) {
+		switch p.next().text {
+		case "{", "(":
+			depth++
+		case "}", ")":
+			depth--
+			if depth == 0 && p.peek().text != ";" && p.peek().text != "," && !isCIdentifier(p.peek().text) {
+				// the end of a function body
+				return
+			}
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *cParser) skipBalanced(open, close string) {
+	depth := 0
+	for p.pos < len(p.toks) {
+		switch p.next().text {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func isCIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || (r < unicode.MaxASCII && unicode.IsLetter(r)) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+var cDefinePattern = regexp.MustCompile(`^#\s*define\s+([A-Za-z_]\w*)\s+\(?\s*([0-9][0-9A-Fa-fxXuUlL]*)\s*\)?\s*$`)
+
+// tokenizeC splits C source into tokens, dropping comments and preprocessor
+// lines. Object-like macros defined as integers are returned so that array
+// sizes and bit-field widths may use them.
+func tokenizeC(src string) ([]cToken, map[string]string, error) {
+	toks := make([]cToken, 0)
+	defines := map[string]string{}
+	line := 1
+	rs := []rune(src)
+	atLineStart := true
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '\n':
+			line++
+			atLineStart = true
+			i++
+			continue
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '#' && atLineStart:
+			var b strings.Builder
+			for i < len(rs) && rs[i] != '\n' {
+				if rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == '\n' {
+					line++
+					i += 2
+					continue
+				}
+				b.WriteRune(rs[i])
+				i++
+			}
+			directive := b.String()
+			for _, c := range []string{"//", "/*"} {
+				if j := strings.Index(directive, c); j >= 0 {
+					directive = directive[:j]
+				}
+			}
+			if m := cDefinePattern.FindStringSubmatch(strings.TrimSpace(directive)); m != nil {
+				defines[m[1]] = m[2]
+			}
+			continue
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			continue
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			start := line
+			i += 2
+			for ; i+1 < len(rs) && !(rs[i] == '*' && rs[i+1] == '/'); i++ {
+				if rs[i] == '\n' {
+					line++
+				}
+			}
+			if i+1 >= len(rs) {
+				return nil, nil, errors.Errorf("line %d: unterminated comment", start)
+			}
+			i += 2
+			continue
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(rs) && rs[j] != r && rs[j] != '\n' {
+				if rs[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			toks = append(toks, cToken{text: string(rs[i:minInt(j+1, len(rs))]), line: line})
+			i = j + 1
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i + 1
+			for j < len(rs) && (rs[j] == '_' || unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+			toks = append(toks, cToken{text: string(rs[i:j]), line: line})
+			i = j
+		default:
+			toks = append(toks, cToken{text: string(r), line: line})
+			i++
+		}
+		atLineStart = false
+	}
+	return toks, defines, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,122 @@
+package packetdiagram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+const testCStructSource = `#include <stdint.h>
+#define ADDR_LEN 4 /* octets */
+
+struct addr {
+	uint8_t b[ADDR_LEN];
+};
+
+/* a made-up header */
+typedef struct __attribute__((packed)) {
+	uint8_t ihl : 4,
+	        version : 4;
+	uint16_t length;
+	struct addr src;
+	unsigned int flag : 1; // padded to the unit
+	uint8_t options[];
+} hdr_t;
+`
+
+func TestImportCStruct(t *testing.T) {
+	testData := []struct {
+		Name     string
+		Order    BitfieldOrder
+		Expected []Placement
+	}{
+		{
+			Name:  "little-endian",
+			Order: BitfieldOrderLittleEndian,
+			Expected: []Placement{
+				{Label: "version", Bits: uintp(4)},
+				{Label: "ihl", Bits: uintp(4)},
+				{Label: "length", Bits: uintp(16)},
+				{Label: "src.b", Bits: uintp(32)},
+				{Label: "(padding)", Bits: uintp(31)},
+				{Label: "flag", Bits: uintp(1)},
+				{Label: "options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+			},
+		},
+		{
+			Name:  "big-endian",
+			Order: BitfieldOrderBigEndian,
+			Expected: []Placement{
+				{Label: "ihl", Bits: uintp(4)},
+				{Label: "version", Bits: uintp(4)},
+				{Label: "length", Bits: uintp(16)},
+				{Label: "src.b", Bits: uintp(32)},
+				{Label: "flag", Bits: uintp(1)},
+				{Label: "(padding)", Bits: uintp(31)},
+				{Label: "options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+			},
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			def, err := ImportCStruct(strings.NewReader(testCStructSource), "hdr_t", tt.Order)
+			assert.NoError(t, err)
+			assert.Equal(t, &Definition{Name: "hdr_t", Placements: tt.Expected}, def)
+		})
+	}
+}
+
+func TestImportCStructErrors(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Type   string
+		Error  string
+	}{
+		{
+			Name:   "ambiguous",
+			Source: "struct a { char x; };\nstruct b { char y; };\n",
+			Error:  "choose one by name",
+		},
+		{
+			Name:   "missing",
+			Source: "struct a { char x; };\n",
+			Type:   "b",
+			Error:  "no struct named b",
+		},
+		{
+			Name:   "pointer",
+			Source: "struct a {\n\tchar *x;\n};\n",
+			Error:  "line 2: pointers have no size on the wire",
+		},
+		{
+			Name:   "unknown type",
+			Source: "struct a {\n\tfoo_t x;\n};\n",
+			Error:  "line 2: unknown type foo_t",
+		},
+		{
+			Name:   "wide bit-field",
+			Source: "struct a {\n\tuint8_t x : 9;\n};\n",
+			Error:  "line 2: bit-field x is wider than its type",
+		},
+		{
+			Name:   "union",
+			Source: "union a { char x; };\nstruct b { union a u; };\n",
+			Error:  "unions are not supported",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ImportCStruct(strings.NewReader(tt.Source), tt.Type, BitfieldOrderLittleEndian)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.Error)
+		})
+	}
+}
@@ -1,14 +1,12 @@
 package packetdiagram
 
 import (
-	"fmt"
 	"io"
 	"log"
 	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -22,111 +20,241 @@ const (
 	defaultYAxisOctetsOrigin  = 0
 	defaultCellWidth          = 30
 	defaultCellHeight         = 30
+	defaultRegisterCellHeight = 50
 	defaultBreakMarkWidth     = 10
 	defaultBreakMarkHeight    = 10
+	defaultHeaderHeight       = 20
 )
 
 type Definition struct {
-	Theme         *ThemeSpec    `yaml:"theme,omitempty"`
-	OctetsPerLine *uint         `yaml:"octets-per-line,omitempty"`
-	XAxis         XAxisSpec     `yaml:"x-axis"`
-	YAxis         YAxisSpec     `yaml:"y-axis"`
-	Cell          CellSpec      `yaml:"cell"`
-	BreakMark     BreakMarkSpec `yaml:"break-mark"`
-	Placements    []Placement   `yaml:"placements"`
+	Name          string        `yaml:"name,omitempty" json:"name,omitempty" toml:"name,omitempty"`
+	Mode          *Mode         `yaml:"mode,omitempty" json:"mode,omitempty" toml:"mode,omitempty"`
+	ByteOrder     *ByteOrder    `yaml:"byte-order,omitempty" json:"byte-order,omitempty" toml:"byte-order,omitempty"`
+	BitOrder      *BitOrder     `yaml:"bit-order,omitempty" json:"bit-order,omitempty" toml:"bit-order,omitempty"`
+	Theme         *ThemeSpec    `yaml:"theme,omitempty" json:"theme,omitempty" toml:"theme,omitempty"`
+	OctetsPerLine *uint         `yaml:"octets-per-line,omitempty" json:"octets-per-line,omitempty" toml:"octets-per-line,omitempty"`
+	XAxis         XAxisSpec     `yaml:"x-axis,omitempty" json:"x-axis,omitempty" toml:"x-axis,omitempty"`
+	YAxis         YAxisSpec     `yaml:"y-axis,omitempty" json:"y-axis,omitempty" toml:"y-axis,omitempty"`
+	Cell          CellSpec      `yaml:"cell,omitempty" json:"cell,omitempty" toml:"cell,omitempty"`
+	BreakMark     BreakMarkSpec `yaml:"break-mark,omitempty" json:"break-mark,omitempty" toml:"break-mark,omitempty"`
+	Offsets       *OffsetsSpec  `yaml:"offsets,omitempty" json:"offsets,omitempty" toml:"offsets,omitempty"`
+	Placements    []Placement   `yaml:"placements,omitempty" json:"placements,omitempty" toml:"placements,omitempty"`
 }
 
 type XAxisSpec struct {
-	Bits   *XAxisBitsSpec   `yaml:"bits,omitempty"`
-	Octets *XAxisOctetsSpec `yaml:"octets,omitempty"`
+	Bits   *XAxisBitsSpec   `yaml:"bits,omitempty" json:"bits,omitempty" toml:"bits,omitempty"`
+	Octets *XAxisOctetsSpec `yaml:"octets,omitempty" json:"octets,omitempty" toml:"octets,omitempty"`
 }
 
 type YAxisSpec struct {
-	Bits   *YAxisBitsSpec   `yaml:"bits,omitempty"`
-	Octets *YAxisOctetsSpec `yaml:"octets,omitempty"`
+	Bits   *YAxisBitsSpec   `yaml:"bits,omitempty" json:"bits,omitempty" toml:"bits,omitempty"`
+	Octets *YAxisOctetsSpec `yaml:"octets,omitempty" json:"octets,omitempty" toml:"octets,omitempty"`
 }
 
 type XAxisBitsSpec struct {
-	Show      *bool               `yaml:"show,omitempty"`
-	Height    *uint               `yaml:"height,omitempty"`
-	Direction *XAxisBitsDirection `yaml:"direction,omitempty"`
-	Origin    *uint               `yaml:"origin,omitempty"`
-	Unit      *XAxisBitsUnit      `yaml:"unit,omitempty"`
+	Show      *bool               `yaml:"show,omitempty" json:"show,omitempty" toml:"show,omitempty"`
+	Height    *uint               `yaml:"height,omitempty" json:"height,omitempty" toml:"height,omitempty"`
+	Direction *XAxisBitsDirection `yaml:"direction,omitempty" json:"direction,omitempty" toml:"direction,omitempty"`
+	Origin    *uint               `yaml:"origin,omitempty" json:"origin,omitempty" toml:"origin,omitempty"`
+	Unit      *XAxisBitsUnit      `yaml:"unit,omitempty" json:"unit,omitempty" toml:"unit,omitempty"`
+	Format    *AxisLabelFormat    `yaml:"format,omitempty" json:"format,omitempty" toml:"format,omitempty"`
 }
 
 type XAxisBitsDirection string
 
 const (
 	XAxisBitsDirectionLeftToRight XAxisBitsDirection = "left-to-right"
+	XAxisBitsDirectionRightToLeft XAxisBitsDirection = "right-to-left"
 )
 
 type XAxisBitsUnit uint
 
+// XAxisOctetsSpec marks the octets of a line, or its words of WordBits bits
+// in word mode.
 type XAxisOctetsSpec struct {
-	Show   *bool `yaml:"show,omitempty"`
-	Height *uint `yaml:"height,omitempty"`
+	Show     *bool            `yaml:"show,omitempty" json:"show,omitempty" toml:"show,omitempty"`
+	Height   *uint            `yaml:"height,omitempty" json:"height,omitempty" toml:"height,omitempty"`
+	Format   *AxisLabelFormat `yaml:"format,omitempty" json:"format,omitempty" toml:"format,omitempty"`
+	WordBits *uint            `yaml:"word-bits,omitempty" json:"word-bits,omitempty" toml:"word-bits,omitempty"`
 }
 
 type YAxisBitsSpec struct {
-	Show   *bool `yaml:"show,omitempty"`
-	Width  *uint `yaml:"width,omitempty"`
-	Origin *uint `yaml:"origin,omitempty"`
+	Show   *bool            `yaml:"show,omitempty" json:"show,omitempty" toml:"show,omitempty"`
+	Width  *uint            `yaml:"width,omitempty" json:"width,omitempty" toml:"width,omitempty"`
+	Origin *uint            `yaml:"origin,omitempty" json:"origin,omitempty" toml:"origin,omitempty"`
+	Format *AxisLabelFormat `yaml:"format,omitempty" json:"format,omitempty" toml:"format,omitempty"`
 }
 
+// YAxisOctetsSpec labels rows with the octet they start at, or the word of
+// WordBits bits in word mode, counted from Origin.
 type YAxisOctetsSpec struct {
-	Show   *bool `yaml:"show,omitempty"`
-	Width  *uint `yaml:"width,omitempty"`
-	Origin *uint `yaml:"origin,omitempty"`
+	Show     *bool            `yaml:"show,omitempty" json:"show,omitempty" toml:"show,omitempty"`
+	Width    *uint            `yaml:"width,omitempty" json:"width,omitempty" toml:"width,omitempty"`
+	Origin   *uint            `yaml:"origin,omitempty" json:"origin,omitempty" toml:"origin,omitempty"`
+	Format   *AxisLabelFormat `yaml:"format,omitempty" json:"format,omitempty" toml:"format,omitempty"`
+	WordBits *uint            `yaml:"word-bits,omitempty" json:"word-bits,omitempty" toml:"word-bits,omitempty"`
 }
 
 type CellSpec struct {
-	Width  *uint `yaml:"width,omitempty"`
-	Height *uint `yaml:"height,omitempty"`
+	Width  *uint `yaml:"width,omitempty" json:"width,omitempty" toml:"width,omitempty"`
+	Height *uint `yaml:"height,omitempty" json:"height,omitempty" toml:"height,omitempty"`
 }
 
+// BreakMarkSpec is how variable-length placements are marked as such. The
+// marks go on Row of the placement, counted from 0, when it is given.
 type BreakMarkSpec struct {
-	Width  *uint `yaml:"width,omitempty"`
-	Height *uint `yaml:"height,omitempty"`
+	Width  *uint           `yaml:"width,omitempty" json:"width,omitempty" toml:"width,omitempty"`
+	Height *uint           `yaml:"height,omitempty" json:"height,omitempty" toml:"height,omitempty"`
+	Style  *BreakMarkStyle `yaml:"style,omitempty" json:"style,omitempty" toml:"style,omitempty"`
+	Row    *uint           `yaml:"row,omitempty" json:"row,omitempty" toml:"row,omitempty"`
 }
 
 type Placement struct {
-	Label          string                       `yaml:"label"`
-	Bits           *uint                        `yaml:"bits,omitempty"`
-	VariableLength *VariableLengthPlacementSpec `yaml:"variable-length,omitempty"`
-	Fill           *string                      `yaml:"fill,omitempty"`
+	Name           string                       `yaml:"name,omitempty" json:"name,omitempty" toml:"name,omitempty"`
+	Label          string                       `yaml:"label" json:"label" toml:"label"`
+	Bits           *uint                        `yaml:"bits,omitempty" json:"bits,omitempty" toml:"bits,omitempty"`
+	VariableLength *VariableLengthPlacementSpec `yaml:"variable-length,omitempty" json:"variable-length,omitempty" toml:"variable-length,omitempty"`
+	Structure      string                       `yaml:"structure,omitempty" json:"structure,omitempty" toml:"structure,omitempty"`
+	Access         *AccessType                  `yaml:"access,omitempty" json:"access,omitempty" toml:"access,omitempty"`
+	Reset          *uint64                      `yaml:"reset,omitempty" json:"reset,omitempty" toml:"reset,omitempty"`
+	Values         []ValueSpec                  `yaml:"values,omitempty" json:"values,omitempty" toml:"values,omitempty"`
+	ByteOrder      *ByteOrder                   `yaml:"byte-order,omitempty" json:"byte-order,omitempty" toml:"byte-order,omitempty"`
+	BitOrder       *BitOrder                    `yaml:"bit-order,omitempty" json:"bit-order,omitempty" toml:"bit-order,omitempty"`
+	Fill           *string                      `yaml:"fill,omitempty" json:"fill,omitempty" toml:"fill,omitempty"`
+	Pattern        *FillPattern                 `yaml:"pattern,omitempty" json:"pattern,omitempty" toml:"pattern,omitempty"`
+	Kind           *PlacementKind               `yaml:"kind,omitempty" json:"kind,omitempty" toml:"kind,omitempty"`
+}
+
+// VariableLengthPlacementSpec sizes a placement whose length varies from
+// MinBits to MaxBits, which the field LengthField names may give in bytes. It
+// is drawn with RowsBeforeBreak rows, the rows after them up to its last one
+// being left out.
+type VariableLengthPlacementSpec struct {
+	MaxBits         uint   `yaml:"max-bits" json:"max-bits" toml:"max-bits"`
+	MinBits         *uint  `yaml:"min-bits,omitempty" json:"min-bits,omitempty" toml:"min-bits,omitempty"`
+	LengthField     string `yaml:"length-field,omitempty" json:"length-field,omitempty" toml:"length-field,omitempty"`
+	RowsBeforeBreak *uint  `yaml:"rows-before-break,omitempty" json:"rows-before-break,omitempty" toml:"rows-before-break,omitempty"`
 }
 
-type VariableLengthPlacementSpec struct {
-	MaxBits uint `yaml:"max-bits"`
+// ValueSpec names a value a field may take, as an enumeration does.
+type ValueSpec struct {
+	Value uint64 `yaml:"value" json:"value" toml:"value"`
+	Label string `yaml:"label" json:"label" toml:"label"`
 }
 
+// GetKey returns what identifies the placement across revisions of a
+// definition: its name, or its label when it has no name.
+func (p Placement) GetKey() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Label
+}
+
+// GetBits returns the number of bits the placement takes up in the layout,
+// which is the maximum for a variable-length placement.
+func (p Placement) GetBits() uint {
+	if p.VariableLength != nil {
+		return p.VariableLength.MaxBits
+	}
+	if p.Bits == nil {
+		return 0
+	}
+	return *p.Bits
+}
+
+// LoadDefinition reads a YAML definition file holding a single diagram. Use
+// LoadDefinitions for files that may hold several.
 func LoadDefinition(r io.Reader) (*Definition, error) {
-	b, err := io.ReadAll(r)
+	defs, err := LoadDefinitions(r)
 	if err != nil {
 		return nil, err
 	}
+	return SelectDefinition(defs, "")
+}
 
-	var def Definition
-	err = yaml.Unmarshal(b, &def)
+// LoadDefinitionJSON reads a JSON definition file holding a single diagram.
+func LoadDefinitionJSON(r io.Reader) (*Definition, error) {
+	defs, err := LoadDefinitionsJSON(r)
 	if err != nil {
 		return nil, err
 	}
+	return SelectDefinition(defs, "")
+}
 
-	err = def.validate()
+// LoadDefinitionTOML reads a TOML definition file holding a single diagram.
+func LoadDefinitionTOML(r io.Reader) (*Definition, error) {
+	defs, err := LoadDefinitionsTOML(r)
 	if err != nil {
 		return nil, err
 	}
-
-	return &def, nil
+	return SelectDefinition(defs, "")
 }
 
 func (d *Definition) validate() error {
 	for _, p := range d.Placements {
-		if p.Bits == nil && p.VariableLength == nil {
-			return errors.New("either `bits` or `valiable-length` field is required for a placement")
+		if p.Bits == nil && p.VariableLength == nil && p.Structure == "" {
+			return errors.New("one of `bits`, `variable-length` or `structure` is required for a placement")
+		}
+		err := p.validateRegisterSpec()
+		if err != nil {
+			return err
+		}
+		err = p.validateValues()
+		if err != nil {
+			return err
 		}
 	}
 
+	err := d.validateOrders()
+	if err != nil {
+		return err
+	}
+	err = d.validateAxes()
+	if err != nil {
+		return err
+	}
+	err = d.validateOffsets()
+	if err != nil {
+		return err
+	}
+	err = d.validateVariableLengths()
+	if err != nil {
+		return err
+	}
+	err = d.validateBreakMark()
+	if err != nil {
+		return err
+	}
+	err = d.validateFillPatterns()
+	if err != nil {
+		return err
+	}
+
+	if d.IsRegisterMode() {
+		return d.validateRegister()
+	}
+
+	return nil
+}
+
+func (p Placement) validateValues() error {
+	if len(p.Values) == 0 {
+		return nil
+	}
+	if p.Bits == nil {
+		return errors.Errorf("placement %s: only fields with `bits` can have values", p.GetKey())
+	}
+	seen := map[uint64]bool{}
+	for _, v := range p.Values {
+		if *p.Bits < 64 && v.Value>>*p.Bits != 0 {
+			return errors.Errorf("placement %s: value %d does not fit in %d bits", p.GetKey(), v.Value, *p.Bits)
+		}
+		if seen[v.Value] {
+			return errors.Errorf("placement %s: value %d is named more than once", p.GetKey(), v.Value)
+		}
+		seen[v.Value] = true
+	}
 	return nil
 }
 
@@ -159,8 +287,8 @@ func (d *Definition) GetXAxisOctetsHeight() uint {
 
 func (d *Definition) GetYAxisBitsWidth() uint {
 	if d.YAxis.Bits == nil || d.YAxis.Bits.Width == nil {
-		tb := d.GetTotalPlacementBits()
-		cols := uint(len(fmt.Sprintf("%d", tb)))
+		tb := d.GetYAxisBitsOrigin() + d.GetTotalPlacementBits()
+		cols := uint(len(formatAxisLabel(tb, 1, d.GetYAxisBitsFormat())))
 		numWidth := cols * d.GetTextSizeInPixels()
 		titleWidth := d.GetAxisTitleTextSizeInPixels() * (3 + 2)
 
@@ -172,15 +300,14 @@ func (d *Definition) GetYAxisBitsWidth() uint {
 
 func (d *Definition) GetYAxisOctetsWidth() uint {
 	if d.YAxis.Octets == nil || d.YAxis.Octets.Width == nil {
-		to := d.GetTotalPlacementOctets()
-		cols := uint(len(fmt.Sprintf("%d", to)))
+		cols := uint(len(d.formatYAxisOctetLabel(d.GetTotalPlacementOctets())))
 		numWidth := cols * d.GetTextSizeInPixels()
 		titleWidth := d.GetAxisTitleTextSizeInPixels() * (5 + 2) // len("octet") + somehow we need "2"
 
 		return maxUint(numWidth, titleWidth)
 	}
 
-	return *d.YAxis.Bits.Width
+	return *d.YAxis.Octets.Width
 }
 
 func maxUint(nums ...uint) uint {
@@ -365,6 +492,10 @@ func (d *Definition) GetCellWidth() uint {
 
 func (d *Definition) GetCellHeight() uint {
 	if d.Cell.Height == nil {
+		if d.IsRegisterMode() {
+			// room for the bit range and reset value around the label
+			return defaultRegisterCellHeight
+		}
 		return defaultCellHeight
 	}
 
@@ -406,24 +537,17 @@ func (d *Definition) GetTotalPlacementBits() uint {
 	return sum
 }
 
-func (d *Definition) GetTotalRows() uint {
-	rows := uint(1)
-	bits := uint(0)
-	for _, p := range d.Placements {
-		if p.VariableLength == nil {
-			bits += *p.Bits
-		} else {
-			bits += p.VariableLength.MaxBits
-		}
-
-		for bits >= d.GetBitsPerLine() {
-			rows++
-			bits -= d.GetBitsPerLine()
-		}
+// GetPlacementOffsets returns the bit offset each placement starts at.
+func (d *Definition) GetPlacementOffsets() []uint {
+	offsets := make([]uint, len(d.Placements))
+	offset := uint(0)
+	for i, p := range d.Placements {
+		offsets[i] = offset
+		offset += p.GetBits()
 	}
+	return offsets
+}
 
-	if bits == 0 {
-		rows--
-	}
-	return rows
+func (d *Definition) GetTotalRows() uint {
+	return uint(len(d.getLayout().rows))
 }
@@ -0,0 +1,108 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestLoadDefinitionFormats(t *testing.T) {
+	expected := &Definition{
+		OctetsPerLine: uintp(2),
+		XAxis: XAxisSpec{
+			Bits: &XAxisBitsSpec{
+				Origin: uintp(1),
+			},
+		},
+		Placements: []Placement{
+			{Label: "Type", Bits: uintp(8), Fill: stringp("gray")},
+			{Label: "Value", VariableLength: &VariableLengthPlacementSpec{MaxBits: 64}},
+		},
+	}
+
+	testData := []struct {
+		Name   string
+		Load   func(io.Reader) (*Definition, error)
+		Source string
+	}{
+		{
+			Name: "YAML",
+			Load: LoadDefinition,
+			Source: `
+octets-per-line: 2
+x-axis:
+  bits:
+    origin: 1
+placements:
+  - label: Type
+    bits: 8
+    fill: gray
+  - label: Value
+    variable-length:
+      max-bits: 64
+`,
+		},
+		{
+			Name: "JSON",
+			Load: LoadDefinitionJSON,
+			Source: `{
+  "octets-per-line": 2,
+  "x-axis": {"bits": {"origin": 1}},
+  "placements": [
+    {"label": "Type", "bits": 8, "fill": "gray"},
+    {"label": "Value", "variable-length": {"max-bits": 64}}
+  ]
+}`,
+		},
+		{
+			Name: "TOML",
+			Load: LoadDefinitionTOML,
+			Source: `
+octets-per-line = 2
+
+[x-axis.bits]
+origin = 1
+
+[[placements]]
+label = "Type"
+bits = 8
+fill = "gray"
+
+[[placements]]
+label = "Value"
+variable-length = { max-bits = 64 }
+`,
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			def, err := data.Load(strings.NewReader(data.Source))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, def)
+		})
+	}
+}
+
+func TestPublishedJSONSchemaIsUpToDate(t *testing.T) {
+	published, err := ioutil.ReadFile("schema/packet-diagram.schema.json")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = WriteJSONSchema(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, string(published), buf.String(), "run `make schema` to regenerate the published schema")
+}
+
+func TestValidatePlacementSize(t *testing.T) {
+	t.Parallel()
+	_, err := LoadDefinition(strings.NewReader("placements:\n  - label: a\n"))
+	assert.EqualError(t, err, "one of `bits`, `variable-length` or `structure` is required for a placement")
+}
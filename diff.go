@@ -0,0 +1,209 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	svg "github.com/ajstarks/svgo"
+)
+
+const (
+	diffAddedFill   = "#b7e4b7"
+	diffRemovedFill = "#f4b6b6"
+	diffChangedFill = "#fcd98a"
+	diffCaptionSize = 20
+	diffGap         = 20
+)
+
+type DiffStatus string
+
+const (
+	DiffStatusUnchanged DiffStatus = "unchanged"
+	DiffStatusAdded     DiffStatus = "added"
+	DiffStatusRemoved   DiffStatus = "removed"
+	DiffStatusChanged   DiffStatus = "changed"
+)
+
+// PlacementLocation tells where a placement sits in one revision of a
+// definition.
+type PlacementLocation struct {
+	Index  int
+	Offset uint
+	Bits   uint
+}
+
+type PlacementDiff struct {
+	Key     string
+	Status  DiffStatus
+	Old     *PlacementLocation
+	New     *PlacementLocation
+	Resized bool
+	Shifted bool
+}
+
+// DiffDefinitions matches the placements of two revisions of a definition
+// by name, or by label for placements without a name, and reports how each
+// of them changed. Placements sharing a key are matched in order of
+// appearance. The result follows the order of the new revision, with
+// removed placements where they used to be.
+func DiffDefinitions(old, new *Definition) []PlacementDiff {
+	oldOffsets := old.GetPlacementOffsets()
+	newOffsets := new.GetPlacementOffsets()
+
+	oldByKey := map[string][]int{}
+	for i, p := range old.Placements {
+		oldByKey[p.GetKey()] = append(oldByKey[p.GetKey()], i)
+	}
+
+	matched := make([]bool, len(old.Placements))
+	news := make([]PlacementDiff, 0, len(new.Placements))
+	for i, p := range new.Placements {
+		d := PlacementDiff{
+			Key:    p.GetKey(),
+			Status: DiffStatusAdded,
+			New:    &PlacementLocation{Index: i, Offset: newOffsets[i], Bits: p.GetBits()},
+		}
+
+		if candidates := oldByKey[p.GetKey()]; len(candidates) > 0 {
+			j := candidates[0]
+			oldByKey[p.GetKey()] = candidates[1:]
+			matched[j] = true
+
+			d.Old = &PlacementLocation{Index: j, Offset: oldOffsets[j], Bits: old.Placements[j].GetBits()}
+			d.Resized = d.Old.Bits != d.New.Bits
+			d.Shifted = d.Old.Offset != d.New.Offset
+			d.Status = DiffStatusUnchanged
+			if d.Resized || d.Shifted {
+				d.Status = DiffStatusChanged
+			}
+		}
+		news = append(news, d)
+	}
+
+	diffs := make([]PlacementDiff, 0, len(news))
+	next := 0 // the first old placement not reported yet
+	flushRemoved := func(until int) {
+		for ; next < until; next++ {
+			if matched[next] {
+				continue
+			}
+			diffs = append(diffs, PlacementDiff{
+				Key:    old.Placements[next].GetKey(),
+				Status: DiffStatusRemoved,
+				Old:    &PlacementLocation{Index: next, Offset: oldOffsets[next], Bits: old.Placements[next].GetBits()},
+			})
+		}
+	}
+	for _, d := range news {
+		if d.Old != nil && d.Old.Index >= next {
+			flushRemoved(d.Old.Index)
+		}
+		diffs = append(diffs, d)
+	}
+	flushRemoved(len(old.Placements))
+
+	return diffs
+}
+
+func (l *PlacementLocation) String() string {
+	if l.Bits == 0 {
+		return fmt.Sprintf("[%d]", l.Offset)
+	}
+	return fmt.Sprintf("[%d:%d]", l.Offset, l.Offset+l.Bits-1)
+}
+
+func (d PlacementDiff) String() string {
+	switch d.Status {
+	case DiffStatusAdded:
+		return fmt.Sprintf("+ %s %s, %d bits", d.Key, d.New, d.New.Bits)
+	case DiffStatusRemoved:
+		return fmt.Sprintf("- %s %s, %d bits", d.Key, d.Old, d.Old.Bits)
+	case DiffStatusChanged:
+		changes := make([]string, 0, 2)
+		if d.Resized {
+			changes = append(changes, fmt.Sprintf("resized %d -> %d bits", d.Old.Bits, d.New.Bits))
+		}
+		if d.Shifted {
+			changes = append(changes, fmt.Sprintf("shifted by %+d bits", int(d.New.Offset)-int(d.Old.Offset)))
+		}
+		return fmt.Sprintf("~ %s %s -> %s, %s", d.Key, d.Old, d.New, strings.Join(changes, ", "))
+	default:
+		return fmt.Sprintf("  %s %s", d.Key, d.New)
+	}
+}
+
+// DrawDiff draws the old revision above the new one. Removed placements are
+// highlighted in the former, added ones in the latter and resized or
+// shifted ones in both.
+func DrawDiff(old, new *Definition, out io.Writer) error {
+	return drawDiff(old, new, svg.New(out))
+}
+
+func drawDiff(old, new *Definition, canvas surface) error {
+	oldHighlighted, newHighlighted := highlightDiff(old, new, DiffDefinitions(old, new))
+
+	oldDim := calculateDimensions(oldHighlighted)
+	newDim := calculateDimensions(newHighlighted)
+	width := maxUint(oldDim.Canvas.Width, newDim.Canvas.Width)
+	height := diffCaptionSize + oldDim.Canvas.Height + diffGap + diffCaptionSize + newDim.Canvas.Height
+
+	canvas.Start(int(width), int(height))
+	defineStyles(newHighlighted, newDim, canvas)
+	definePatterns(canvas, newHighlighted, oldHighlighted)
+	canvas.Rect(0, 0, int(width), int(height), "id='background'", fmt.Sprintf("fill='%s'", new.GetBackgroundColor()), "stroke='none'")
+
+	y := uint(0)
+	revisions := []struct {
+		caption string
+		def     *Definition
+		dim     Dimensions
+	}{
+		{"old", oldHighlighted, oldDim},
+		{"new", newHighlighted, newDim},
+	}
+	// each revision is drawn with its own theme
+	for _, rev := range revisions {
+		defineScopedStyles(rev.def, rev.dim, getDiffScope(rev.caption), canvas)
+	}
+
+	for _, rev := range revisions {
+		canvas.Text(5, int(y+diffCaptionSize*3/4), rev.caption, `class="x-bit-title"`)
+		y += diffCaptionSize
+		canvas.Gtransform(fmt.Sprintf("translate(0,%d)", y))
+		canvas.Gid(getDiffScope(rev.caption))
+		drawDiagram(rev.def, rev.dim, canvas)
+		canvas.Gend()
+		canvas.Gend()
+		y += rev.dim.Canvas.Height + diffGap
+	}
+
+	canvas.End()
+	return nil
+}
+
+func getDiffScope(caption string) string {
+	return "diff-" + caption
+}
+
+// highlightDiff returns copies of the definitions with the fill of changed
+// placements replaced by the color of their change.
+func highlightDiff(old, new *Definition, diffs []PlacementDiff) (*Definition, *Definition) {
+	o := *old
+	o.Placements = append([]Placement(nil), old.Placements...)
+	n := *new
+	n.Placements = append([]Placement(nil), new.Placements...)
+
+	for _, d := range diffs {
+		switch d.Status {
+		case DiffStatusAdded:
+			n.Placements[d.New.Index].Fill = stringp(diffAddedFill)
+		case DiffStatusRemoved:
+			o.Placements[d.Old.Index].Fill = stringp(diffRemovedFill)
+		case DiffStatusChanged:
+			o.Placements[d.Old.Index].Fill = stringp(diffChangedFill)
+			n.Placements[d.New.Index].Fill = stringp(diffChangedFill)
+		}
+	}
+	return &o, &n
+}
@@ -0,0 +1,120 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestDiffDefinitions(t *testing.T) {
+	old := &Definition{
+		Placements: []Placement{
+			{Label: "Type", Bits: uintp(8)},
+			{Label: "Flags", Bits: uintp(8)},
+			{Label: "Length", Bits: uintp(16)},
+		},
+	}
+	new := &Definition{
+		Placements: []Placement{
+			{Label: "Kind", Name: "Type", Bits: uintp(4)},
+			{Label: "Version", Bits: uintp(4)},
+			{Label: "Length", Bits: uintp(16)},
+			{Label: "Value", VariableLength: &VariableLengthPlacementSpec{MaxBits: 64}},
+		},
+	}
+
+	expected := []PlacementDiff{
+		{
+			Key:     "Type",
+			Status:  DiffStatusChanged,
+			Old:     &PlacementLocation{Index: 0, Offset: 0, Bits: 8},
+			New:     &PlacementLocation{Index: 0, Offset: 0, Bits: 4},
+			Resized: true,
+		},
+		{
+			Key:    "Version",
+			Status: DiffStatusAdded,
+			New:    &PlacementLocation{Index: 1, Offset: 4, Bits: 4},
+		},
+		{
+			Key:    "Flags",
+			Status: DiffStatusRemoved,
+			Old:    &PlacementLocation{Index: 1, Offset: 8, Bits: 8},
+		},
+		{
+			Key:     "Length",
+			Status:  DiffStatusChanged,
+			Old:     &PlacementLocation{Index: 2, Offset: 16, Bits: 16},
+			New:     &PlacementLocation{Index: 2, Offset: 8, Bits: 16},
+			Shifted: true,
+		},
+		{
+			Key:    "Value",
+			Status: DiffStatusAdded,
+			New:    &PlacementLocation{Index: 3, Offset: 24, Bits: 64},
+		},
+	}
+
+	assert.Equal(t, expected, DiffDefinitions(old, new))
+}
+
+func TestDrawDiff(t *testing.T) {
+	t.Parallel()
+	old := &Definition{
+		Theme: &ThemeSpec{Text: &TextSpec{Color: stringp("navy")}},
+		Placements: []Placement{
+			{Label: "Type", Bits: uintp(8)},
+			{Label: "Flags", Bits: uintp(8)},
+		},
+	}
+	new := &Definition{
+		Placements: []Placement{
+			{Label: "Type", Bits: uintp(8)},
+			{Label: "Value", Bits: uintp(16)},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := DrawDiff(old, new, &buf)
+	assert.NoError(t, err)
+	svg := buf.String()
+	assert.Contains(t, svg, `#diff-old text.placement{fill:navy;`)
+	assert.Contains(t, svg, `#diff-new text.placement{fill:black;`)
+
+	i := strings.Index(svg, `<g id="diff-old">`)
+	j := strings.Index(svg, `<g id="diff-new">`)
+	assert.True(t, i >= 0 && j > i, "the old revision is drawn above the new one")
+	assert.Contains(t, svg[i:j], `style="fill:`+diffRemovedFill+`"`)
+	assert.NotContains(t, svg[i:j], diffAddedFill)
+	assert.Contains(t, svg[j:], `style="fill:`+diffAddedFill+`"`)
+	assert.NotContains(t, svg[j:], diffRemovedFill)
+
+	canvas := newRasterSurface()
+	err = drawDiff(old, new, canvas)
+	assert.NoError(t, err)
+	removed, _ := parseColor(diffRemovedFill)
+	added, _ := parseColor(diffAddedFill)
+	b := canvas.img.Bounds()
+	middle := diffCaptionSize + calculateDimensions(old).Canvas.Height
+	count := func(c color.Color, top, bottom int) int {
+		n := 0
+		for y := top; y < bottom; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if canvas.img.At(x, y) == c {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	assert.True(t, count(removed, 0, int(middle)) > 0)
+	assert.Equal(t, 0, count(removed, int(middle), b.Max.Y))
+	assert.True(t, count(added, int(middle), b.Max.Y) > 0)
+	assert.Equal(t, 0, count(added, 0, int(middle)))
+	navy, _ := parseColor("navy")
+	assert.True(t, count(navy, 0, int(middle)) > 0, "the old revision keeps its theme")
+	assert.Equal(t, 0, count(navy, int(middle), b.Max.Y))
+}
@@ -2,6 +2,7 @@ package packetdiagram
 
 type Dimensions struct {
 	Canvas Dimension
+	Header Dimension
 	XAxis  Dimension
 	YAxis  Dimension
 	Cell   Dimension
@@ -17,11 +18,16 @@ func calculateDimensions(def *Definition) Dimensions {
 	yAxisWidth, yAxisHeight := calculateYAxisDimensions(def)
 	placementWidth := def.GetCellWidth()*def.GetBitsPerLine() + (def.GetBreakMarkWidth() / 2)
 	placementHeight := def.GetCellHeight() * def.GetTotalRows()
+	headerHeight := calculateHeaderHeight(def)
 
 	return Dimensions{
 		Canvas: Dimension{
 			Width:  yAxisWidth + placementWidth,
-			Height: xAxisHeight + placementHeight,
+			Height: headerHeight + xAxisHeight + placementHeight,
+		},
+		Header: Dimension{
+			Width:  yAxisWidth + placementWidth,
+			Height: headerHeight,
 		},
 		XAxis: Dimension{
 			Width:  xAxisWidth,
@@ -65,3 +71,12 @@ func calculateYAxisDimensions(def *Definition) (w, h uint) {
 
 	return
 }
+
+// calculateHeaderHeight gives room above the axes for the byte and bit order
+// when the definition sets them.
+func calculateHeaderHeight(def *Definition) uint {
+	if getOrderCaption(def) == "" {
+		return 0
+	}
+	return defaultHeaderHeight
+}
@@ -8,25 +8,58 @@ import (
 	svg "github.com/ajstarks/svgo"
 )
 
+// surface is the set of drawing primitives a diagram is rendered with.
+// *svg.SVG satisfies it as is; other backends emulate the same calls.
+type surface interface {
+	Start(w int, h int, ns ...string)
+	Style(scriptype string, data ...string)
+	Rect(x int, y int, w int, h int, s ...string)
+	Line(x1 int, y1 int, x2 int, y2 int, s ...string)
+	Text(x int, y int, t string, s ...string)
+	Polygon(x []int, y []int, s ...string)
+	Bezier(sx int, sy int, cx int, cy int, px int, py int, ex int, ey int, s ...string)
+	Def()
+	DefEnd()
+	Pattern(id string, x int, y int, w int, h int, putype string, s ...string)
+	PatternEnd()
+	Gtransform(s string)
+	Gid(s string)
+	Gend()
+	End()
+}
+
 func Draw(def *Definition, out io.Writer) error {
-	canvas := svg.New(out)
+	return draw(def, svg.New(out))
+}
+
+func draw(def *Definition, canvas surface) error {
 	dim := calculateDimensions(def)
 	canvas.Start(int(dim.Canvas.Width), int(dim.Canvas.Height))
 	defineStyles(def, dim, canvas)
+	definePatterns(canvas, def)
 
 	drawBackground(def, dim, canvas)
+	drawDiagram(def, dim, canvas)
+	canvas.End()
+	return nil
+}
+
+func drawDiagram(def *Definition, dim Dimensions, canvas surface) {
+	if dim.Header.Height > 0 {
+		canvas.Text(5, int(dim.Header.Height*3/4), getOrderCaption(def), `class="header"`)
+		canvas.Gtransform(fmt.Sprintf("translate(0,%d)", dim.Header.Height))
+		defer canvas.Gend()
+	}
 	drawXAxis(def, dim, canvas)
 	drawYAxis(def, dim, canvas)
 	drawPlacements(def, dim, canvas)
-	canvas.End()
-	return nil
 }
 
-func drawBackground(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func drawBackground(def *Definition, dim Dimensions, canvas surface) {
 	canvas.Rect(0, 0, int(dim.Canvas.Width), int(dim.Canvas.Height), "id='background'", fmt.Sprintf("fill='%s'", def.GetBackgroundColor()), "stroke='none'")
 }
 
-func drawXAxis(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func drawXAxis(def *Definition, dim Dimensions, canvas surface) {
 	if def.ShouldShowXAxisOctets() {
 		drawXAxisOctets(def, dim, canvas)
 	}
@@ -35,7 +68,7 @@ func drawXAxis(def *Definition, dim Dimensions, canvas *svg.SVG) {
 	}
 }
 
-func drawXAxisBits(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func drawXAxisBits(def *Definition, dim Dimensions, canvas surface) {
 	xs, ys, labels := calculateXAxisBitLabelDimensions(def, dim)
 	h := int(def.GetXAxisBitsHeight())
 	cw := int(dim.Cell.Width)
@@ -58,31 +91,48 @@ func calculateXAxisBitLabelDimensions(def *Definition, dim Dimensions) (xs []int
 
 	h := int(def.GetXAxisBitsHeight())
 	cw := int(dim.Cell.Width)
-	o := int(def.GetXAxisBitsOrigin())
-	u := int(def.GetXAxisBitsUnit())
 	startX := int(dim.YAxis.Width)
 
 	xs = make([]int, count)
 	ys = make([]int, count)
-	labels = make([]string, count)
+	labels = getXAxisBitLabels(def)
 
 	for i := 0; i < count; i++ {
 		xs[i] = startX + (i * cw)
 		ys[i] = int(dim.XAxis.Height) - h
+	}
+	return
+}
+
+func getXAxisBitLabels(def *Definition) []string {
+	count := int(def.GetOctetsPerLine() * 8)
+	o := int(def.GetXAxisBitsOrigin())
+	u := int(def.GetXAxisBitsUnit())
+
+	format := def.GetXAxisBitsFormat()
+
+	labels := make([]string, count)
+	for i := 0; i < count; i++ {
+		if def.IsRegisterMode() {
+			// registers number their bits MSB-first within the word
+			labels[i] = formatAxisLabel(uint(count-1-i), 1, format)
+			continue
+		}
 		if def.GetXAxisBitsDirection() == XAxisBitsDirectionLeftToRight {
-			labels[i] = fmt.Sprintf("%d", o+(i%u))
+			labels[i] = formatAxisLabel(uint(o+(i%u)), 1, format)
 		} else {
-			labels[i] = fmt.Sprintf("%d", o+(u-(i%u)))
+			labels[i] = formatAxisLabel(uint(o+u-1-(i%u)), 1, format)
 		}
 	}
-	return
+	return labels
 }
 
-func drawXAxisOctets(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func drawXAxisOctets(def *Definition, dim Dimensions, canvas surface) {
 	xs, ys, labels := calculateXAxisOctetLabelDimensions(def, dim)
+	unit := int(def.GetXAxisOctetsUnitBits())
 	h := int(def.GetXAxisBitsHeight())
 	cw := int(dim.Cell.Width)
-	lox := int(cw * 4)                       // label offset x
+	lox := int(cw * unit / 2)                // label offset x
 	loy := int(def.GetXAxisBitsHeight()) / 2 // label offset y
 	for i := range xs {
 		canvas.Line(xs[i], ys[i], xs[i], ys[i]+h, `class="x-octet"`)
@@ -92,12 +142,13 @@ func drawXAxisOctets(def *Definition, dim Dimensions, canvas *svg.SVG) {
 	last := len(xs) - 1
 	xlast := xs[last]
 	ylast := ys[last]
-	canvas.Line(xlast+(cw*8), ylast, xlast+(cw*8), ylast+h, `class="x-octet"`)
-	canvas.Text(xs[0]+5, ys[0]+int(def.GetAxisTitleTextSizeInPixels())+3, "octet", `class="x-octet-title"`)
+	canvas.Line(xlast+(cw*unit), ylast, xlast+(cw*unit), ylast+h, `class="x-octet"`)
+	canvas.Text(xs[0]+5, ys[0]+int(def.GetAxisTitleTextSizeInPixels())+3, getOctetsTitle(uint(unit)), `class="x-octet-title"`)
 }
 
 func calculateXAxisOctetLabelDimensions(def *Definition, dim Dimensions) (xs []int, ys []int, labels []string) {
-	count := int(def.GetOctetsPerLine())
+	unit := def.GetXAxisOctetsUnitBits()
+	count := int(def.GetBitsPerLine() / unit)
 
 	cw := int(dim.Cell.Width)
 
@@ -107,14 +158,14 @@ func calculateXAxisOctetLabelDimensions(def *Definition, dim Dimensions) (xs []i
 
 	startX := int(dim.YAxis.Width)
 	for i := 0; i < count; i++ {
-		xs[i] = startX + (i * cw * 8)
+		xs[i] = startX + (i * cw * int(unit))
 		ys[i] = 0
-		labels[i] = fmt.Sprintf("%d", i)
+		labels[i] = formatAxisLabel(uint(i), unit, def.GetXAxisOctetsFormat())
 	}
 	return
 }
 
-func drawYAxis(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func drawYAxis(def *Definition, dim Dimensions, canvas surface) {
 	if def.ShouldShowYAxisOctets() {
 		drawYAxisOctets(def, dim, canvas)
 	}
@@ -123,7 +174,7 @@ func drawYAxis(def *Definition, dim Dimensions, canvas *svg.SVG) {
 	}
 }
 
-func drawYAxisBits(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func drawYAxisBits(def *Definition, dim Dimensions, canvas surface) {
 	xs, ys, labels := calculateYAxisBitLabelDimensions(def, dim)
 	w := int(def.GetYAxisBitsWidth())
 
@@ -149,68 +200,31 @@ func calculateYAxisBitLabelDimensions(def *Definition, dim Dimensions) (xs, ys [
 	}
 	offsetY := int(dim.XAxis.Height)
 	ch := dim.Cell.Height
+	format := def.GetYAxisBitsFormat()
 	xs = make([]int, 0)
 	ys = make([]int, 0)
 	labels = make([]string, 0)
 
-	totalBit := def.GetYAxisBitsOrigin()
-	xs = append(xs, offsetX)
-	ys = append(ys, offsetY)
-	labels = append(labels, fmt.Sprintf("%d", totalBit))
-
-	currBit := uint(0)
-	currRow := uint(0)
-	for _, p := range def.Placements {
-		if p.VariableLength == nil {
-			totalBit += *p.Bits
-			currBit += *p.Bits
-			for currBit >= def.GetBitsPerLine() {
-				currRow++
-				xs = append(xs, offsetX)
-				ys = append(ys, offsetY+int(ch*currRow))
-				labels = append(labels, fmt.Sprintf("%d", totalBit))
-
-				currBit = currBit - def.GetBitsPerLine()
-			}
-		} else {
-			totalBit += p.VariableLength.MaxBits
-			currBit += p.VariableLength.MaxBits
-			for currBit > def.GetBitsPerLine() {
-				currBit = currBit - def.GetBitsPerLine()
-			}
-
-			currRow++
-			xs = append(xs, offsetX)
-			ys = append(ys, offsetY+int(ch*currRow))
+	for i, r := range def.getLayout().rows {
+		xs = append(xs, offsetX)
+		ys = append(ys, offsetY+int(ch*uint(i)))
+		if r.elided {
 			labels = append(labels, "︙")
-
-			currRow++
-			xs = append(xs, offsetX)
-			ys = append(ys, offsetY+int(ch*currRow))
-			labels = append(labels, fmt.Sprintf("%d", totalBit-def.GetBitsPerLine()))
-
-			currRow++
-			xs = append(xs, offsetX)
-			ys = append(ys, offsetY+int(ch*currRow))
-			labels = append(labels, fmt.Sprintf("%d", totalBit))
+			continue
 		}
-	}
-	if currBit == 0 {
-		xs = xs[0 : len(xs)-1]
-		ys = ys[0 : len(ys)-1]
-		labels = labels[0 : len(labels)-1]
+		labels = append(labels, formatAxisLabel(def.GetYAxisBitsOrigin()+r.offset, 1, format))
 	}
 	return
 }
 
-func drawYAxisOctets(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func drawYAxisOctets(def *Definition, dim Dimensions, canvas surface) {
 	xs, ys, labels := calculateYAxisOctetLabelDimensions(def, dim)
 
 	w := int(def.GetYAxisOctetsWidth())
 	lox := int(def.GetYAxisOctetsWidth()) - 5
 	loy := int(dim.Cell.Height * 3 / 4)
 
-	canvas.Text(xs[0]+lox, ys[0]+int(def.GetAxisTitleTextSizeInPixels()+3), "octet", `class="y-octet-title"`)
+	canvas.Text(xs[0]+lox, ys[0]+int(def.GetAxisTitleTextSizeInPixels()+3), getOctetsTitle(def.GetYAxisOctetsUnitBits()), `class="y-octet-title"`)
 
 	for i := range xs {
 		canvas.Line(xs[i], ys[i], xs[i]+w, ys[i], `class="y-bit"`)
@@ -230,44 +244,16 @@ func calculateYAxisOctetLabelDimensions(def *Definition, dim Dimensions) (xs, ys
 	ys = make([]int, 0)
 	labels = make([]string, 0)
 
-	currOctet := def.GetYAxisOctetsOrigin()
-	currBit := uint(0)
-	currRow := uint(0)
-	for _, p := range def.Placements {
-		if p.VariableLength == nil {
-			currBit += *p.Bits
-			for currBit >= def.GetBitsPerLine() {
-				xs = append(xs, offsetX)
-				ys = append(ys, offsetY+int(ch*currRow))
-				labels = append(labels, fmt.Sprintf("%d", currOctet))
-
-				currBit = currBit - def.GetBitsPerLine()
-				currOctet += def.GetOctetsPerLine()
-				currRow++
-			}
-		} else {
-			xs = append(xs, offsetX)
-			ys = append(ys, offsetY+int(ch*currRow))
-			labels = append(labels, fmt.Sprintf("%d", currOctet))
-
-			currBit += p.VariableLength.MaxBits
-			for currBit > def.GetBitsPerLine() {
-				currBit = currBit - def.GetBitsPerLine()
-				currOctet += def.GetOctetsPerLine()
-			}
-
-			currRow++
-			xs = append(xs, offsetX)
-			ys = append(ys, offsetY+int(ch*currRow))
+	for i, r := range def.getLayout().rows {
+		xs = append(xs, offsetX)
+		ys = append(ys, offsetY+int(ch*uint(i)))
+		if r.elided {
 			labels = append(labels, "︙")
-
-			currRow++
-			xs = append(xs, offsetX)
-			ys = append(ys, offsetY+int(ch*currRow))
-			labels = append(labels, fmt.Sprintf("%d", currOctet))
+			continue
 		}
+		// from the origin, which the labels add
+		labels = append(labels, def.formatYAxisOctetLabel(r.offset/8))
 	}
-
 	return
 }
 
@@ -276,109 +262,121 @@ type Cursor struct {
 	y uint
 }
 
-func drawPlacements(def *Definition, dim Dimensions, canvas *svg.SVG) {
-	cur := &Cursor{x: 0, y: 0}
-
+func drawPlacements(def *Definition, dim Dimensions, canvas surface) {
+	layout := def.getLayout()
 	for i, p := range def.Placements {
-		drawPlacement(def, dim, cur, p, i, canvas)
+		drawPlacement(def, dim, layout.placements[i], p, i, canvas)
 	}
 }
 
-func drawPlacement(def *Definition, dim Dimensions, cur *Cursor, p Placement, index int, canvas *svg.SVG) {
+func drawPlacement(def *Definition, dim Dimensions, l placementLayout, p Placement, index int, canvas surface) {
 	log.Printf("placement == %v\n", p)
-	polygons := getPlacementPolygons(def, dim, cur, p)
+	offset := l.offset
+	polygons := getPlacementPolygons(def, dim, l)
 	if len(polygons) == 0 {
 		return
 	}
 
 	for _, polygon := range polygons {
 		style := ""
-		if p.Fill != nil {
-			log.Printf("fill == %s", *p.Fill)
-			style = fmt.Sprintf(`style="fill:%s"`, *p.Fill)
+		fill, pattern := def.getPlacementFill(p)
+		if pattern != FillPatternNone {
+			style = fmt.Sprintf(`style="fill:url(#%s)"`, getPatternID(pattern, fill))
+		} else if fill != nil {
+			log.Printf("fill == %s", *fill)
+			style = fmt.Sprintf(`style="fill:%s"`, *fill)
 		}
 		canvas.Polygon(polygon.xs, polygon.ys, `class="placement"`, style)
-		if p.VariableLength != nil {
-			drawBreakMark(def, polygon, canvas)
+	}
+	if p.VariableLength != nil {
+		drawBreakMarks(def, dim, l, polygons, canvas)
+	}
+
+	for _, polygon := range polygons {
+		if def.IsRegisterMode() {
+			drawRegisterFieldText(def, dim, p, offset, polygon, canvas)
+			continue
 		}
 		drawPlacementText(def, dim, p, polygon, canvas)
 	}
+	if def.ShouldShowOffsets() {
+		first, last := def.getOffsetLabels(offset, p.GetBits(), p.VariableLength != nil)
+		drawPlacementOffsets(def, first, last, polygons, canvas)
+	}
 }
 
-func drawBreakMark(def *Definition, polygon Polygon, canvas *svg.SVG) {
-	left, top, right, bottom := polygon.findBoundingBox()
-
-	sx, sy, cx, cy, px, py, ex, ey := getBreakMarkPoints(def, left, (top+bottom)/2-3)
-	canvas.Bezier(sx, sy, cx, cy, px, py, ex, ey, `class="breakmark"`)
-	sx, sy, cx, cy, px, py, ex, ey = getBreakMarkPoints(def, left, (top+bottom)/2+3)
-	canvas.Bezier(sx, sy, cx, cy, px, py, ex, ey, `class="breakmark"`)
-
-	sx, sy, cx, cy, px, py, ex, ey = getBreakMarkPoints(def, right, (top+bottom)/2-3)
-	canvas.Bezier(sx, sy, cx, cy, px, py, ex, ey, `class="breakmark"`)
-	sx, sy, cx, cy, px, py, ex, ey = getBreakMarkPoints(def, right, (top+bottom)/2+3)
-	canvas.Bezier(sx, sy, cx, cy, px, py, ex, ey, `class="breakmark"`)
-}
-
-func getBreakMarkPoints(def *Definition, x, y uint) (sx, sy, cx, cy, px, py, ex, ey int) {
-	w := def.GetBreakMarkWidth() / 2
-	sx = int(x - w)
-	cx = int(x)
-	px = int(x)
-	ex = int(x + w)
-
-	h := def.GetBreakMarkHeight() / 2
-	sy = int(y)
-	cy = int(y - h)
-	py = int(y + h)
-	ey = int(y)
-	return
-}
-
-func drawPlacementText(def *Definition, dim Dimensions, p Placement, polygon Polygon, canvas *svg.SVG) {
+func drawPlacementText(def *Definition, dim Dimensions, p Placement, polygon Polygon, canvas surface) {
 	left, top, right, bottom := polygon.findBoundingBox()
 	canvas.Text(int(left+right)/2, (int(top+bottom)/2)+(int(dim.Cell.Height)/6), p.Label, `class="placement"`)
 }
 
-func getPlacementPolygons(def *Definition, dim Dimensions, cur *Cursor, p Placement) []Polygon {
-	bitsPerLine := def.GetBitsPerLine()
-	bitDistributions := getBitDistributions(bitsPerLine, cur, p)
-	defer advanceCursor(def, bitDistributions, cur)
-
-	lb := len(bitDistributions)
-	if lb == 1 {
-		polygon := getSingleRectPlacement(def, dim, cur, p)
-		return []Polygon{polygon}
+// getPlacementPolygons outlines the segments of a placement: a rectangle
+// when it is on a single row, two when its two rows do not overlap and a
+// single polygon otherwise.
+func getPlacementPolygons(def *Definition, dim Dimensions, l placementLayout) []Polygon {
+	if len(l.segments) == 0 {
+		return []Polygon{}
 	}
 
-	if lb == 2 && *p.Bits <= def.GetBitsPerLine() {
-		return getSeparatedTwoLinesPlacement(def, dim, cur, p, bitDistributions)
+	cw := dim.Cell.Width
+	ch := dim.Cell.Height
+	x := func(bit uint) uint { return dim.YAxis.Width + bit*cw }
+	y := func(row uint) uint { return dim.XAxis.Height + row*ch }
+
+	first := l.segments[0]
+	last := l.segments[len(l.segments)-1]
+	end := last.x + last.bits
+	if len(l.segments) == 1 {
+		return []Polygon{createRectPolygon(x(first.x), y(first.row), x(end), y(first.row+1))}
 	}
 
-	polygon := getMultipleLinesPlacement(def, dim, cur, p, bitDistributions)
-	return []Polygon{polygon}
-}
-
-func advanceCursor(def *Definition, bitDistributions []uint, cur *Cursor) {
-	bitsPerLine := def.GetBitsPerLine()
-	for _, bd := range bitDistributions {
-		cur.x += bd
-		if cur.x == bitsPerLine {
-			cur.x = 0
-			cur.y += 1
+	/*
+		          ┌──────────┐
+		          │    (1)   │
+		┌───────┐ └──────────┘
+		│  (2)  │
+		└───────┘
+	*/
+	if len(l.segments) == 2 && end <= first.x {
+		return []Polygon{
+			createRectPolygon(x(first.x), y(first.row), x(first.x+first.bits), y(first.row+1)),
+			createRectPolygon(x(last.x), y(last.row), x(end), y(last.row+1)),
 		}
 	}
-}
 
-func getBitDistributions(bitsPerLine uint, cur *Cursor, p Placement) []uint {
-	bitsToGo := uint(0)
-	if p.VariableLength == nil {
-		bitsToGo = *p.Bits
+	/*
+		      ┌───────────┐
+		      │           │
+		┌─────┘           │
+		│                 │
+		│           ┌─────┘
+		│           │
+		└───────────┘
+	*/
+	right := def.GetBitsPerLine()
+	xs := []uint{x(first.x), x(right)}
+	ys := []uint{y(first.row), y(first.row)}
+	if end < right {
+		xs = append(xs, x(right), x(end), x(end))
+		ys = append(ys, y(last.row), y(last.row), y(last.row+1))
 	} else {
-		bitsToGo = p.VariableLength.MaxBits
-		if bitsToGo > bitsPerLine*3 {
-			bitsToGo = bitsPerLine * 3
-		}
+		xs = append(xs, x(right))
+		ys = append(ys, y(last.row+1))
 	}
+	xs = append(xs, x(0))
+	ys = append(ys, y(last.row+1))
+	if first.x > 0 {
+		xs = append(xs, x(0), x(first.x))
+		ys = append(ys, y(first.row+1), y(first.row+1))
+	}
+	xs = append(xs, x(first.x))
+	ys = append(ys, y(first.row))
+
+	return []Polygon{NewPolygon(xs, ys)}
+}
+
+func getBitDistributions(bitsPerLine uint, cur *Cursor, p Placement) []uint {
+	bitsToGo := p.GetBits()
 	availableInCurrentLine := bitsPerLine - cur.x
 
 	if availableInCurrentLine >= bitsToGo {
@@ -409,14 +407,6 @@ func availableBitsInCurrentLine(def *Definition, cur *Cursor) uint {
 	return def.GetBitsPerLine() - cur.x
 }
 
-func getSingleRectPlacement(def *Definition, dim Dimensions, cur *Cursor, p Placement) Polygon {
-	left := dim.YAxis.Width + (cur.x * dim.Cell.Width)
-	right := left + *p.Bits*dim.Cell.Width
-	top := dim.XAxis.Height + (cur.y * dim.Cell.Height)
-	bottom := top + dim.Cell.Height
-	return createRectPolygon(left, top, right, bottom)
-}
-
 func createRectPolygon(left, top, right, bottom uint) Polygon {
 	xs := make([]uint, 5)
 	xs[0] = left
@@ -434,107 +424,3 @@ func createRectPolygon(left, top, right, bottom uint) Polygon {
 
 	return NewPolygon(xs, ys)
 }
-
-func doesFitInTwoLines(def *Definition, cur *Cursor, p Placement) bool {
-	return *p.Bits-availableBitsInCurrentLine(def, cur) < def.GetBitsPerLine()
-}
-
-func getSeparatedTwoLinesPlacement(def *Definition, dim Dimensions, cur *Cursor, p Placement, bitDistributions []uint) []Polygon {
-	cw := dim.Cell.Width
-	ch := dim.Cell.Height
-	/*
-		          ┌──────────┐
-		          │    (1)   │
-		┌───────┐ └──────────┘
-		│  (2)  │
-		└───────┘
-	*/
-	left1 := dim.YAxis.Width + (cur.x * cw)
-	right1 := left1 + bitDistributions[0]*cw
-	top1 := dim.XAxis.Height + (cur.y * ch)
-	bottom1 := top1 + ch
-	p1 := createRectPolygon(left1, top1, right1, bottom1)
-
-	left2 := dim.YAxis.Width
-	right2 := left2 + bitDistributions[1]*cw
-	top2 := dim.XAxis.Height + ((cur.y + 1) * ch)
-	bottom2 := top2 + ch
-	p2 := createRectPolygon(left2, top2, right2, bottom2)
-
-	return []Polygon{p1, p2}
-}
-
-func getMultipleLinesPlacement(def *Definition, dim Dimensions, cur *Cursor, p Placement, bitDistributions []uint) Polygon {
-	cw := dim.Cell.Width
-	ch := dim.Cell.Height
-	/*
-		pattern 1:
-		      ┌───────────┐
-		      │           │
-		┌─────┘           │
-		│                 │
-		│                 │
-		│                 │
-		└─────────────────┘
-	*/
-	if bitDistributions[1] == def.GetBitsPerLine() {
-		startX := dim.YAxis.Width + (cur.x * cw)
-		startY := dim.XAxis.Height + (cur.y * ch)
-		xs := make([]uint, 7)
-		xs[0] = startX
-		xs[1] = xs[0] + (bitDistributions[0] * cw)
-		xs[2] = xs[1]
-		xs[3] = dim.YAxis.Width
-		xs[4] = xs[3]
-		xs[5] = xs[0]
-		xs[6] = xs[0]
-
-		ys := make([]uint, 7)
-		ys[0] = startY
-		ys[1] = ys[0]
-		ys[2] = ys[0] + (ch * uint(len(bitDistributions)))
-		ys[3] = ys[2]
-		ys[4] = ys[0] + ch
-		ys[5] = ys[4]
-		ys[6] = ys[0]
-
-		return NewPolygon(xs, ys)
-	}
-
-	/*
-		pattern 2:
-		      ┌───────────┐
-		      │           │
-		┌─────┘           │
-		│                 │
-		│                 │
-		│           ┌─────┘
-		│           │
-		└───────────┘
-	*/
-	startX := dim.YAxis.Width + (cur.x * cw)
-	startY := dim.XAxis.Height + (cur.y * ch)
-	xs := make([]uint, 9)
-	xs[0] = startX
-	xs[1] = xs[0] + (bitDistributions[0] * cw)
-	xs[2] = xs[1]
-	xs[3] = dim.YAxis.Width + (bitDistributions[1] * cw)
-	xs[4] = xs[3]
-	xs[5] = dim.YAxis.Width
-	xs[6] = xs[5]
-	xs[7] = xs[0]
-	xs[8] = xs[0]
-
-	ys := make([]uint, 9)
-	ys[0] = startY
-	ys[1] = ys[0]
-	ys[2] = ys[0] + (uint(len(bitDistributions)-1) * ch)
-	ys[3] = ys[2]
-	ys[4] = ys[3] + ch
-	ys[5] = ys[4]
-	ys[6] = ys[0] + ch
-	ys[7] = ys[6]
-	ys[8] = ys[0]
-
-	return NewPolygon(xs, ys)
-}
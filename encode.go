@@ -0,0 +1,286 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const encodeIndent = 2
+
+// Encode writes the definition as YAML with keys in canonical order.
+func (d *Definition) Encode(w io.Writer) error {
+	enc := yamlv3.NewEncoder(w)
+	enc.SetIndent(encodeIndent)
+	err := enc.Encode(d)
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// EncodeDefinitions writes definitions as a canonical YAML stream, one
+// document each.
+func EncodeDefinitions(defs []*Definition, w io.Writer) error {
+	var buf bytes.Buffer
+	for i, def := range defs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		err := def.Encode(&buf)
+		if err != nil {
+			return err
+		}
+	}
+	return FormatDefinition(&buf, w)
+}
+
+// FormatDefinition rewrites a YAML definition file in canonical form: keys
+// are spelled the way LoadDefinitions expects them and ordered as in
+// Definition, and indentation is normalized. Unlike decoding and encoding
+// the definition, comments and keys unknown to Definition are kept.
+func FormatDefinition(r io.Reader, w io.Writer) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	dec := yamlv3.NewDecoder(bytes.NewReader(b))
+	for i := 0; ; i++ {
+		var doc yamlv3.Node
+		err = dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = canonicalizeNode(&doc, reflect.TypeOf(definitionFile{}))
+		if err != nil {
+			return err
+		}
+
+		encoded, err := encodeCanonicalDocument(&doc)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(encoded)
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+
+	// make sure the result still loads, leaving imports to the files that
+	// are imported
+	imp := newImporter()
+	imp.skip = true
+	_, err = imp.loadDefinitions(out.Bytes(), ".")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// encodeCanonicalDocument encodes the top-level keys one by one so that they
+// can be separated by blank lines, which the YAML encoder does not keep.
+func encodeCanonicalDocument(doc *yamlv3.Node) ([]byte, error) {
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return encodeNode(doc)
+	}
+
+	var buf bytes.Buffer
+	if doc.HeadComment != "" {
+		buf.WriteString(doc.HeadComment + "\n\n")
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		section := &yamlv3.Node{
+			Kind:    yamlv3.MappingNode,
+			Tag:     "!!map",
+			Content: root.Content[i : i+2],
+		}
+		b, err := encodeNode(section)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	if doc.FootComment != "" {
+		buf.WriteString("\n" + doc.FootComment + "\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeNode(node *yamlv3.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(encodeIndent)
+	err := enc.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type canonicalField struct {
+	name  string
+	index int
+	typ   reflect.Type
+}
+
+// canonicalizeNode walks node alongside the Go type it decodes into,
+// renaming keys to their canonical spelling and sorting them in field order.
+func canonicalizeNode(node *yamlv3.Node, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// block style throughout; quoting is left alone as LoadDefinition reads
+	// YAML 1.1, where more plain scalars are booleans than the encoder knows
+	node.Style &^= yamlv3.FlowStyle
+
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, c := range node.Content {
+			err := canonicalizeNode(c, t)
+			if err != nil {
+				return err
+			}
+		}
+	case yamlv3.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return nil
+		}
+		for _, c := range node.Content {
+			err := canonicalizeNode(c, t.Elem())
+			if err != nil {
+				return err
+			}
+		}
+	case yamlv3.MappingNode:
+		if t == reflect.TypeOf(namedDefinitions{}) {
+			for i := 1; i < len(node.Content); i += 2 {
+				err := canonicalizeNode(node.Content[i], reflect.TypeOf(Definition{}))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if t.Kind() == reflect.Map {
+			for i := 1; i < len(node.Content); i += 2 {
+				err := canonicalizeNode(node.Content[i], t.Elem())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		return canonicalizeMapping(node, t)
+	}
+
+	return nil
+}
+
+func canonicalizeMapping(node *yamlv3.Node, t reflect.Type) error {
+	fields := map[string]canonicalField{}
+	for i, f := range yamlFields(t) {
+		fields[normalizeKey(f.name)] = canonicalField{name: f.name, index: i, typ: f.typ}
+	}
+	for alias, name := range keyAliases {
+		if f, ok := fields[normalizeKey(name)]; ok {
+			fields[normalizeKey(alias)] = f
+		}
+	}
+
+	type pair struct {
+		key   *yamlv3.Node
+		value *yamlv3.Node
+		order int
+	}
+	pairs := make([]pair, 0, len(node.Content)/2)
+	seen := map[string]int{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		order := len(node.Content) + i // unknown keys go last, as they came
+
+		if f, ok := fields[normalizeKey(key.Value)]; ok {
+			if line, dup := seen[f.name]; dup {
+				return errors.Errorf("line %d: `%s` is already given on line %d", key.Line, key.Value, line)
+			}
+			seen[f.name] = key.Line
+
+			key.Value = f.name
+			order = f.index
+			err := canonicalizeNode(value, f.typ)
+			if err != nil {
+				return err
+			}
+		}
+		pairs = append(pairs, pair{key: key, value: value, order: order})
+	}
+
+	// the comment above the first key heads the mapping, wherever it goes
+	var head string
+	if len(pairs) > 0 {
+		head, pairs[0].key.HeadComment = pairs[0].key.HeadComment, ""
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].order < pairs[j].order
+	})
+	if head != "" {
+		if first := pairs[0].key; first.HeadComment != "" {
+			head += "\n" + first.HeadComment
+		}
+		pairs[0].key.HeadComment = head
+	}
+
+	node.Content = node.Content[:0]
+	for _, p := range pairs {
+		node.Content = append(node.Content, p.key, p.value)
+	}
+	return nil
+}
+
+// keyAliases lists misspellings that are too far from the canonical key for
+// normalizeKey to catch.
+var keyAliases = map[string]string{
+	"valiable-length": "variable-length",
+	"octets-per-row":  "octets-per-line",
+	"colour":          "color",
+}
+
+// normalizeKey folds the usual spelling variants of a key, e.g.
+// octets-per-line, octets_per_line and octetsPerLine, into one.
+func normalizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, key)
+}
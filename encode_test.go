@@ -0,0 +1,98 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestFormatDefinition(t *testing.T) {
+	testData := []struct {
+		Name     string
+		Source   string
+		Expected string
+	}{
+		{
+			Name: "reorders and respells keys keeping comments",
+			Source: `# TLV
+placements:
+    - bits: 8
+      label: Type   # one octet
+    - label: Value
+      valiable_length: {maxBits: 64}
+octets_per_line: 2
+`,
+			Expected: `# TLV
+octets-per-line: 2
+
+placements:
+  - label: Type # one octet
+    bits: 8
+  - label: Value
+    variable-length:
+      max-bits: 64
+`,
+		},
+		{
+			Name: "keeps the head comment above the comment of the new first key",
+			Source: `# TLV
+placements:
+  - label: Type
+    bits: 8
+# two per line
+octets-per-line: 2
+`,
+			Expected: `# TLV
+# two per line
+octets-per-line: 2
+
+placements:
+  - label: Type
+    bits: 8
+`,
+		},
+		{
+			Name: "keeps quotes YAML 1.1 needs",
+			Source: `placements:
+  - label: 'yes'
+    bits: 1
+`,
+			Expected: `placements:
+  - label: 'yes'
+    bits: 1
+`,
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			err := FormatDefinition(strings.NewReader(data.Source), &buf)
+			assert.NoError(t, err)
+			assert.Equal(t, data.Expected, buf.String())
+		})
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	def := &Definition{
+		OctetsPerLine: uintp(2),
+		Placements: []Placement{
+			{Label: "Type", Bits: uintp(8)},
+			{Label: "Value", VariableLength: &VariableLengthPlacementSpec{MaxBits: 64}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := def.Encode(&buf)
+	assert.NoError(t, err)
+
+	loaded, err := LoadDefinition(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, def, loaded)
+}
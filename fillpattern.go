@@ -0,0 +1,220 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FillPattern is what a placement is filled with, drawn over its fill in the
+// pattern color of the theme.
+type FillPattern string
+
+const (
+	FillPatternNone          FillPattern = "none"           // the fill alone
+	FillPatternSolid         FillPattern = "solid"          // the pattern color all over
+	FillPatternDiagonalHatch FillPattern = "diagonal-hatch" // lines slanting up to the right
+	FillPatternCrossHatch    FillPattern = "cross-hatch"    // lines slanting both ways
+	FillPatternDots          FillPattern = "dots"           // a grid of dots
+)
+
+func (FillPattern) schemaEnum() []string {
+	return []string{
+		string(FillPatternNone),
+		string(FillPatternSolid),
+		string(FillPatternDiagonalHatch),
+		string(FillPatternCrossHatch),
+		string(FillPatternDots),
+	}
+}
+
+func (f FillPattern) isValid() bool {
+	switch f {
+	case FillPatternNone, FillPatternSolid, FillPatternDiagonalHatch, FillPatternCrossHatch, FillPatternDots:
+		return true
+	}
+	return false
+}
+
+// PlacementKind is what the bits of a placement are for, which gives them
+// their look unless they have their own.
+type PlacementKind string
+
+const (
+	PlacementKindData     PlacementKind = "data"
+	PlacementKindReserved PlacementKind = "reserved"
+	PlacementKindPadding  PlacementKind = "padding"
+)
+
+func (PlacementKind) schemaEnum() []string {
+	return []string{
+		string(PlacementKindData),
+		string(PlacementKindReserved),
+		string(PlacementKindPadding),
+	}
+}
+
+const (
+	defaultPlacementKind = PlacementKindData
+	defaultPlacementFill = "white"
+)
+
+// defaultKindThemes hatches reserved bits and greys padding out, as is
+// conventional.
+var defaultKindThemes = map[PlacementKind]KindTheme{
+	PlacementKindData:     {},
+	PlacementKindReserved: {Pattern: fillPatternp(FillPatternDiagonalHatch)},
+	PlacementKindPadding:  {Pattern: fillPatternp(FillPatternSolid)},
+}
+
+func fillPatternp(f FillPattern) *FillPattern {
+	return &f
+}
+
+func (p Placement) GetKind() PlacementKind {
+	if p.Kind == nil {
+		return defaultPlacementKind
+	}
+	return *p.Kind
+}
+
+func (d *Definition) GetPatternColor() string {
+	return d.GetTheme().GetPatternColor()
+}
+
+func (d *Definition) GetPatternSpacing() uint {
+	return d.GetTheme().GetPatternSpacing()
+}
+
+// getPlacementFill returns the fill and pattern of a placement, each its
+// own if it has one and else that of its kind in the theme.
+func (d *Definition) getPlacementFill(p Placement) (*string, FillPattern) {
+	look := d.GetTheme().GetKindTheme(p.GetKind())
+	fill := p.GetFill()
+	if fill == nil {
+		fill = look.Fill
+	}
+
+	pattern := FillPatternNone
+	if p.Pattern != nil {
+		pattern = *p.Pattern
+	} else if look.Pattern != nil {
+		pattern = *look.Pattern
+	}
+	return fill, pattern
+}
+
+func (d *Definition) validateFillPatterns() error {
+	for _, p := range d.Placements {
+		if p.Kind != nil {
+			if _, ok := defaultKindThemes[*p.Kind]; !ok {
+				return errors.Errorf("placement %s: unsupported kind: %s", p.GetKey(), *p.Kind)
+			}
+		}
+		if p.Pattern != nil && !p.Pattern.isValid() {
+			return errors.Errorf("placement %s: unsupported pattern: %s", p.GetKey(), *p.Pattern)
+		}
+	}
+	if d.Theme != nil && d.Theme.Kinds != nil {
+		for _, k := range []*KindTheme{d.Theme.Kinds.Reserved, d.Theme.Kinds.Padding, d.Theme.Kinds.Data} {
+			if k != nil && k.Pattern != nil && !k.Pattern.isValid() {
+				return errors.Errorf("theme: unsupported pattern: %s", *k.Pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// getPatternID names the pattern definition of pattern drawn over fill.
+func getPatternID(pattern FillPattern, fill *string) string {
+	f := defaultPlacementFill
+	if fill != nil {
+		f = *fill
+	}
+	words := strings.FieldsFunc(f, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	return fmt.Sprintf("pattern-%s-%s", pattern, strings.Join(words, "-"))
+}
+
+// definePatterns defines the patterns the placements of defs are filled
+// with, each once, for them to refer to by getPatternID. The first
+// definition gives their color and spacing.
+func definePatterns(canvas surface, defs ...*Definition) {
+	type patternFill struct {
+		pattern FillPattern
+		fill    *string
+	}
+	used := make([]patternFill, 0)
+	seen := map[string]bool{}
+	for _, def := range defs {
+		for _, p := range def.Placements {
+			fill, pattern := def.getPlacementFill(p)
+			id := getPatternID(pattern, fill)
+			if pattern == FillPatternNone || seen[id] {
+				continue
+			}
+			seen[id] = true
+			used = append(used, patternFill{pattern, fill})
+		}
+	}
+	if len(used) == 0 {
+		return
+	}
+
+	color := defs[0].GetPatternColor()
+	s := int(defs[0].GetPatternSpacing())
+	canvas.Def()
+	for _, u := range used {
+		fill := defaultPlacementFill
+		if u.fill != nil {
+			fill = *u.fill
+		}
+		canvas.Pattern(getPatternID(u.pattern, u.fill), 0, 0, s, s, "user")
+		drawPatternTile(u.pattern, fill, color, s, canvas)
+		canvas.PatternEnd()
+	}
+	canvas.DefEnd()
+}
+
+// drawPatternTile draws a tile of pattern s pixels square. Diagonals run
+// on past its corners so that tiles join up without gaps.
+func drawPatternTile(pattern FillPattern, fill, color string, s int, canvas surface) {
+	stroke := fmt.Sprintf("stroke='%s'", color)
+	if pattern == FillPatternSolid {
+		canvas.Rect(0, 0, s, s, fmt.Sprintf("fill='%s'", color), "stroke='none'")
+		return
+	}
+
+	canvas.Rect(0, 0, s, s, fmt.Sprintf("fill='%s'", fill), "stroke='none'")
+	switch pattern {
+	case FillPatternDiagonalHatch, FillPatternCrossHatch:
+		canvas.Line(-1, 1, 1, -1, stroke)
+		canvas.Line(0, s, s, 0, stroke)
+		canvas.Line(s-1, s+1, s+1, s-1, stroke)
+		if pattern == FillPatternCrossHatch {
+			canvas.Line(-1, s-1, 1, s+1, stroke)
+			canvas.Line(0, 0, s, s, stroke)
+			canvas.Line(s-1, -1, s+1, 1, stroke)
+		}
+	case FillPatternDots:
+		canvas.Rect(s/2-1, s/2-1, 2, 2, fmt.Sprintf("fill='%s'", color), "stroke='none'")
+	}
+}
+
+// getTextPatternRune returns what the blank cells of a placement filled
+// with pattern are filled with in plain text, or a space.
+func getTextPatternRune(pattern FillPattern) rune {
+	switch pattern {
+	case FillPatternSolid:
+		return '#'
+	case FillPatternDiagonalHatch:
+		return '/'
+	case FillPatternCrossHatch:
+		return 'x'
+	case FillPatternDots:
+		return '.'
+	}
+	return ' '
+}
@@ -0,0 +1,168 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestGetPlacementFill(t *testing.T) {
+	reserved, padding := PlacementKindReserved, PlacementKindPadding
+	dots, none := FillPatternDots, FillPatternNone
+	testData := []struct {
+		Name            string
+		Theme           *ThemeSpec
+		Placement       Placement
+		ExpectedFill    *string
+		ExpectedPattern FillPattern
+	}{
+		{"data", nil, Placement{Label: "a"}, nil, FillPatternNone},
+		{"reserved", nil, Placement{Label: "a", Kind: &reserved}, nil, FillPatternDiagonalHatch},
+		{"padding", nil, Placement{Label: "a", Kind: &padding}, nil, FillPatternSolid},
+		{"own pattern", nil, Placement{Label: "a", Kind: &reserved, Pattern: &dots}, nil, FillPatternDots},
+		{"own fill", nil, Placement{Label: "a", Kind: &reserved, Fill: stringp("red")}, stringp("red"), FillPatternDiagonalHatch},
+		{
+			"theme",
+			&ThemeSpec{Kinds: &KindsTheme{Reserved: &KindTheme{Fill: stringp("#eee"), Pattern: &none}}},
+			Placement{Label: "a", Kind: &reserved},
+			stringp("#eee"),
+			FillPatternNone,
+		},
+		{
+			"theme leaves the pattern out",
+			&ThemeSpec{Kinds: &KindsTheme{Padding: &KindTheme{Fill: stringp("#eee")}}},
+			Placement{Label: "a", Kind: &padding},
+			stringp("#eee"),
+			FillPatternSolid,
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+			def := &Definition{Theme: data.Theme, Placements: []Placement{data.Placement}}
+			fill, pattern := def.getPlacementFill(data.Placement)
+			assert.Equal(t, data.ExpectedFill, fill)
+			assert.Equal(t, data.ExpectedPattern, pattern)
+		})
+	}
+}
+
+func TestGetPatternID(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "pattern-dots-white", getPatternID(FillPatternDots, nil))
+	assert.Equal(t, "pattern-cross-hatch-ffcc00", getPatternID(FillPatternCrossHatch, stringp("#ffcc00")))
+	assert.Equal(t, "pattern-solid-rgb-1-2-3", getPatternID(FillPatternSolid, stringp("rgb(1, 2, 3)")))
+}
+
+func TestDrawFillPatterns(t *testing.T) {
+	t.Parallel()
+	reserved := PlacementKindReserved
+	dots := FillPatternDots
+	def := &Definition{Placements: []Placement{
+		{Label: "a", Bits: uintp(8)},
+		{Label: "b", Bits: uintp(8), Kind: &reserved},
+		{Label: "c", Bits: uintp(8), Kind: &reserved},
+		{Label: "d", Bits: uintp(8), Pattern: &dots, Fill: stringp("#ffcc00")},
+	}}
+
+	var buf bytes.Buffer
+	err := Draw(def, &buf)
+	assert.NoError(t, err)
+	svg := buf.String()
+	assert.Equal(t, 1, strings.Count(svg, `<pattern id="pattern-diagonal-hatch-white"`))
+	assert.Contains(t, svg, `<pattern id="pattern-dots-ffcc00" x="0" y="0" width="6" height="6" patternUnits="userSpaceOnUse"`)
+	assert.Contains(t, svg, `<rect x="0" y="0" width="6" height="6" fill='#ffcc00' stroke='none' />`)
+	assert.Contains(t, svg, `<rect x="2" y="2" width="2" height="2" fill='silver' stroke='none' />`)
+	assert.Contains(t, svg, `class="placement" style="fill:url(#pattern-diagonal-hatch-white)"`)
+	assert.Contains(t, svg, `class="placement" style="fill:url(#pattern-dots-ffcc00)"`)
+
+	buf.Reset()
+	err = Draw(&Definition{Placements: def.Placements[:1]}, &buf)
+	assert.NoError(t, err)
+	assert.NotContains(t, buf.String(), `<defs>`)
+}
+
+func TestRasterFillPattern(t *testing.T) {
+	t.Parallel()
+	r := newRasterSurface()
+	r.Start(20, 20)
+	r.Def()
+	r.Pattern("p", 0, 0, 4, 4, "user")
+	r.Rect(0, 0, 4, 4, "fill='white'", "stroke='none'")
+	r.Rect(0, 0, 1, 1, "fill='black'", "stroke='none'")
+	r.PatternEnd()
+	r.DefEnd()
+
+	r.Gtransform("translate(2,2)")
+	r.Rect(0, 0, 12, 12, `style="fill:url(#p)"`)
+	r.Gend()
+
+	black := color.RGBA{A: 0xff}
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	assert.Equal(t, black, r.img.At(2, 2))
+	assert.Equal(t, white, r.img.At(3, 2))
+	assert.Equal(t, black, r.img.At(6, 10))
+	assert.Equal(t, color.RGBA{}, r.img.At(1, 1))
+}
+
+func TestDrawTextFillPatterns(t *testing.T) {
+	t.Parallel()
+	reserved, padding := PlacementKindReserved, PlacementKindPadding
+	dots := FillPatternDots
+	def := &Definition{Placements: []Placement{
+		{Label: "Version", Bits: uintp(4)},
+		{Label: "Reserved", Bits: uintp(12), Kind: &reserved},
+		{Label: "Dots", Bits: uintp(16), Pattern: &dots},
+		{Label: "", Bits: uintp(16), Kind: &padding},
+		{Label: "Data", Bits: uintp(16)},
+	}}
+
+	var buf strings.Builder
+	err := DrawText(def, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"|Version|////// Reserved ///////|............ Dots .............|\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"|###############################|             Data              |\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n", buf.String())
+}
+
+func TestValidateFillPatterns(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "unknown kind",
+			Source: "placements:\n  - label: a\n    bits: 8\n    kind: unused\n",
+			Error:  "placement a: unsupported kind: unused",
+		},
+		{
+			Name:   "unknown pattern",
+			Source: "placements:\n  - label: a\n    bits: 8\n    pattern: stripes\n",
+			Error:  "placement a: unsupported pattern: stripes",
+		},
+		{
+			Name:   "unknown theme pattern",
+			Source: "theme:\n  kinds:\n    padding:\n      pattern: stripes\nplacements:\n  - label: a\n    bits: 8\n",
+			Error:  "theme: unsupported pattern: stripes",
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadDefinition(strings.NewReader(data.Source))
+			assert.EqualError(t, err, data.Error)
+		})
+	}
+}
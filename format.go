@@ -0,0 +1,48 @@
+package packetdiagram
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+type Format string
+
+const (
+	FormatSVG  Format = "svg"
+	FormatPNG  Format = "png"
+	FormatText Format = "txt"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatSVG, FormatPNG, FormatText:
+		return f, nil
+	default:
+		return "", errors.Errorf("unsupported format: %s", s)
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatPNG:
+		return "image/png"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	default:
+		return "image/svg+xml"
+	}
+}
+
+func Render(def *Definition, format Format, out io.Writer) error {
+	switch format {
+	case FormatSVG:
+		return Draw(def, out)
+	case FormatPNG:
+		return DrawPNG(def, out)
+	case FormatText:
+		return DrawText(def, out)
+	default:
+		return errors.Errorf("unsupported format: %s", format)
+	}
+}
@@ -3,11 +3,14 @@ module github.com/bitbears-dev/packet-diagram
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/ajstarks/deck/generate v0.0.0-20210309230005-c3f852c02e19 // indirect
 	github.com/ajstarks/svgo v0.0.0-20210406150507-75cfd577ce75
 	github.com/jessevdk/go-flags v1.5.0
-	github.com/pkg/errors v0.9.1 // indirect
-	github.com/tj/assert v0.0.3 // indirect
+	github.com/pkg/errors v0.9.1
+	github.com/tj/assert v0.0.3
+	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 	honnef.co/go/tools v0.2.0 // indirect
 )
@@ -0,0 +1,56 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of generated code")
+
+// goldenDefinitions holds the definitions the generators are tested on,
+// shared by all of them.
+const goldenDefinitions = "testdata/definitions"
+
+// testGolden runs export on the definitions in inputDir that have a golden
+// file in goldenDir, of the same name with extension ext, and compares the
+// output with it, or rewrites the golden files with -update. A generator is
+// given a new definition by adding an empty golden file for it.
+func testGolden(t *testing.T, inputDir, goldenDir, ext string, export func(*Definition, io.Writer) error) {
+	goldens, err := filepath.Glob(filepath.Join(goldenDir, "*"+ext))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, goldens)
+
+	for _, golden := range goldens {
+		golden := golden
+		name := strings.TrimSuffix(filepath.Base(golden), ext) + ".pd"
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			f, err := os.Open(filepath.Join(inputDir, name))
+			assert.NoError(t, err)
+			defer f.Close()
+			def, err := LoadDefinition(f)
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = export(def, &buf)
+			assert.NoError(t, err)
+
+			if *update {
+				err := ioutil.WriteFile(golden, buf.Bytes(), 0644)
+				assert.NoError(t, err)
+				return
+			}
+			expected, err := ioutil.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), buf.String(), "run `go test -update` to regenerate %s", golden)
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package packetdiagram
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	goBitsTag = "bits"
+
+	// the room given to []byte fields without a bits tag: one row
+	defaultGoVariableLengthMaxBits = defaultOctetsPerLine * 8
+)
+
+var goTypeBits = map[string]uint{
+	"bool":    8,
+	"byte":    8,
+	"int8":    8,
+	"uint8":   8,
+	"int16":   16,
+	"uint16":  16,
+	"int32":   32,
+	"uint32":  32,
+	"rune":    32,
+	"float32": 32,
+	"int64":   64,
+	"uint64":  64,
+	"float64": 64,
+}
+
+// ImportGoStruct builds a Definition from the struct type called typeName in
+// a Go source file, or from its only struct type when typeName is empty.
+// Fields take the width of their fixed-size type unless a `bits:"n"` tag
+// says otherwise, []byte fields become variable-length and fields of struct
+// types declared in the same file are expanded in place, labelled with the
+// path to them. Other types declared in the file, such as `type Opcode
+// uint8`, stand for their underlying type. Fields tagged `bits:"-"` are left
+// out.
+func ImportGoStruct(r io.Reader, typeName string) (*Definition, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := map[string]*ast.StructType{}
+	types := map[string]ast.Expr{}
+	names := make([]string, 0)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				structs[ts.Name.Name] = st
+				names = append(names, ts.Name.Name)
+			} else {
+				types[ts.Name.Name] = ts.Type
+			}
+		}
+	}
+
+	if typeName == "" {
+		if len(names) != 1 {
+			return nil, errors.Errorf("the file declares %d struct types (%s); choose one by name", len(names), strings.Join(names, ", "))
+		}
+		typeName = names[0]
+	}
+	if _, ok := structs[typeName]; !ok {
+		return nil, errors.Errorf("no struct type named %s", typeName)
+	}
+
+	i := &goStructImporter{fset: fset, structs: structs, types: types}
+	placements, err := i.importStruct(typeName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &Definition{Name: typeName, Placements: placements}
+	err = def.validate()
+	if err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+type goStructImporter struct {
+	fset    *token.FileSet
+	structs map[string]*ast.StructType
+	// types holds the other types declared in the file, by name
+	types map[string]ast.Expr
+}
+
+func (i *goStructImporter) importStruct(name string, expanding []string) ([]Placement, error) {
+	for _, e := range expanding {
+		if e == name {
+			return nil, errors.Errorf("struct %s contains itself", name)
+		}
+	}
+	expanding = append(append([]string(nil), expanding...), name)
+
+	placements := make([]Placement, 0)
+	for _, field := range i.structs[name].Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, i.errorf(field, "%v", err)
+			}
+			tag = reflect.StructTag(s)
+		}
+		bitsTag, tagged := tag.Lookup(goBitsTag)
+		if bitsTag == "-" {
+			continue
+		}
+
+		labels := make([]string, 0, len(field.Names))
+		for _, n := range field.Names {
+			labels = append(labels, n.Name)
+		}
+		if len(labels) == 0 {
+			// an embedded field
+			labels = append(labels, "")
+		}
+
+		for _, label := range labels {
+			if !tagged {
+				if ident, ok := i.resolve(field.Type).(*ast.Ident); ok && i.structs[ident.Name] != nil {
+					expanded, err := i.importStruct(ident.Name, expanding)
+					if err != nil {
+						return nil, err
+					}
+					for _, p := range expanded {
+						if label != "" {
+							p.Label = label + "." + p.Label
+						}
+						placements = append(placements, p)
+					}
+					continue
+				}
+			}
+			if label == "" {
+				return nil, i.errorf(field, "embedded field of type %s cannot be expanded", i.typeString(field.Type))
+			}
+
+			p, err := i.importField(field, label, bitsTag, tagged)
+			if err != nil {
+				return nil, err
+			}
+			placements = append(placements, p)
+		}
+	}
+	return placements, nil
+}
+
+func (i *goStructImporter) importField(field *ast.Field, label, bitsTag string, tagged bool) (Placement, error) {
+	p := Placement{Label: label}
+
+	if isGoByteSlice(i.resolve(field.Type)) {
+		max := uint(defaultGoVariableLengthMaxBits)
+		if tagged {
+			n, err := strconv.ParseUint(bitsTag, 10, 32)
+			if err != nil || n == 0 {
+				return p, i.errorf(field, "invalid bits tag %q", bitsTag)
+			}
+			max = uint(n)
+		}
+		p.VariableLength = &VariableLengthPlacementSpec{MaxBits: max}
+		return p, nil
+	}
+
+	if tagged {
+		n, err := strconv.ParseUint(bitsTag, 10, 32)
+		if err != nil || n == 0 {
+			return p, i.errorf(field, "invalid bits tag %q", bitsTag)
+		}
+		p.Bits = newUint(uint(n))
+		return p, nil
+	}
+
+	bits, err := i.typeBits(field.Type)
+	if err != nil {
+		return p, i.errorf(field, "%s %v; give it a bits tag", label, err)
+	}
+	p.Bits = newUint(bits)
+	return p, nil
+}
+
+// typeBits returns the width of a fixed-size type: a sized basic type, an
+// array of them or a struct made of them.
+func (i *goStructImporter) typeBits(expr ast.Expr) (uint, error) {
+	switch t := i.resolve(expr).(type) {
+	case *ast.Ident:
+		if bits, ok := goTypeBits[t.Name]; ok {
+			return bits, nil
+		}
+		if st := i.structs[t.Name]; st != nil {
+			placements, err := i.importStruct(t.Name, nil)
+			if err != nil {
+				return 0, err
+			}
+			sum := uint(0)
+			for _, p := range placements {
+				if p.VariableLength != nil {
+					return 0, errors.Errorf("has variable size type %s", t.Name)
+				}
+				sum += *p.Bits
+			}
+			return sum, nil
+		}
+	case *ast.ArrayType:
+		if t.Len == nil {
+			break
+		}
+		lit, ok := t.Len.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			return 0, errors.Errorf("has array type %s of unknown length", i.typeString(t))
+		}
+		n, err := strconv.ParseUint(lit.Value, 0, 32)
+		if err != nil {
+			return 0, err
+		}
+		bits, err := i.typeBits(t.Elt)
+		if err != nil {
+			return 0, err
+		}
+		return uint(n) * bits, nil
+	}
+	return 0, errors.Errorf("has type %s of no fixed size", i.typeString(expr))
+}
+
+// resolve follows the names of types declared in the file that are not
+// structs to their underlying type, as far as it is declared there.
+func (i *goStructImporter) resolve(expr ast.Expr) ast.Expr {
+	seen := map[string]bool{}
+	for {
+		ident, ok := expr.(*ast.Ident)
+		if !ok || seen[ident.Name] {
+			return expr
+		}
+		underlying, ok := i.types[ident.Name]
+		if !ok {
+			return expr
+		}
+		seen[ident.Name] = true
+		expr = underlying
+	}
+}
+
+func (i *goStructImporter) errorf(field *ast.Field, format string, args ...interface{}) error {
+	pos := i.fset.Position(field.Pos())
+	return errors.Errorf("line %d: "+format, append([]interface{}{pos.Line}, args...)...)
+}
+
+func isGoByteSlice(expr ast.Expr) bool {
+	t, ok := expr.(*ast.ArrayType)
+	if !ok || t.Len != nil {
+		return false
+	}
+	elt, ok := t.Elt.(*ast.Ident)
+	return ok && (elt.Name == "byte" || elt.Name == "uint8")
+}
+
+func (i *goStructImporter) typeString(expr ast.Expr) string {
+	var b strings.Builder
+	err := printer.Fprint(&b, i.fset, expr)
+	if err != nil {
+		return "?"
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package packetdiagram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestImportGoStruct(t *testing.T) {
+	t.Parallel()
+	src := `package wire
+
+type Addr struct {
+	Hi, Lo uint16
+}
+
+type Opcode uint8
+
+type Port = uint16
+
+type MAC [6]byte
+
+type Options []byte
+
+type Endpoint Addr
+
+type Header struct {
+	Version  uint8 ` + "`bits:\"4\"`" + `
+	IHL      uint8 ` + "`bits:\"4\"`" + `
+	Length   uint16
+	Src      Addr
+	Op       Opcode
+	Port     Port
+	HW       MAC
+	Dst      Endpoint
+	Reserved [3]byte
+	cache    map[string]int ` + "`bits:\"-\"`" + `
+	Options  Options ` + "`bits:\"320\"`" + `
+	Payload  []byte
+}
+`
+	def, err := ImportGoStruct(strings.NewReader(src), "Header")
+	assert.NoError(t, err)
+	assert.Equal(t, &Definition{
+		Name: "Header",
+		Placements: []Placement{
+			{Label: "Version", Bits: uintp(4)},
+			{Label: "IHL", Bits: uintp(4)},
+			{Label: "Length", Bits: uintp(16)},
+			{Label: "Src.Hi", Bits: uintp(16)},
+			{Label: "Src.Lo", Bits: uintp(16)},
+			{Label: "Op", Bits: uintp(8)},
+			{Label: "Port", Bits: uintp(16)},
+			{Label: "HW", Bits: uintp(48)},
+			{Label: "Dst.Hi", Bits: uintp(16)},
+			{Label: "Dst.Lo", Bits: uintp(16)},
+			{Label: "Reserved", Bits: uintp(24)},
+			{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320}},
+			{Label: "Payload", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+		},
+	}, def)
+}
+
+func TestImportGoStructErrors(t *testing.T) {
+	testData := []struct {
+		Name     string
+		Source   string
+		TypeName string
+		Error    string
+	}{
+		{
+			Name:   "ambiguous",
+			Source: "package p\ntype A struct{ X uint8 }\ntype B struct{ Y uint8 }\n",
+			Error:  "choose one by name",
+		},
+		{
+			Name:     "missing",
+			Source:   "package p\ntype A struct{ X uint8 }\n",
+			TypeName: "B",
+			Error:    "no struct type named B",
+		},
+		{
+			Name:   "no fixed size",
+			Source: "package p\ntype A struct{ X int }\n",
+			Error:  "line 2: X has type int of no fixed size",
+		},
+		{
+			Name:   "zero bytes",
+			Source: "package p\ntype A struct{ X []byte `bits:\"0\"` }\n",
+			Error:  "line 2: invalid bits tag \"0\"",
+		},
+		{
+			Name:   "named type of no fixed size",
+			Source: "package p\ntype Count int\ntype A struct{ X Count }\n",
+			Error:  "line 3: X has type Count of no fixed size",
+		},
+		{
+			Name:   "cyclic named types",
+			Source: "package p\ntype B C\ntype C B\ntype A struct{ X B }\n",
+			Error:  "line 4: X has type B of no fixed size",
+		},
+		{
+			Name:   "recursive",
+			Source: "package p\ntype A struct{ X uint8; Next A }\n",
+			Error:  "struct A contains itself",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ImportGoStruct(strings.NewReader(tt.Source), tt.TypeName)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.Error)
+		})
+	}
+}
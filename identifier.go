@@ -0,0 +1,85 @@
+package packetdiagram
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// snakeCaseIdentifier turns a label such as "Source Port" or "TotalLength"
+// into an identifier such as source_port or total_length, usable in the
+// languages definitions are exported to.
+func snakeCaseIdentifier(label string) string {
+	var b strings.Builder
+	rs := []rune(label)
+	sep := false
+	for i, r := range rs {
+		if r >= unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			sep = b.Len() > 0
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 && b.Len() > 0 {
+			prev := rs[i-1]
+			// the last capital of an acronym may start a word, as in HTTPServer,
+			// but not when the acronym has a suffix, as in IPv4
+			startsWord := i+2 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsLower(rs[i+2])
+			if unicode.IsLower(prev) || (unicode.IsUpper(prev) && startsWord) {
+				sep = true
+			}
+		}
+		if sep {
+			b.WriteByte('_')
+			sep = false
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	id := b.String()
+	if id == "" {
+		return "field"
+	}
+	if unicode.IsDigit(rune(id[0])) {
+		return "f_" + id
+	}
+	return id
+}
+
+// identifierSet hands out identifiers, numbering the ones already taken.
+type identifierSet map[string]bool
+
+func (s identifierSet) add(label string) string {
+	base := snakeCaseIdentifier(label)
+	id := base
+	for n := 2; s[id]; n++ {
+		id = base + "_" + strconv.Itoa(n)
+	}
+	s[id] = true
+	return id
+}
+
+// pascalCaseIdentifier turns a label such as "IPv4 header" into a type name
+// such as IPv4Header, keeping the capitals of each word.
+func pascalCaseIdentifier(label string) string {
+	var b strings.Builder
+	start := true
+	for _, r := range label {
+		if r >= unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			start = true
+			continue
+		}
+		if start {
+			r = unicode.ToUpper(r)
+			start = false
+		}
+		b.WriteRune(r)
+	}
+
+	id := b.String()
+	if id == "" {
+		return "Packet"
+	}
+	if unicode.IsDigit(rune(id[0])) {
+		return "P" + id
+	}
+	return id
+}
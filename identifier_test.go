@@ -0,0 +1,60 @@
+package packetdiagram
+
+import (
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestSnakeCaseIdentifier(t *testing.T) {
+	testData := []struct {
+		Label    string
+		Expected string
+	}{
+		{Label: "Source Port", Expected: "source_port"},
+		{Label: "TotalLength", Expected: "total_length"},
+		{Label: "IHL", Expected: "ihl"},
+		{Label: "HTTPServer", Expected: "http_server"},
+		{Label: "IPv4 Header", Expected: "ipv4_header"},
+		{Label: "src.b", Expected: "src_b"},
+		{Label: "802.1Q Tag", Expected: "f_802_1q_tag"},
+		{Label: "(padding)", Expected: "padding"},
+		{Label: "", Expected: "field"},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Label, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.Expected, snakeCaseIdentifier(tt.Label))
+		})
+	}
+}
+
+func TestIdentifierSet(t *testing.T) {
+	t.Parallel()
+	ids := identifierSet{}
+	assert.Equal(t, "flags", ids.add("Flags"))
+	assert.Equal(t, "flags_2", ids.add("flags"))
+	assert.Equal(t, "flags_3", ids.add("FLAGS"))
+}
+
+func TestPascalCaseIdentifier(t *testing.T) {
+	testData := []struct {
+		Label    string
+		Expected string
+	}{
+		{Label: "IPv4 header", Expected: "IPv4Header"},
+		{Label: "tcp_segment", Expected: "TcpSegment"},
+		{Label: "802.1Q Tag", Expected: "P8021QTag"},
+		{Label: "", Expected: "Packet"},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Label, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.Expected, pascalCaseIdentifier(tt.Label))
+		})
+	}
+}
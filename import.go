@@ -0,0 +1,230 @@
+package packetdiagram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// importList holds the files named by an `import` or `include` directive,
+// given either as a single path or as a list of them.
+type importList []string
+
+func (l *importList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*l = importList{path}
+		return nil
+	}
+
+	var paths []string
+	err := unmarshal(&paths)
+	if err != nil {
+		return err
+	}
+	*l = paths
+	return nil
+}
+
+func (importList) extendSchema(schema map[string]interface{}) {
+	delete(schema, "type")
+	delete(schema, "items")
+	schema["anyOf"] = []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	}
+}
+
+// library is what a definition file offers to the files importing it: the
+// settings of its documents without placements, and its structures.
+type library struct {
+	defaults   *Definition
+	structures map[string][]Placement
+}
+
+// importer loads the files imported by definitions, each of them once.
+type importer struct {
+	disabled bool
+	// skip leaves imports and structures unresolved, for checking a file on
+	// its own
+	skip    bool
+	loading []string // the files being loaded, outermost first
+	loaded  map[string]*library
+	read    []string // every file read, in the order they were
+}
+
+func newImporter() *importer {
+	return &importer{
+		loading: make([]string, 0),
+		loaded:  map[string]*library{},
+		read:    make([]string, 0),
+	}
+}
+
+// LoadDefinitionsFile reads every diagram of a definition file, in JSON or
+// TOML if its extension says so and in YAML otherwise, resolving its
+// imports relative to the directory it is in.
+func LoadDefinitionsFile(path string) ([]*Definition, error) {
+	defs, _, err := LoadDefinitionsFileSources(path)
+	return defs, err
+}
+
+// LoadDefinitionsFileSources is like LoadDefinitionsFile but also returns
+// the files the diagrams are read from: the file itself and those it
+// imports, directly or not, as absolute paths. They are returned on errors
+// too, as far as they were read, for the caller to tell when the error may
+// have been fixed.
+func LoadDefinitionsFileSources(path string) ([]*Definition, []string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	imp := newImporter()
+	imp.read = append(imp.read, abs)
+	b, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return nil, imp.read, err
+	}
+	b, err = yamlFromFile(abs, b)
+	if err != nil {
+		return nil, imp.read, err
+	}
+
+	imp.loading = append(imp.loading, abs)
+	defs, err := imp.loadDefinitions(b, filepath.Dir(abs))
+	return defs, imp.read, err
+}
+
+// importFiles merges the libraries at paths, relative to dir. Later imports
+// take precedence over earlier ones.
+func (imp *importer) importFiles(dir string, paths []string) (*library, error) {
+	lib := &library{
+		defaults:   &Definition{},
+		structures: map[string][]Placement{},
+	}
+	if len(paths) == 0 || imp.skip {
+		return lib, nil
+	}
+	if imp.disabled {
+		return nil, errors.New("imports are not allowed here")
+	}
+
+	for i := len(paths) - 1; i >= 0; i-- {
+		path := paths[i]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		l, err := imp.importFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, paths[i])
+		}
+
+		applyDefinitionDefaults(lib.defaults, l.defaults)
+		for name, s := range l.structures {
+			if _, ok := lib.structures[name]; !ok {
+				lib.structures[name] = s
+			}
+		}
+	}
+
+	return lib, nil
+}
+
+func (imp *importer) importFile(path string) (*library, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range imp.loading {
+		if f == abs {
+			cycle := append(append([]string(nil), imp.loading[i:]...), abs)
+			return nil, errors.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if lib, ok := imp.loaded[abs]; ok {
+		return lib, nil
+	}
+
+	imp.read = append(imp.read, abs)
+	b, err := ioutil.ReadFile(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("no such file")
+		}
+		return nil, err
+	}
+	b, err = yamlFromFile(abs, b)
+	if err != nil {
+		return nil, err
+	}
+
+	imp.loading = append(imp.loading, abs)
+	defer func() {
+		imp.loading = imp.loading[:len(imp.loading)-1]
+	}()
+
+	f, err := imp.parseFile(b, filepath.Dir(abs))
+	if err != nil {
+		return nil, err
+	}
+
+	// structures are resolved where they are written, so that a library
+	// need not be imported along with the libraries it uses
+	lib := &library{
+		defaults:   f.defaults,
+		structures: map[string][]Placement{},
+	}
+	for name, s := range f.structures {
+		lib.structures[name], err = expandStructures(s, f.structures, []string{name})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	imp.loaded[abs] = lib
+	return lib, nil
+}
+
+// expandStructures replaces the placements referring to a structure with the
+// placements of the structure. A fill given on the reference applies to the
+// placements that have none of their own.
+func expandStructures(placements []Placement, structures map[string][]Placement, expanding []string) ([]Placement, error) {
+	expanded := make([]Placement, 0, len(placements))
+	for _, p := range placements {
+		if p.Structure == "" {
+			expanded = append(expanded, p)
+			continue
+		}
+
+		for _, name := range expanding {
+			if name == p.Structure {
+				return nil, errors.Errorf("structure %s contains itself", p.Structure)
+			}
+		}
+		s, ok := structures[p.Structure]
+		if !ok {
+			return nil, errors.Errorf("unknown structure %s", p.Structure)
+		}
+		s, err := expandStructures(s, structures, append(append([]string(nil), expanding...), p.Structure))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sp := range s {
+			if sp.Fill == nil {
+				sp.Fill = p.Fill
+			}
+			if sp.Pattern == nil {
+				sp.Pattern = p.Pattern
+			}
+			if sp.Kind == nil {
+				sp.Kind = p.Kind
+			}
+			expanded = append(expanded, sp)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,122 @@
+package packetdiagram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestLoadDefinitionsFileImports(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/corp.pd": `
+theme:
+  background:
+    color: gray
+cell:
+  width: 20
+  height: 20
+structures:
+  address:
+    - label: Address
+      bits: 32
+`,
+		"lib/tlv.pd": `
+import: corp.pd
+structures:
+  tlv:
+    - label: Type
+      bits: 8
+      fill: white
+    - label: Length
+      bits: 8
+    - structure: address
+`,
+		"main.pd": `
+include:
+  - lib/tlv.pd
+cell:
+  height: 40
+placements:
+  - structure: tlv
+    fill: yellow
+`,
+		"main.pd.json": `{
+  "include": ["lib/tlv.pd"],
+  "cell": {"height": 40},
+  "placements": [{"structure": "tlv", "fill": "yellow"}]
+}`,
+		"main.pd.toml": `
+include = ["lib/tlv.pd"]
+cell = { height = 40 }
+
+[structures]
+frame = [{ structure = "tlv" }]
+
+[[placements]]
+structure = "frame"
+fill = "yellow"
+`,
+		"cycle-a.pd": `
+import: cycle-b.pd
+placements:
+  - label: A
+    bits: 8
+`,
+		"cycle-b.pd": `
+import: cycle-a.pd
+`,
+		"broken.pd": `
+import: lib/missing.pd
+placements:
+  - label: A
+    bits: 8
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	defs, err := LoadDefinitionsFile(filepath.Join(dir, "main.pd"))
+	assert.NoError(t, err)
+	assert.Equal(t, []*Definition{
+		{
+			Theme: &ThemeSpec{Background: &BackgroundSpec{Color: stringp("gray")}},
+			Cell:  CellSpec{Width: uintp(20), Height: uintp(40)},
+			Placements: []Placement{
+				{Label: "Type", Bits: uintp(8), Fill: stringp("white")},
+				{Label: "Length", Bits: uintp(8), Fill: stringp("yellow")},
+				{Label: "Address", Bits: uintp(32), Fill: stringp("yellow")},
+			},
+		},
+	}, defs)
+
+	// JSON and TOML files import and expand structures just as YAML ones
+	for _, name := range []string{"main.pd.json", "main.pd.toml"} {
+		others, err := LoadDefinitionsFile(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, defs, others, name)
+	}
+
+	_, err = LoadDefinitionsFile(filepath.Join(dir, "cycle-a.pd"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "import cycle")
+
+	_, err = LoadDefinitionsFile(filepath.Join(dir, "broken.pd"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "lib/missing.pd")
+
+	_, err = LoadDefinitionsUntrusted(strings.NewReader(files["main.pd"]))
+	assert.Error(t, err)
+	_, err = LoadDefinitionsUntrustedJSON(strings.NewReader(files["main.pd.json"]))
+	assert.EqualError(t, err, "imports are not allowed here")
+	_, err = LoadDefinitionsUntrustedTOML(strings.NewReader(files["main.pd.toml"]))
+	assert.EqualError(t, err, "imports are not allowed here")
+}
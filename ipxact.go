@@ -0,0 +1,178 @@
+package packetdiagram
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The subset of IP-XACT (IEEE 1685) describing registers. Elements are
+// matched by local name, so the 2009 (spirit:) and 2014/2022 (ipxact:)
+// namespaces both work.
+type ipxactComponent struct {
+	MemoryMaps []ipxactMemoryMap `xml:"memoryMaps>memoryMap"`
+}
+
+type ipxactMemoryMap struct {
+	Name          string               `xml:"name"`
+	AddressBlocks []ipxactAddressBlock `xml:"addressBlock"`
+}
+
+type ipxactAddressBlock struct {
+	Name          string               `xml:"name"`
+	Access        string               `xml:"access"`
+	Registers     []ipxactRegister     `xml:"register"`
+	RegisterFiles []ipxactRegisterFile `xml:"registerFile"`
+}
+
+type ipxactRegisterFile struct {
+	Name          string               `xml:"name"`
+	Registers     []ipxactRegister     `xml:"register"`
+	RegisterFiles []ipxactRegisterFile `xml:"registerFile"`
+}
+
+type ipxactRegister struct {
+	Name   string        `xml:"name"`
+	Size   string        `xml:"size"`
+	Access string        `xml:"access"`
+	Reset  string        `xml:"reset>value"`
+	Fields []ipxactField `xml:"field"`
+}
+
+type ipxactField struct {
+	Name               string `xml:"name"`
+	BitOffset          string `xml:"bitOffset"`
+	BitWidth           string `xml:"bitWidth"`
+	Access             string `xml:"access"`
+	ModifiedWriteValue string `xml:"modifiedWriteValue"`
+	ReadAction         string `xml:"readAction"`
+	Reset              string `xml:"resets>reset>value"`
+}
+
+// ImportIPXACT reads the registers of an IP-XACT component and makes a
+// register mode Definition of each, named after the address block,
+// register files and register it sits in.
+func ImportIPXACT(r io.Reader) ([]*Definition, error) {
+	var c ipxactComponent
+	err := xml.NewDecoder(r).Decode(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make([]*Definition, 0)
+	for _, m := range c.MemoryMaps {
+		for _, b := range m.AddressBlocks {
+			d, err := importIPXACTRegisters([]string{b.Name}, b.Access, b.Registers, b.RegisterFiles)
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d...)
+		}
+	}
+	if len(defs) == 0 {
+		return nil, errors.New("no registers found")
+	}
+	return defs, nil
+}
+
+func importIPXACTRegisters(path []string, access string, regs []ipxactRegister, files []ipxactRegisterFile) ([]*Definition, error) {
+	defs := make([]*Definition, 0, len(regs))
+	for _, reg := range regs {
+		def, err := importIPXACTRegister(append(path, reg.Name), access, reg)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	for _, f := range files {
+		d, err := importIPXACTRegisters(append(path, f.Name), access, f.Registers, f.RegisterFiles)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, d...)
+	}
+	return defs, nil
+}
+
+func importIPXACTRegister(path []string, access string, reg ipxactRegister) (*Definition, error) {
+	name := strings.Join(path, ".")
+	if reg.Access != "" {
+		access = reg.Access
+	}
+
+	width, err := parseHDLNumber(reg.Size)
+	if err != nil {
+		return nil, errors.Wrapf(err, "register %s: size", name)
+	}
+
+	// IP-XACT 2009 gives the reset value of the register as a whole
+	var regReset *uint64
+	if reg.Reset != "" {
+		v, err := parseHDLNumber(reg.Reset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "register %s: reset", name)
+		}
+		regReset = &v
+	}
+
+	fields := make([]registerField, 0, len(reg.Fields))
+	for _, f := range reg.Fields {
+		lsb, err := parseHDLNumber(f.BitOffset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "register %s: field %s: bitOffset", name, f.Name)
+		}
+		w, err := parseHDLNumber(f.BitWidth)
+		if err != nil {
+			return nil, errors.Wrapf(err, "register %s: field %s: bitWidth", name, f.Name)
+		}
+
+		field := registerField{name: f.Name, lsb: uint(lsb), width: uint(w)}
+		fieldAccess := access
+		if f.Access != "" {
+			fieldAccess = f.Access
+		}
+		field.access = ipxactAccessType(fieldAccess, f.ModifiedWriteValue, f.ReadAction)
+
+		switch {
+		case f.Reset != "":
+			v, err := parseHDLNumber(f.Reset)
+			if err != nil {
+				return nil, errors.Wrapf(err, "register %s: field %s: reset", name, f.Name)
+			}
+			field.reset = &v
+		case regReset != nil && w < 64:
+			v := (*regReset >> lsb) & (1<<w - 1)
+			field.reset = &v
+		}
+		fields = append(fields, field)
+	}
+
+	return newRegisterDefinition(name, uint(width), fields)
+}
+
+func ipxactAccessType(access, modifiedWriteValue, readAction string) *AccessType {
+	var t AccessType
+	switch {
+	case readAction == "clear":
+		t = AccessTypeRC
+	case readAction == "set":
+		t = AccessTypeRS
+	case modifiedWriteValue == "oneToClear":
+		t = AccessTypeW1C
+	case modifiedWriteValue == "oneToSet":
+		t = AccessTypeW1S
+	case modifiedWriteValue == "oneToToggle":
+		t = AccessTypeW1T
+	case access == "read-only":
+		t = AccessTypeRO
+	case access == "write-only" || access == "writeOnce":
+		t = AccessTypeWO
+	case access == "read-write" || access == "read-writeOnce":
+		t = AccessTypeRW
+	default:
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,107 @@
+package packetdiagram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestImportIPXACT(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+	}{
+		{
+			Name: "2014, field resets",
+			Source: `<?xml version="1.0"?>
+<ipxact:component xmlns:ipxact="http://www.accellera.org/XMLSchema/IPXACT/1685-2014">
+  <ipxact:memoryMaps>
+    <ipxact:memoryMap>
+      <ipxact:name>regs</ipxact:name>
+      <ipxact:addressBlock>
+        <ipxact:name>uart</ipxact:name>
+        <ipxact:access>read-write</ipxact:access>
+        <ipxact:register>
+          <ipxact:name>CTRL</ipxact:name>
+          <ipxact:size>32</ipxact:size>
+          <ipxact:field>
+            <ipxact:name>EN</ipxact:name>
+            <ipxact:bitOffset>0</ipxact:bitOffset>
+            <ipxact:resets><ipxact:reset><ipxact:value>1</ipxact:value></ipxact:reset></ipxact:resets>
+            <ipxact:bitWidth>1</ipxact:bitWidth>
+          </ipxact:field>
+          <ipxact:field>
+            <ipxact:name>IRQ</ipxact:name>
+            <ipxact:bitOffset>8</ipxact:bitOffset>
+            <ipxact:resets><ipxact:reset><ipxact:value>0</ipxact:value></ipxact:reset></ipxact:resets>
+            <ipxact:bitWidth>8</ipxact:bitWidth>
+            <ipxact:modifiedWriteValue>oneToClear</ipxact:modifiedWriteValue>
+          </ipxact:field>
+        </ipxact:register>
+      </ipxact:addressBlock>
+    </ipxact:memoryMap>
+  </ipxact:memoryMaps>
+</ipxact:component>`,
+		},
+		{
+			Name: "2009, register reset",
+			Source: `<?xml version="1.0"?>
+<spirit:component xmlns:spirit="http://www.spiritconsortium.org/XMLSchema/SPIRIT/1685-2009">
+  <spirit:memoryMaps>
+    <spirit:memoryMap>
+      <spirit:name>regs</spirit:name>
+      <spirit:addressBlock>
+        <spirit:name>uart</spirit:name>
+        <spirit:register>
+          <spirit:name>CTRL</spirit:name>
+          <spirit:size>32</spirit:size>
+          <spirit:access>read-write</spirit:access>
+          <spirit:reset><spirit:value>0x00000001</spirit:value></spirit:reset>
+          <spirit:field>
+            <spirit:name>EN</spirit:name>
+            <spirit:bitOffset>0</spirit:bitOffset>
+            <spirit:bitWidth>1</spirit:bitWidth>
+          </spirit:field>
+          <spirit:field>
+            <spirit:name>IRQ</spirit:name>
+            <spirit:bitOffset>8</spirit:bitOffset>
+            <spirit:bitWidth>8</spirit:bitWidth>
+            <spirit:modifiedWriteValue>oneToClear</spirit:modifiedWriteValue>
+          </spirit:field>
+        </spirit:register>
+      </spirit:addressBlock>
+    </spirit:memoryMap>
+  </spirit:memoryMaps>
+</spirit:component>`,
+		},
+	}
+
+	reset := func(v uint64) *uint64 { return &v }
+	access := func(a AccessType) *AccessType { return &a }
+	mode := ModeRegister
+	expected := []*Definition{
+		{
+			Name:          "uart.CTRL",
+			Mode:          &mode,
+			OctetsPerLine: uintp(4),
+			XAxis:         XAxisSpec{Bits: &XAxisBitsSpec{}},
+			Placements: []Placement{
+				{Label: "Reserved", Bits: uintp(16)},
+				{Label: "IRQ", Bits: uintp(8), Access: access(AccessTypeW1C), Reset: reset(0)},
+				{Label: "Reserved", Bits: uintp(7)},
+				{Label: "EN", Bits: uintp(1), Access: access(AccessTypeRW), Reset: reset(1)},
+			},
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			defs, err := ImportIPXACT(strings.NewReader(tt.Source))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, defs)
+		})
+	}
+}
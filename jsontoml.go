@@ -0,0 +1,182 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// JSON and TOML definition files are read as the YAML document they amount
+// to, so that they have diagrams, structures and imports just as YAML files
+// do.
+
+// yamlFromFile turns the definition file at path into YAML, going by its
+// extension.
+func yamlFromFile(path string, b []byte) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return yamlFromJSON(b)
+	case ".toml":
+		return yamlFromTOML(b)
+	}
+	return b, nil
+}
+
+// yamlFromJSON re-encodes a JSON definition file as YAML, keeping the order
+// of keys, which that of the diagrams depends on.
+func yamlFromJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the definition")
+	}
+	return marshalDefinitionFile(v)
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := t.(type) {
+	case json.Delim:
+		if t == '[' {
+			s := make([]interface{}, 0)
+			for dec.More() {
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				s = append(s, v)
+			}
+			_, err = dec.Token()
+			return s, err
+		}
+
+		m := yaml.MapSlice{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yaml.MapItem{Key: k, Value: v})
+		}
+		_, err = dec.Token()
+		return m, err
+	case json.Number:
+		// as integers where they are, for 64-bit values to stay exact
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		if i, err := strconv.ParseInt(t.String(), 10, 64); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	}
+	return t, nil
+}
+
+// yamlFromTOML re-encodes a TOML definition file as YAML. Keys are ordered
+// as they first appear in the file, which decoding into a map loses.
+func yamlFromTOML(b []byte) ([]byte, error) {
+	var m map[string]interface{}
+	md, err := toml.Decode(string(b), &m)
+	if err != nil {
+		return nil, err
+	}
+
+	// tables given only by their subtables, as in [a.b], take the place of
+	// the first of them
+	order := map[string]int{}
+	for i, k := range md.Keys() {
+		for n := 1; n <= len(k); n++ {
+			key := strings.Join(k[:n], "\x00")
+			if _, ok := order[key]; !ok {
+				order[key] = i
+			}
+		}
+	}
+	return marshalDefinitionFile(orderTOMLValue(m, nil, order))
+}
+
+func orderTOMLValue(v interface{}, path []string, order map[string]int) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		position := func(k string) int {
+			if i, ok := order[strings.Join(append(path, k), "\x00")]; ok {
+				return i
+			}
+			return len(order)
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			pi, pj := position(keys[i]), position(keys[j])
+			if pi != pj {
+				return pi < pj
+			}
+			return keys[i] < keys[j]
+		})
+
+		m := make(yaml.MapSlice, 0, len(v))
+		for _, k := range keys {
+			child := append(path[:len(path):len(path)], k)
+			m = append(m, yaml.MapItem{Key: k, Value: orderTOMLValue(v[k], child, order)})
+		}
+		return m
+	case []map[string]interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = orderTOMLValue(e, path, order)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = orderTOMLValue(e, path, order)
+		}
+		return s
+	}
+	return v
+}
+
+// marshalDefinitionFile encodes a definition file decoded from JSON or TOML
+// as YAML. Top-level keys that are not settings of a definition file are
+// rejected rather than dropped, as they are most likely misspelled.
+func marshalDefinitionFile(v interface{}) ([]byte, error) {
+	m, ok := v.(yaml.MapSlice)
+	if !ok {
+		return nil, errors.New("a definition must be an object")
+	}
+
+	known := map[string]bool{}
+	for _, f := range yamlFields(reflect.TypeOf(definitionFile{})) {
+		known[f.name] = true
+	}
+	for _, item := range m {
+		if k, _ := item.Key.(string); !known[k] {
+			return nil, errors.Errorf("unknown key `%v`", item.Key)
+		}
+	}
+	return yaml.Marshal(m)
+}
@@ -0,0 +1,496 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	kaitaiOrigIDKey = "-orig-id"
+
+	// the room given to fields Kaitai sizes at run time: one row
+	defaultKaitaiVariableLengthMaxBits = defaultOctetsPerLine * 8
+)
+
+// ExportKaitai writes a Kaitai Struct type (.ksy) reading what def
+// describes. Byte-aligned fields of 8, 16, 32 or 64 bits become u1 to u8,
+// longer byte-aligned ones byte arrays and the rest bit fields, in the byte
+// and bit order of the definition, which fields that differ from it give
+// with an le or be suffix to their type. Variable-length fields take their
+// size, in bytes, from their length field. Without one, a trailing
+// variable-length field runs to the end of the stream and any other one takes
+// its size from a parameter of the type. Fields with values read
+// as enums. Labels that are not valid Kaitai identifiers are kept as
+// -orig-id.
+func ExportKaitai(def *Definition, w io.Writer) error {
+	ids := identifierSet{}
+	orders := def.getFieldOrders()
+	endian, bitEndian := kaitaiEndian(def.GetByteOrder() == ByteOrderLittleEndian), kaitaiEndian(def.GetBitOrder() == BitOrderLSBFirst)
+	params := make([]yaml.MapSlice, 0)
+	seq := make([]yaml.MapSlice, 0, len(def.Placements))
+	enums := yaml.MapSlice{}
+	fieldIDs := make([]string, 0, len(def.Placements))
+
+	offset := uint(0) // within the current byte
+	for i, p := range def.Placements {
+		label := p.Label
+		if p.Name != "" {
+			label = p.Name
+		}
+		id := ids.add(label)
+		fieldIDs = append(fieldIDs, id)
+		attr := yaml.MapSlice{{Key: "id", Value: id}}
+		if p.Label != "" && p.Label != id {
+			attr = append(attr, yaml.MapItem{Key: kaitaiOrigIDKey, Value: p.Label})
+		}
+
+		switch {
+		case p.VariableLength != nil:
+			if offset != 0 {
+				return errors.Errorf("placement %s: Kaitai variable-length fields must start on a byte boundary", p.GetKey())
+			}
+			if j := def.getLengthField(p); j >= 0 {
+				if j > i {
+					return errors.Errorf("placement %s: Kaitai reads the length field %s after it", p.GetKey(), p.VariableLength.LengthField)
+				}
+				attr = append(attr, yaml.MapItem{Key: "size", Value: fieldIDs[j]})
+				break
+			}
+			if i == len(def.Placements)-1 {
+				attr = append(attr, yaml.MapItem{Key: "size-eos", Value: true})
+				break
+			}
+			param := ids.add(id + "_size")
+			params = append(params, yaml.MapSlice{{Key: "id", Value: param}, {Key: "type", Value: "u4"}})
+			attr = append(attr, yaml.MapItem{Key: "size", Value: param})
+		case p.Bits != nil:
+			n := *p.Bits
+			// bit fields of whole bytes on byte boundaries read little-endian
+			// when their bits are le
+			fieldEndian := kaitaiEndian(orders[i].littleEndian)
+			switch {
+			case offset == 0 && (n == 8 || n == 16 || n == 32 || n == 64):
+				typ := "u" + strconv.Itoa(int(n/8))
+				if n > 8 && fieldEndian != endian {
+					typ += fieldEndian
+				}
+				attr = append(attr, yaml.MapItem{Key: "type", Value: typ})
+			case n <= 64:
+				typ := "b" + strconv.Itoa(int(n))
+				if fieldEndian != bitEndian {
+					typ += fieldEndian
+				}
+				attr = append(attr, yaml.MapItem{Key: "type", Value: typ})
+			case offset == 0 && n%8 == 0:
+				attr = append(attr, yaml.MapItem{Key: "size", Value: n / 8})
+			default:
+				return errors.Errorf("placement %s: Kaitai cannot read %d bits unaligned to bytes", p.GetKey(), n)
+			}
+			offset = (offset + n) % 8
+
+			if len(p.Values) > 0 {
+				attr = append(attr, yaml.MapItem{Key: "enum", Value: id})
+				enums = append(enums, yaml.MapItem{Key: id, Value: kaitaiEnum(p.Values)})
+			}
+		default:
+			return errors.Errorf("placement %s has no size", p.GetKey())
+		}
+		seq = append(seq, attr)
+	}
+
+	meta := yaml.MapSlice{{Key: "id", Value: snakeCaseIdentifier(def.Name)}}
+	if def.Name == "" {
+		meta[0].Value = "packet"
+	} else {
+		meta = append(meta, yaml.MapItem{Key: "title", Value: def.Name})
+	}
+	meta = append(meta,
+		yaml.MapItem{Key: "endian", Value: endian},
+		yaml.MapItem{Key: "bit-endian", Value: bitEndian},
+	)
+
+	ksy := yaml.MapSlice{{Key: "meta", Value: meta}}
+	if len(params) > 0 {
+		ksy = append(ksy, yaml.MapItem{Key: "params", Value: params})
+	}
+	ksy = append(ksy, yaml.MapItem{Key: "seq", Value: seq})
+	if len(enums) > 0 {
+		ksy = append(ksy, yaml.MapItem{Key: "enums", Value: enums})
+	}
+
+	b, err := yaml.Marshal(ksy)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func kaitaiEndian(littleEndian bool) string {
+	if littleEndian {
+		return "le"
+	}
+	return "be"
+}
+
+func kaitaiEnum(values []ValueSpec) yaml.MapSlice {
+	ids := identifierSet{}
+	enum := make(yaml.MapSlice, 0, len(values))
+	for _, v := range values {
+		id := ids.add(v.Label)
+		var value interface{} = id
+		if v.Label != id {
+			value = yaml.MapSlice{{Key: "id", Value: id}, {Key: kaitaiOrigIDKey, Value: v.Label}}
+		}
+		enum = append(enum, yaml.MapItem{Key: v.Value, Value: value})
+	}
+	return enum
+}
+
+// The subset of Kaitai Struct that lays out a type.
+type kaitaiType struct {
+	Meta struct {
+		ID        string      `yaml:"id"`
+		Title     string      `yaml:"title"`
+		Endian    interface{} `yaml:"endian"`
+		BitEndian string      `yaml:"bit-endian"`
+	} `yaml:"meta"`
+	Seq   []kaitaiAttribute        `yaml:"seq"`
+	Types map[string]*kaitaiType   `yaml:"types"`
+	Enums map[string]yaml.MapSlice `yaml:"enums"`
+}
+
+type kaitaiAttribute struct {
+	ID         string      `yaml:"id"`
+	OrigID     string      `yaml:"-orig-id"`
+	Type       interface{} `yaml:"type"`
+	Size       interface{} `yaml:"size"`
+	SizeEOS    bool        `yaml:"size-eos"`
+	Terminator interface{} `yaml:"terminator"`
+	Contents   interface{} `yaml:"contents"`
+	Repeat     string      `yaml:"repeat"`
+	RepeatExpr interface{} `yaml:"repeat-expr"`
+	Enum       string      `yaml:"enum"`
+}
+
+var (
+	kaitaiBitType   = regexp.MustCompile(`^b([0-9]+)(be|le)?$`)
+	kaitaiIntType   = regexp.MustCompile(`^[us]([1248])(be|le)?$`)
+	kaitaiFloatType = regexp.MustCompile(`^f([48])(be|le)?$`)
+)
+
+// ImportKaitai builds a Definition from the seq of a Kaitai Struct type
+// (.ksy). Fields of user types are expanded in place, labelled with the path
+// to them, and fields whose size is only known at run time become
+// variable-length, sized by the field read before them that their size
+// names, if any. Enums become the values of the fields using them. The endian
+// and bit-endian of the top-level type become the byte and bit order of the
+// definition, and fields that differ from them get their own.
+func ImportKaitai(r io.Reader) (*Definition, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ksy kaitaiType
+	err = yaml.Unmarshal(src, &ksy)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ksy.Meta.Title
+	if name == "" {
+		name = ksy.Meta.ID
+	}
+
+	def := &Definition{Name: name}
+	if ksy.Meta.BitEndian == "le" {
+		def.BitOrder = bitOrderp(BitOrderLSBFirst)
+	}
+	// an endian that switches on a field is left as big-endian
+	if e, ok := ksy.Meta.Endian.(string); ok && kaitaiByteOrder(e) != def.GetByteOrder() {
+		def.ByteOrder = byteOrderp(kaitaiByteOrder(e))
+	}
+
+	placements, err := importKaitaiType(def, name, []*kaitaiType{&ksy}, nil)
+	if err != nil {
+		return nil, err
+	}
+	def.Placements = placements
+
+	err = def.validate()
+	if err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// importKaitaiType lays out the last of scopes, a type nested in the ones
+// before it, for def.
+func importKaitaiType(def *Definition, name string, scopes []*kaitaiType, expanding []string) ([]Placement, error) {
+	for _, e := range expanding {
+		if e == name {
+			return nil, errors.Errorf("type %s contains itself", name)
+		}
+	}
+	expanding = append(append([]string(nil), expanding...), name)
+
+	t := scopes[len(scopes)-1]
+	placements := make([]Placement, 0, len(t.Seq))
+	fields := map[string]string{} // labels of the fields read before, by id
+	for _, a := range t.Seq {
+		label := a.ID
+		if a.OrigID != "" {
+			label = a.OrigID
+		}
+
+		expanded, err := importKaitaiAttribute(def, a, label, scopes, expanding)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", a.ID)
+		}
+		if len(expanded) == 1 {
+			p := expanded[0]
+			// sized by a field read before it
+			if size, ok := a.Size.(string); ok && p.VariableLength != nil && fields[size] != "" {
+				p.VariableLength.LengthField = fields[size]
+			}
+			if p.Bits != nil && p.Label == label {
+				fields[a.ID] = label
+			}
+		}
+		placements = append(placements, expanded...)
+	}
+	return placements, nil
+}
+
+func importKaitaiAttribute(def *Definition, a kaitaiAttribute, label string, scopes []*kaitaiType, expanding []string) ([]Placement, error) {
+	variableLength := []Placement{{Label: label, VariableLength: &VariableLengthPlacementSpec{MaxBits: defaultKaitaiVariableLengthMaxBits}}}
+
+	count := uint64(1)
+	switch a.Repeat {
+	case "":
+	case "expr":
+		n, ok := kaitaiInteger(a.RepeatExpr)
+		if !ok {
+			return variableLength, nil
+		}
+		count = n
+	default:
+		return variableLength, nil
+	}
+
+	bits := uint64(0)
+	typeName, _ := a.Type.(string)
+	if i := strings.Index(typeName, "("); i >= 0 {
+		// the arguments of a parametric type
+		typeName = typeName[:i]
+	}
+	size, sized := kaitaiInteger(a.Size)
+
+	switch {
+	case a.Contents != nil:
+		switch c := a.Contents.(type) {
+		case string:
+			bits = uint64(len(c)) * 8
+		case []interface{}:
+			bits = uint64(len(c)) * 8
+		default:
+			return nil, errors.New("unsupported contents")
+		}
+	case sized:
+		bits = size * 8
+	case a.Size != nil || a.SizeEOS || a.Terminator != nil || typeName == "strz":
+		return variableLength, nil
+	case typeName == "" && a.Type != nil:
+		// switch-on types vary in size
+		return variableLength, nil
+	case typeName == "" || typeName == "str":
+		return nil, errors.New("the size is not given")
+	default:
+		if m := kaitaiBitType.FindStringSubmatch(typeName); m != nil {
+			n, _ := strconv.ParseUint(m[1], 10, 32)
+			bits = n
+			break
+		}
+		if m := kaitaiIntType.FindStringSubmatch(typeName); m != nil {
+			n, _ := strconv.ParseUint(m[1], 10, 32)
+			bits = n * 8
+			break
+		}
+		if m := kaitaiFloatType.FindStringSubmatch(typeName); m != nil {
+			n, _ := strconv.ParseUint(m[1], 10, 32)
+			bits = n * 8
+			break
+		}
+
+		sub, subScopes := lookupKaitaiType(scopes, typeName)
+		if sub == nil {
+			return nil, errors.Errorf("unknown type %s", typeName)
+		}
+		expanded, err := importKaitaiType(def, typeName, subScopes, expanding)
+		if err != nil {
+			return nil, err
+		}
+		if count == 1 {
+			for i := range expanded {
+				expanded[i].Label = label + "." + expanded[i].Label
+				if v := expanded[i].VariableLength; v != nil && v.LengthField != "" {
+					v.LengthField = label + "." + v.LengthField
+				}
+			}
+			return expanded, nil
+		}
+		for _, p := range expanded {
+			if p.VariableLength != nil {
+				return variableLength, nil
+			}
+			bits += uint64(*p.Bits)
+		}
+	}
+
+	p := Placement{Label: label, Bits: newUint(uint(bits * count))}
+	if count == 1 {
+		setKaitaiOrders(def, &p, typeName)
+	}
+	if a.Enum != "" && count == 1 {
+		values, err := lookupKaitaiEnum(scopes, a.Enum)
+		if err != nil {
+			return nil, err
+		}
+		p.Values = values
+	}
+	return []Placement{p}, nil
+}
+
+// setKaitaiOrders gives p the byte and bit order of the Kaitai type it reads
+// as where they differ from those of def.
+func setKaitaiOrders(def *Definition, p *Placement, typeName string) {
+	if m := kaitaiBitType.FindStringSubmatch(typeName); m != nil {
+		bits := def.GetBitOrder()
+		if m[2] != "" {
+			bits = kaitaiBitOrder(m[2])
+		}
+		if bits != def.GetBitOrder() {
+			p.BitOrder = bitOrderp(bits)
+		}
+		// bit fields of whole bytes read in the natural byte order of their
+		// bits
+		if *p.Bits > 8 && *p.Bits%8 == 0 && bits.naturalByteOrder() != def.GetPlacementByteOrder(*p) {
+			p.ByteOrder = byteOrderp(bits.naturalByteOrder())
+		}
+		return
+	}
+
+	m := kaitaiIntType.FindStringSubmatch(typeName)
+	if m == nil {
+		m = kaitaiFloatType.FindStringSubmatch(typeName)
+	}
+	if m != nil && m[2] != "" && *p.Bits > 8 && kaitaiByteOrder(m[2]) != def.GetByteOrder() {
+		p.ByteOrder = byteOrderp(kaitaiByteOrder(m[2]))
+	}
+}
+
+func kaitaiByteOrder(endian string) ByteOrder {
+	if endian == "le" {
+		return ByteOrderLittleEndian
+	}
+	return ByteOrderBigEndian
+}
+
+func kaitaiBitOrder(endian string) BitOrder {
+	if endian == "le" {
+		return BitOrderLSBFirst
+	}
+	return BitOrderMSBFirst
+}
+
+// lookupKaitaiType finds a type by name from the innermost scope outwards,
+// returning the scopes it is nested in.
+func lookupKaitaiType(scopes []*kaitaiType, name string) (*kaitaiType, []*kaitaiType) {
+	path := strings.Split(name, "::")
+	for i := len(scopes) - 1; i >= 0; i-- {
+		found := append([]*kaitaiType(nil), scopes[:i+1]...)
+		t := scopes[i]
+		for _, n := range path {
+			t = t.Types[n]
+			if t == nil {
+				break
+			}
+			found = append(found, t)
+		}
+		if t != nil {
+			return t, found
+		}
+	}
+	return nil, nil
+}
+
+func lookupKaitaiEnum(scopes []*kaitaiType, name string) ([]ValueSpec, error) {
+	typePath, enumName := "", name
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		typePath, enumName = name[:i], name[i+2:]
+	}
+	if typePath != "" {
+		_, found := lookupKaitaiType(scopes, typePath)
+		if found == nil {
+			return nil, errors.Errorf("unknown enum %s", name)
+		}
+		scopes = found
+	}
+
+	for i := len(scopes) - 1; i >= 0; i-- {
+		enum, ok := scopes[i].Enums[enumName]
+		if !ok {
+			continue
+		}
+		values := make([]ValueSpec, 0, len(enum))
+		for _, item := range enum {
+			v, ok := kaitaiInteger(item.Key)
+			if !ok {
+				return nil, errors.Errorf("enum %s: invalid value %v", name, item.Key)
+			}
+			values = append(values, ValueSpec{Value: v, Label: kaitaiEnumLabel(item.Value)})
+		}
+		return values, nil
+	}
+	return nil, errors.Errorf("unknown enum %s", name)
+}
+
+// kaitaiEnumLabel reads an enum value given as an identifier or a map with
+// an id.
+func kaitaiEnumLabel(v interface{}) string {
+	m, ok := v.(yaml.MapSlice)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	label := ""
+	for _, item := range m {
+		switch item.Key {
+		case kaitaiOrigIDKey:
+			return fmt.Sprint(item.Value)
+		case "id":
+			label = fmt.Sprint(item.Value)
+		}
+	}
+	return label
+}
+
+func kaitaiInteger(v interface{}) (uint64, bool) {
+	switch n := v.(type) {
+	case int:
+		return uint64(n), n >= 0
+	case uint64:
+		return n, true
+	case string:
+		u, err := strconv.ParseUint(strings.ReplaceAll(n, "_", ""), 0, 64)
+		return u, err == nil
+	default:
+		return 0, false
+	}
+}
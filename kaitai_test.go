@@ -0,0 +1,271 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestExportKaitai(t *testing.T) {
+	t.Parallel()
+	def := &Definition{
+		Name: "Sample Header",
+		Placements: []Placement{
+			{Label: "Version", Bits: uintp(4)},
+			{Label: "flags", Bits: uintp(4)},
+			{Label: "Length", Bits: uintp(16)},
+			{Label: "Protocol", Bits: uintp(8), Values: []ValueSpec{{Value: 6, Label: "tcp"}, {Value: 17, Label: "UDP"}}},
+			{Label: "Address", Bits: uintp(128)},
+			{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320}},
+			{Label: "Payload", VariableLength: &VariableLengthPlacementSpec{MaxBits: 64}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := ExportKaitai(def, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `meta:
+  id: sample_header
+  title: Sample Header
+  endian: be
+  bit-endian: be
+params:
+- id: options_size
+  type: u4
+seq:
+- id: version
+  -orig-id: Version
+  type: b4
+- id: flags
+  type: b4
+- id: length
+  -orig-id: Length
+  type: u2
+- id: protocol
+  -orig-id: Protocol
+  type: u1
+  enum: protocol
+- id: address
+  -orig-id: Address
+  size: 16
+- id: options
+  -orig-id: Options
+  size: options_size
+- id: payload
+  -orig-id: Payload
+  size-eos: true
+enums:
+  protocol:
+    6: tcp
+    17:
+      id: udp
+      -orig-id: UDP
+`, buf.String())
+}
+
+func TestExportKaitaiErrors(t *testing.T) {
+	testData := []struct {
+		Name       string
+		Placements []Placement
+		Error      string
+	}{
+		{
+			Name:       "unaligned variable-length",
+			Placements: []Placement{{Label: "a", Bits: uintp(4)}, {Label: "b", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}}},
+			Error:      "placement b: Kaitai variable-length fields must start on a byte boundary",
+		},
+		{
+			Name:       "wide unaligned",
+			Placements: []Placement{{Label: "a", Bits: uintp(4)}, {Label: "b", Bits: uintp(68)}},
+			Error:      "placement b: Kaitai cannot read 68 bits unaligned to bytes",
+		},
+		{
+			Name:       "length field after",
+			Placements: []Placement{{Label: "a", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32, LengthField: "len"}}, {Label: "len", Bits: uintp(8)}},
+			Error:      "placement a: Kaitai reads the length field len after it",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			err := ExportKaitai(&Definition{Placements: tt.Placements}, &buf)
+			assert.EqualError(t, err, tt.Error)
+		})
+	}
+}
+
+func TestImportKaitai(t *testing.T) {
+	t.Parallel()
+	src := `meta:
+  id: frame
+  endian: be
+seq:
+  - id: magic
+    contents: [0xca, 0xfe]
+  - id: version
+    type: b4
+  - id: kind
+    type: b4
+    enum: kind
+  - id: src
+    type: addr
+  - id: hops
+    type: addr
+    repeat: expr
+    repeat-expr: 2
+  - id: len
+    type: u2le
+  - id: name
+    type: str
+    size: 8
+    encoding: ASCII
+  - id: body
+    size: len
+  - id: trailer
+    -orig-id: Trailer
+    size-eos: true
+types:
+  addr:
+    seq:
+      - id: hi
+        type: u2
+      - id: lo
+        type: u2
+enums:
+  kind:
+    1: data
+    0x2:
+      id: ack
+      -orig-id: ACK
+`
+	def, err := ImportKaitai(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Equal(t, &Definition{
+		Name: "frame",
+		Placements: []Placement{
+			{Label: "magic", Bits: uintp(16)},
+			{Label: "version", Bits: uintp(4)},
+			{Label: "kind", Bits: uintp(4), Values: []ValueSpec{{Value: 1, Label: "data"}, {Value: 2, Label: "ACK"}}},
+			{Label: "src.hi", Bits: uintp(16)},
+			{Label: "src.lo", Bits: uintp(16)},
+			{Label: "hops", Bits: uintp(64)},
+			{Label: "len", Bits: uintp(16), ByteOrder: byteOrderp(ByteOrderLittleEndian)},
+			{Label: "name", Bits: uintp(64)},
+			{Label: "body", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32, LengthField: "len"}},
+			{Label: "Trailer", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+		},
+	}, def)
+}
+
+func TestKaitaiRoundTrip(t *testing.T) {
+	t.Parallel()
+	def := &Definition{
+		Name: "Round Trip",
+		Placements: []Placement{
+			{Label: "Flags", Bits: uintp(3)},
+			{Label: "Fragment Offset", Bits: uintp(13), Values: []ValueSpec{{Value: 0, Label: "First"}}},
+			{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+			{Label: "Payload", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := ExportKaitai(def, &buf)
+	assert.NoError(t, err)
+	imported, err := ImportKaitai(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, def, imported)
+}
+
+func TestKaitaiRoundTripLengthField(t *testing.T) {
+	t.Parallel()
+	def := &Definition{
+		Name: "Length Field",
+		Placements: []Placement{
+			{Label: "len", Bits: uintp(8)},
+			{Label: "Value", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32, LengthField: "len"}},
+			{Label: "crc", Bits: uintp(16)},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := ExportKaitai(def, &buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "- id: value\n  -orig-id: Value\n  size: len\n")
+	assert.NotContains(t, buf.String(), "params")
+	imported, err := ImportKaitai(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, def, imported)
+}
+
+func TestKaitaiRoundTripOrders(t *testing.T) {
+	t.Parallel()
+	def := &Definition{
+		Name:     "USB Setup",
+		BitOrder: bitOrderp(BitOrderLSBFirst),
+		Placements: []Placement{
+			{Label: "Recipient", Bits: uintp(5)},
+			{Label: "Type", Bits: uintp(2)},
+			{Label: "Direction", Bits: uintp(1)},
+			{Label: "Value", Bits: uintp(16)},
+			{Label: "Wide", Bits: uintp(24)},
+			{Label: "Magic", Bits: uintp(32), ByteOrder: byteOrderp(ByteOrderBigEndian)},
+			{Label: "Signal", Bits: uintp(12), BitOrder: bitOrderp(BitOrderMSBFirst)},
+			{Label: "Flags", Bits: uintp(4), BitOrder: bitOrderp(BitOrderMSBFirst)},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := ExportKaitai(def, &buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "endian: le\n  bit-endian: le\n")
+	assert.Contains(t, buf.String(), "type: u4be\n")
+	assert.Contains(t, buf.String(), "type: b12be\n")
+	imported, err := ImportKaitai(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, def, imported)
+}
+
+func TestImportKaitaiErrors(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "unknown type",
+			Source: "seq:\n  - id: a\n    type: foo\n",
+			Error:  "a: unknown type foo",
+		},
+		{
+			Name:   "unknown enum",
+			Source: "seq:\n  - id: a\n    type: u1\n    enum: foo\n",
+			Error:  "a: unknown enum foo",
+		},
+		{
+			Name:   "recursive",
+			Source: "seq:\n  - id: a\n    type: node\ntypes:\n  node:\n    seq:\n      - id: next\n        type: node\n",
+			Error:  "type node contains itself",
+		},
+		{
+			Name:   "unsized",
+			Source: "seq:\n  - id: a\n    type: str\n",
+			Error:  "a: the size is not given",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ImportKaitai(strings.NewReader(tt.Source))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.Error)
+		})
+	}
+}
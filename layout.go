@@ -0,0 +1,85 @@
+package packetdiagram
+
+// layoutRow is a row of the diagram, holding the bits from offset on. An
+// elided row stands for the rows a variable-length placement leaves out,
+// which only it takes up.
+type layoutRow struct {
+	offset uint
+	elided bool
+}
+
+// layoutSegment is the part of a placement on a row of the diagram.
+type layoutSegment struct {
+	row  uint
+	x    uint
+	bits uint
+}
+
+// placementLayout is where a placement is drawn: from the bit at offset on,
+// over segments on consecutive rows. elided is the row of the diagram
+// standing for the bits it leaves out, or -1.
+type placementLayout struct {
+	offset   uint
+	segments []layoutSegment
+	elided   int
+}
+
+type diagramLayout struct {
+	rows       []layoutRow
+	placements []placementLayout
+}
+
+// getLayout lays the placements out one after another on rows, taking
+// variable-length ones to be as long as they may be. Those spanning more
+// rows than they are drawn with before the break and after it have the rows
+// in between left out for an elided row, so that the placements that follow
+// keep their offsets and their place in the rows.
+func (d *Definition) getLayout() diagramLayout {
+	bitsPerLine := d.GetBitsPerLine()
+	l := diagramLayout{
+		rows:       make([]layoutRow, 0),
+		placements: make([]placementLayout, 0, len(d.Placements)),
+	}
+
+	cur := &Cursor{x: 0, y: 0}
+	offset := uint(0)
+	for _, p := range d.Placements {
+		pl := placementLayout{offset: offset, segments: make([]layoutSegment, 0), elided: -1}
+		bitDistributions := getBitDistributions(bitsPerLine, cur, p)
+
+		// the rows between those before the break and the last one
+		from, to := len(bitDistributions), 0
+		if p.VariableLength != nil {
+			before := int(p.VariableLength.GetRowsBeforeBreak(bitsPerLine, cur.x))
+			if len(bitDistributions) > before+2 {
+				from, to = before, len(bitDistributions)-1
+			}
+		}
+
+		for i, bd := range bitDistributions {
+			if bd == 0 {
+				continue
+			}
+			if i > from && i < to {
+				offset += bd
+				continue
+			}
+			if uint(len(l.rows)) == cur.y {
+				l.rows = append(l.rows, layoutRow{offset: offset - cur.x, elided: i == from})
+			}
+			if i == from {
+				pl.elided = int(cur.y)
+			}
+			pl.segments = append(pl.segments, layoutSegment{row: cur.y, x: cur.x, bits: bd})
+
+			offset += bd
+			cur.x += bd
+			if cur.x == bitsPerLine {
+				cur.x = 0
+				cur.y++
+			}
+		}
+		l.placements = append(l.placements, pl)
+	}
+	return l
+}
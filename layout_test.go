@@ -0,0 +1,170 @@
+package packetdiagram
+
+import (
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestGetLayout(t *testing.T) {
+	testData := []struct {
+		Name       string
+		Placements []Placement
+		Rows       []layoutRow
+		Layouts    []placementLayout
+	}{
+		{
+			Name: "rows left out",
+			Placements: []Placement{
+				{Label: "Header", Bits: uintp(32)},
+				{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320}},
+			},
+			Rows: []layoutRow{{offset: 0}, {offset: 32}, {offset: 64, elided: true}, {offset: 320}},
+			Layouts: []placementLayout{
+				{offset: 0, segments: []layoutSegment{{row: 0, x: 0, bits: 32}}, elided: -1},
+				{offset: 32, segments: []layoutSegment{{row: 1, x: 0, bits: 32}, {row: 2, x: 0, bits: 32}, {row: 3, x: 0, bits: 32}}, elided: 2},
+			},
+		},
+		{
+			Name: "split over two rows",
+			Placements: []Placement{
+				{Label: "Kind", Bits: uintp(24)},
+				{Label: "Short", VariableLength: &VariableLengthPlacementSpec{MaxBits: 16}},
+				{Label: "After", Bits: uintp(24)},
+			},
+			Rows: []layoutRow{{offset: 0}, {offset: 32}},
+			Layouts: []placementLayout{
+				{offset: 0, segments: []layoutSegment{{row: 0, x: 0, bits: 24}}, elided: -1},
+				{offset: 24, segments: []layoutSegment{{row: 0, x: 24, bits: 8}, {row: 1, x: 0, bits: 8}}, elided: -1},
+				{offset: 40, segments: []layoutSegment{{row: 1, x: 8, bits: 24}}, elided: -1},
+			},
+		},
+		{
+			Name: "unaligned start and end",
+			Placements: []Placement{
+				{Label: "Kind", Bits: uintp(8)},
+				{Label: "Data", VariableLength: &VariableLengthPlacementSpec{MaxBits: 300}},
+				{Label: "CRC", Bits: uintp(16)},
+			},
+			Rows: []layoutRow{{offset: 0}, {offset: 32, elided: true}, {offset: 288}, {offset: 320}},
+			Layouts: []placementLayout{
+				{offset: 0, segments: []layoutSegment{{row: 0, x: 0, bits: 8}}, elided: -1},
+				{offset: 8, segments: []layoutSegment{{row: 0, x: 8, bits: 24}, {row: 1, x: 0, bits: 32}, {row: 2, x: 0, bits: 20}}, elided: 1},
+				{offset: 308, segments: []layoutSegment{{row: 2, x: 20, bits: 12}, {row: 3, x: 0, bits: 4}}, elided: -1},
+			},
+		},
+		{
+			Name: "rows before the break",
+			Placements: []Placement{
+				{Label: "Data", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320, RowsBeforeBreak: uintp(2)}},
+			},
+			Rows: []layoutRow{{offset: 0}, {offset: 32}, {offset: 64, elided: true}, {offset: 288}},
+			Layouts: []placementLayout{
+				{offset: 0, segments: []layoutSegment{{row: 0, x: 0, bits: 32}, {row: 1, x: 0, bits: 32}, {row: 2, x: 0, bits: 32}, {row: 3, x: 0, bits: 32}}, elided: 2},
+			},
+		},
+		{
+			Name: "rows of the fewest bits before the break",
+			Placements: []Placement{
+				{Label: "Kind", Bits: uintp(16)},
+				{Label: "Data", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320, MinBits: uintp(32)}},
+			},
+			Rows: []layoutRow{{offset: 0}, {offset: 32}, {offset: 64, elided: true}, {offset: 320}},
+			Layouts: []placementLayout{
+				{offset: 0, segments: []layoutSegment{{row: 0, x: 0, bits: 16}}, elided: -1},
+				{offset: 16, segments: []layoutSegment{{row: 0, x: 16, bits: 16}, {row: 1, x: 0, bits: 32}, {row: 2, x: 0, bits: 32}, {row: 3, x: 0, bits: 16}}, elided: 2},
+			},
+		},
+		{
+			Name: "nothing to leave out",
+			Placements: []Placement{
+				{Label: "Data", VariableLength: &VariableLengthPlacementSpec{MaxBits: 96}},
+			},
+			Rows: []layoutRow{{offset: 0}, {offset: 32}, {offset: 64}},
+			Layouts: []placementLayout{
+				{offset: 0, segments: []layoutSegment{{row: 0, x: 0, bits: 32}, {row: 1, x: 0, bits: 32}, {row: 2, x: 0, bits: 32}}, elided: -1},
+			},
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			l := (&Definition{Placements: data.Placements}).getLayout()
+			assert.Equal(t, data.Rows, l.rows)
+			assert.Equal(t, data.Layouts, l.placements)
+		})
+	}
+}
+
+func TestGetPlacementPolygons(t *testing.T) {
+	testData := []struct {
+		Name     string
+		Segments []layoutSegment
+		Expected []Polygon
+	}{
+		{
+			Name:     "single row",
+			Segments: []layoutSegment{{row: 1, x: 2, bits: 3}},
+			Expected: []Polygon{{xs: []int{20, 50, 50, 20, 20}, ys: []int{10, 10, 20, 20, 10}}},
+		},
+		{
+			Name:     "two rows apart",
+			Segments: []layoutSegment{{row: 0, x: 6, bits: 2}, {row: 1, x: 0, bits: 2}},
+			Expected: []Polygon{
+				{xs: []int{60, 80, 80, 60, 60}, ys: []int{0, 0, 10, 10, 0}},
+				{xs: []int{0, 20, 20, 0, 0}, ys: []int{10, 10, 20, 20, 10}},
+			},
+		},
+		{
+			Name:     "full rows after the first",
+			Segments: []layoutSegment{{row: 0, x: 2, bits: 6}, {row: 1, x: 0, bits: 8}, {row: 2, x: 0, bits: 8}},
+			Expected: []Polygon{{xs: []int{20, 80, 80, 0, 0, 20, 20}, ys: []int{0, 0, 30, 30, 10, 10, 0}}},
+		},
+		{
+			Name:     "full row before a partial one",
+			Segments: []layoutSegment{{row: 0, x: 0, bits: 8}, {row: 1, x: 0, bits: 8}, {row: 2, x: 0, bits: 3}},
+			Expected: []Polygon{{xs: []int{0, 80, 80, 30, 30, 0, 0}, ys: []int{0, 0, 20, 20, 30, 30, 0}}},
+		},
+		{
+			Name:     "overlapping rows",
+			Segments: []layoutSegment{{row: 0, x: 4, bits: 4}, {row: 1, x: 0, bits: 6}},
+			Expected: []Polygon{{xs: []int{40, 80, 80, 60, 60, 0, 0, 40, 40}, ys: []int{0, 0, 10, 10, 20, 20, 10, 10, 0}}},
+		},
+	}
+
+	def := &Definition{OctetsPerLine: uintp(1)}
+	dim := Dimensions{Cell: Dimension{Width: 10, Height: 10}}
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+			polygons := getPlacementPolygons(def, dim, placementLayout{segments: data.Segments, elided: -1})
+			assert.Equal(t, data.Expected, polygons)
+		})
+	}
+}
+
+func TestVariableLengthAxisLabels(t *testing.T) {
+	t.Parallel()
+	def := &Definition{
+		YAxis: YAxisSpec{Bits: &YAxisBitsSpec{}, Octets: &YAxisOctetsSpec{}},
+		Placements: []Placement{
+			{Label: "Kind", Bits: uintp(8)},
+			{Label: "Data", VariableLength: &VariableLengthPlacementSpec{MaxBits: 300}},
+			{Label: "CRC", Bits: uintp(16)},
+		},
+	}
+	dim := Dimensions{Cell: Dimension{Width: 10, Height: 10}}
+
+	_, ys, labels := calculateYAxisBitLabelDimensions(def, dim)
+	assert.Equal(t, []int{0, 10, 20, 30}, ys)
+	assert.Equal(t, []string{"0", "︙", "288", "320"}, labels)
+
+	_, _, labels = calculateYAxisOctetLabelDimensions(def, dim)
+	assert.Equal(t, []string{"0", "︙", "36", "40"}, labels)
+
+	assert.Equal(t, uint(4), def.GetTotalRows())
+}
@@ -0,0 +1,293 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// definitionFile is a document of a definition file. Its own settings
+// are shared by the diagrams listed under `diagrams`; a file may also hold
+// several documents separated by `---`, in which case a document without
+// placements provides defaults for the documents following it.
+type definitionFile struct {
+	Import     importList `yaml:"import,omitempty"`
+	Include    importList `yaml:"include,omitempty"`
+	Definition `yaml:",inline"`
+	Structures map[string][]Placement `yaml:"structures,omitempty"`
+	Diagrams   namedDefinitions       `yaml:"diagrams,omitempty"`
+}
+
+// namedDefinitions is a mapping from diagram names to definitions that
+// keeps the order the diagrams are written in.
+type namedDefinitions []*Definition
+
+func (n *namedDefinitions) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items yaml.MapSlice
+	err := unmarshal(&items)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		b, err := yaml.Marshal(item.Value)
+		if err != nil {
+			return err
+		}
+
+		var def Definition
+		err = yaml.Unmarshal(b, &def)
+		if err != nil {
+			return errors.Wrapf(err, "diagram %v", item.Key)
+		}
+		def.Name = fmt.Sprint(item.Key)
+		*n = append(*n, &def)
+	}
+	return nil
+}
+
+func (n namedDefinitions) MarshalYAML() (interface{}, error) {
+	items := make(yaml.MapSlice, 0, len(n))
+	for _, def := range n {
+		d := *def
+		d.Name = ""
+		items = append(items, yaml.MapItem{Key: def.Name, Value: d})
+	}
+	return items, nil
+}
+
+func (namedDefinitions) extendSchema(schema map[string]interface{}) {
+	delete(schema, "items")
+	schema["type"] = "object"
+	schema["additionalProperties"] = typeSchema(reflect.TypeOf(Definition{}))
+}
+
+// LoadDefinitions reads every diagram of a YAML definition file, with the
+// shared settings of the file applied to each of them. Imports are resolved
+// relative to the working directory; use LoadDefinitionsFile to resolve them
+// relative to the file.
+func LoadDefinitions(r io.Reader) ([]*Definition, error) {
+	return loadDefinitionsFrom(r, nil, false)
+}
+
+// LoadDefinitionsJSON is like LoadDefinitions for JSON definition files.
+func LoadDefinitionsJSON(r io.Reader) ([]*Definition, error) {
+	return loadDefinitionsFrom(r, yamlFromJSON, false)
+}
+
+// LoadDefinitionsTOML is like LoadDefinitions for TOML definition files.
+func LoadDefinitionsTOML(r io.Reader) ([]*Definition, error) {
+	return loadDefinitionsFrom(r, yamlFromTOML, false)
+}
+
+// LoadDefinitionsUntrusted is like LoadDefinitions but refuses imports, so
+// that definitions from untrusted sources cannot read local files.
+func LoadDefinitionsUntrusted(r io.Reader) ([]*Definition, error) {
+	return loadDefinitionsFrom(r, nil, true)
+}
+
+// LoadDefinitionsUntrustedJSON is like LoadDefinitionsUntrusted for JSON
+// definition files.
+func LoadDefinitionsUntrustedJSON(r io.Reader) ([]*Definition, error) {
+	return loadDefinitionsFrom(r, yamlFromJSON, true)
+}
+
+// LoadDefinitionsUntrustedTOML is like LoadDefinitionsUntrusted for TOML
+// definition files.
+func LoadDefinitionsUntrustedTOML(r io.Reader) ([]*Definition, error) {
+	return loadDefinitionsFrom(r, yamlFromTOML, true)
+}
+
+// loadDefinitionsFrom reads a definition file, turned into YAML by toYAML
+// unless it is YAML already.
+func loadDefinitionsFrom(r io.Reader, toYAML func([]byte) ([]byte, error), untrusted bool) ([]*Definition, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if toYAML != nil {
+		b, err = toYAML(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	imp := newImporter()
+	imp.disabled = untrusted
+	return imp.loadDefinitions(b, ".")
+}
+
+func (imp *importer) loadDefinitions(b []byte, dir string) ([]*Definition, error) {
+	f, err := imp.parseFile(b, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := f.defs
+	if len(defs) == 0 {
+		// a file without any placements still makes an (empty) diagram
+		defs = append(defs, f.defaults)
+	}
+
+	names := map[string]struct{}{}
+	for i, def := range defs {
+		if def.Name == "" && len(defs) > 1 {
+			// unnamed documents of a stream are known by their position
+			def.Name = fmt.Sprint(f.docs[i])
+		}
+		if _, dup := names[def.Name]; dup && def.Name != "" {
+			return nil, errors.Errorf("diagram %s is defined more than once", def.Name)
+		}
+		names[def.Name] = struct{}{}
+
+		if !imp.skip {
+			def.Placements, err = expandStructures(def.Placements, f.structures, nil)
+		}
+		if err == nil {
+			err = def.validate()
+		}
+		if err != nil {
+			if def.Name != "" {
+				return nil, errors.Wrapf(err, "diagram %s", def.Name)
+			}
+			return nil, err
+		}
+	}
+
+	return defs, nil
+}
+
+// parsedFile holds the documents of a definition file before their
+// structures are expanded.
+type parsedFile struct {
+	defs []*Definition
+	docs []int // the document each diagram comes from
+
+	// defaults are the settings of the documents without placements
+	defaults   *Definition
+	structures map[string][]Placement
+}
+
+func (imp *importer) parseFile(b []byte, dir string) (*parsedFile, error) {
+	f := &parsedFile{
+		defs:       make([]*Definition, 0),
+		docs:       make([]int, 0),
+		defaults:   &Definition{},
+		structures: map[string][]Placement{},
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for doc := 1; ; doc++ {
+		var d definitionFile
+		err := dec.Decode(&d)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lib, err := imp.importFiles(dir, append(d.Import, d.Include...))
+		if err != nil {
+			return nil, err
+		}
+		for name, s := range lib.structures {
+			f.structures[name] = s
+		}
+		for name, s := range d.Structures {
+			f.structures[name] = s
+		}
+		applyDefinitionDefaults(&d.Definition, lib.defaults)
+
+		switch {
+		case len(d.Diagrams) > 0 && len(d.Placements) > 0:
+			return nil, errors.Errorf("document %d: `placements` and `diagrams` cannot be given together", doc)
+		case len(d.Diagrams) > 0:
+			shared := d.Definition
+			applyDefinitionDefaults(&shared, f.defaults)
+			for _, def := range d.Diagrams {
+				applyDefinitionDefaults(def, &shared)
+				f.defs = append(f.defs, def)
+				f.docs = append(f.docs, doc)
+			}
+		case len(d.Placements) > 0:
+			def := d.Definition
+			applyDefinitionDefaults(&def, f.defaults)
+			f.defs = append(f.defs, &def)
+			f.docs = append(f.docs, doc)
+		default:
+			applyDefinitionDefaults(&d.Definition, f.defaults)
+			f.defaults = &d.Definition
+		}
+	}
+
+	return f, nil
+}
+
+// SelectDefinition picks the diagram called name, or the only diagram there
+// is when name is empty.
+func SelectDefinition(defs []*Definition, name string) (*Definition, error) {
+	if name == "" {
+		if len(defs) == 1 {
+			return defs[0], nil
+		}
+		return nil, errors.Errorf("the file holds %d diagrams (%s); choose one by name", len(defs), strings.Join(definitionNames(defs), ", "))
+	}
+
+	for _, def := range defs {
+		if def.Name == name {
+			return def, nil
+		}
+	}
+	return nil, errors.Errorf("no diagram named %s; there are %s", name, strings.Join(definitionNames(defs), ", "))
+}
+
+func definitionNames(defs []*Definition) []string {
+	names := make([]string, len(defs))
+	for i, def := range defs {
+		names[i] = def.Name
+	}
+	return names
+}
+
+// applyDefinitionDefaults fills the settings def leaves out with those of
+// defaults. Names and placements are never inherited.
+func applyDefinitionDefaults(def, defaults *Definition) {
+	d := *defaults
+	d.Name = ""
+	d.Placements = nil
+	applyDefaults(reflect.ValueOf(def).Elem(), reflect.ValueOf(&d).Elem())
+}
+
+// applyDefaults recursively sets the zero fields of dst to the values in
+// src, copying pointed-to structs rather than sharing them.
+func applyDefaults(dst, src reflect.Value) {
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if !dst.IsNil() && dst.Elem().Kind() != reflect.Struct {
+			// given explicitly, even if it is the zero value
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		applyDefaults(dst.Elem(), src.Elem())
+	case reflect.Struct:
+		for i := 0; i < dst.NumField(); i++ {
+			if dst.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			applyDefaults(dst.Field(i), src.Field(i))
+		}
+	default:
+		if dst.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package packetdiagram
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestLoadDefinitions(t *testing.T) {
+	testData := []struct {
+		Name     string
+		Source   string
+		Expected []*Definition
+		Error    bool
+	}{
+		{
+			Name: "single document",
+			Source: `
+placements:
+  - label: Type
+    bits: 8
+`,
+			Expected: []*Definition{
+				{Placements: []Placement{{Label: "Type", Bits: uintp(8)}}},
+			},
+		},
+		{
+			Name: "stream with shared defaults",
+			Source: `
+octets-per-line: 2
+---
+name: a
+placements:
+  - label: A
+    bits: 8
+---
+octets-per-line: 1
+placements:
+  - label: B
+    bits: 8
+`,
+			Expected: []*Definition{
+				{Name: "a", OctetsPerLine: uintp(2), Placements: []Placement{{Label: "A", Bits: uintp(8)}}},
+				{Name: "3", OctetsPerLine: uintp(1), Placements: []Placement{{Label: "B", Bits: uintp(8)}}},
+			},
+		},
+		{
+			Name: "diagrams map",
+			Source: `
+octets-per-line: 2
+x-axis:
+  bits:
+    origin: 1
+diagrams:
+  b:
+    placements:
+      - label: B
+        bits: 8
+  a:
+    x-axis:
+      bits:
+        origin: 0
+    placements:
+      - label: A
+        bits: 8
+`,
+			Expected: []*Definition{
+				{
+					Name:          "b",
+					OctetsPerLine: uintp(2),
+					XAxis:         XAxisSpec{Bits: &XAxisBitsSpec{Origin: uintp(1)}},
+					Placements:    []Placement{{Label: "B", Bits: uintp(8)}},
+				},
+				{
+					Name:          "a",
+					OctetsPerLine: uintp(2),
+					XAxis:         XAxisSpec{Bits: &XAxisBitsSpec{Origin: uintp(0)}},
+					Placements:    []Placement{{Label: "A", Bits: uintp(8)}},
+				},
+			},
+		},
+		{
+			Name: "placements and diagrams together",
+			Source: `
+placements:
+  - label: A
+    bits: 8
+diagrams:
+  b:
+    placements:
+      - label: B
+        bits: 8
+`,
+			Error: true,
+		},
+		{
+			Name: "duplicate names",
+			Source: `
+name: a
+placements:
+  - label: A
+    bits: 8
+---
+name: a
+placements:
+  - label: B
+    bits: 8
+`,
+			Error: true,
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := LoadDefinitions(strings.NewReader(tt.Source))
+			if tt.Error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.Expected, actual)
+		})
+	}
+}
+
+func TestLoadDefinitionsJSONAndTOML(t *testing.T) {
+	reset := uint64(0x7fffffffffffffff)
+	expected := []*Definition{
+		{
+			Name:          "b",
+			OctetsPerLine: uintp(2),
+			Placements:    []Placement{{Label: "B", Bits: uintp(64), Reset: &reset}},
+		},
+		{
+			Name:          "a",
+			OctetsPerLine: uintp(1),
+			Placements:    []Placement{{Label: "A", Bits: uintp(8)}},
+		},
+	}
+	testData := []struct {
+		Name   string
+		Load   func(io.Reader) ([]*Definition, error)
+		Source string
+		Error  string
+	}{
+		{
+			Name: "JSON",
+			Load: LoadDefinitionsJSON,
+			Source: `{
+  "octets-per-line": 2,
+  "diagrams": {
+    "b": {"placements": [{"label": "B", "bits": 64, "reset": 9223372036854775807}]},
+    "a": {"octets-per-line": 1, "placements": [{"label": "A", "bits": 8}]}
+  }
+}`,
+		},
+		{
+			Name: "TOML",
+			Load: LoadDefinitionsTOML,
+			Source: `
+octets-per-line = 2
+
+[[diagrams.b.placements]]
+label = "B"
+bits = 64
+reset = 0x7fffffffffffffff
+
+[diagrams.a]
+octets-per-line = 1
+
+[[diagrams.a.placements]]
+label = "A"
+bits = 8
+`,
+		},
+		{
+			Name:   "unknown JSON key",
+			Load:   LoadDefinitionsJSON,
+			Source: `{"diagram": {"a": {"placements": [{"label": "A", "bits": 8}]}}}`,
+			Error:  "unknown key `diagram`",
+		},
+		{
+			Name:   "unknown TOML key",
+			Load:   LoadDefinitionsTOML,
+			Source: "octets-per-row = 2\n",
+			Error:  "unknown key `octets-per-row`",
+		},
+		{
+			Name:   "JSON array",
+			Load:   LoadDefinitionsJSON,
+			Source: `[]`,
+			Error:  "a definition must be an object",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := tt.Load(strings.NewReader(tt.Source))
+			if tt.Error != "" {
+				assert.EqualError(t, err, tt.Error)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestSelectDefinition(t *testing.T) {
+	t.Parallel()
+	defs := []*Definition{{Name: "a"}, {Name: "b"}}
+
+	_, err := SelectDefinition(defs, "")
+	assert.Error(t, err)
+
+	def, err := SelectDefinition(defs, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, defs[1], def)
+
+	_, err = SelectDefinition(defs, "c")
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package packetdiagram
+
+import (
+	"github.com/pkg/errors"
+)
+
+// OffsetsSpec annotates every placement with where it starts and ends, in
+// small text at the top corners of its box.
+type OffsetsSpec struct {
+	Show   *bool            `yaml:"show,omitempty" json:"show,omitempty" toml:"show,omitempty"`
+	Unit   *OffsetUnit      `yaml:"unit,omitempty" json:"unit,omitempty" toml:"unit,omitempty"`
+	Format *AxisLabelFormat `yaml:"format,omitempty" json:"format,omitempty" toml:"format,omitempty"`
+}
+
+// OffsetUnit is what offset annotations count.
+type OffsetUnit string
+
+const (
+	OffsetUnitBit  OffsetUnit = "bit"  // the first and last bit, numbered as the x-axis does
+	OffsetUnitByte OffsetUnit = "byte" // the first and last byte
+)
+
+func (OffsetUnit) schemaEnum() []string {
+	return []string{
+		string(OffsetUnitBit),
+		string(OffsetUnitByte),
+	}
+}
+
+const defaultOffsetUnit = OffsetUnitBit
+
+func (d *Definition) ShouldShowOffsets() bool {
+	if d.Offsets == nil {
+		return false
+	}
+
+	if d.Offsets.Show == nil {
+		return true
+	}
+
+	return *d.Offsets.Show
+}
+
+func (d *Definition) GetOffsetUnit() OffsetUnit {
+	if d.Offsets == nil || d.Offsets.Unit == nil {
+		return defaultOffsetUnit
+	}
+	return *d.Offsets.Unit
+}
+
+func (d *Definition) GetOffsetFormat() AxisLabelFormat {
+	if d.Offsets == nil {
+		return defaultAxisLabelFormat
+	}
+	return getAxisLabelFormat(d.Offsets.Format)
+}
+
+// getBitNumber numbers the bit offset bits into the diagram the way the
+// x-axis numbers the bits of a line, counting on across units and lines, so
+// that right-to-left and register numbering run high to low within a unit.
+func (d *Definition) getBitNumber(offset uint) uint {
+	if d.IsRegisterMode() {
+		w := d.GetBitsPerLine()
+		return offset/w*w + w - 1 - offset%w
+	}
+	u := uint(d.GetXAxisBitsUnit())
+	base := offset/u*u + d.GetXAxisBitsOrigin()
+	if d.GetXAxisBitsDirection() == XAxisBitsDirectionLeftToRight {
+		return base + offset%u
+	}
+	return base + u - 1 - offset%u
+}
+
+// getOffsetLabels returns the annotations of a placement of bits bits
+// starting offset bits into the diagram: the first bit or byte for its top
+// left corner and the last one for its top right corner. The last is empty
+// when it is the same as the first, and for variable-length placements, as
+// where they end is not known.
+func (d *Definition) getOffsetLabels(offset, bits uint, variable bool) (first, last string) {
+	format := d.GetOffsetFormat()
+	end := offset + bits - 1
+	if bits == 0 {
+		end = offset
+	}
+
+	switch {
+	case d.GetOffsetUnit() == OffsetUnitByte && format == AxisLabelFormatByteBit:
+		// the bit within the byte is worth telling
+		first = formatAxisLabel(offset, 1, format)
+		last = formatAxisLabel(end, 1, format)
+	case d.GetOffsetUnit() == OffsetUnitByte:
+		first = formatAxisLabel(offset/8, 8, format)
+		last = formatAxisLabel(end/8, 8, format)
+	default:
+		first = formatAxisLabel(d.getBitNumber(offset), 1, format)
+		last = formatAxisLabel(d.getBitNumber(end), 1, format)
+	}
+	if variable || last == first {
+		last = ""
+	}
+	return
+}
+
+func (d *Definition) validateOffsets() error {
+	if d.Offsets == nil {
+		return nil
+	}
+	if u := d.Offsets.Unit; u != nil && *u != OffsetUnitBit && *u != OffsetUnitByte {
+		return errors.Errorf("offsets: unsupported unit: %s", *u)
+	}
+	if f := d.Offsets.Format; f != nil {
+		switch *f {
+		case AxisLabelFormatDecimal, AxisLabelFormatHex, AxisLabelFormatOctal, AxisLabelFormatByteBit:
+		default:
+			return errors.Errorf("offsets: unsupported label format: %s", *f)
+		}
+	}
+	return nil
+}
+
+// drawPlacementOffsets writes the first offset of a placement at the top
+// left corner of its first polygon and the last one at the top right corner
+// of its last polygon.
+func drawPlacementOffsets(def *Definition, first, last string, polygons []Polygon, canvas surface) {
+	size := int(def.GetAxisTitleTextSizeInPixels())
+	left, top := polygons[0].topLeftCorner()
+	canvas.Text(left+3, top+size+2, first, `class="placement-offset-start"`)
+	if last != "" {
+		right, top := polygons[len(polygons)-1].topRightCorner()
+		canvas.Text(right-3, top+size+2, last, `class="placement-offset-end"`)
+	}
+}
@@ -0,0 +1,105 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestGetOffsetLabels(t *testing.T) {
+	rtl := XAxisBitsDirectionRightToLeft
+	unit8 := XAxisBitsUnit(8)
+	byteUnit := OffsetUnitByte
+	hex, byteBit := AxisLabelFormatHex, AxisLabelFormatByteBit
+	register := ModeRegister
+
+	testData := []struct {
+		Name     string
+		Def      *Definition
+		Offset   uint
+		Bits     uint
+		Variable bool
+		First    string
+		Last     string
+	}{
+		{"bits", &Definition{}, 96, 4, false, "96", "99"},
+		{"single bit", &Definition{}, 5, 1, false, "5", ""},
+		{"variable length", &Definition{}, 64, 128, true, "64", ""},
+		{"hex", &Definition{Offsets: &OffsetsSpec{Format: &hex}}, 96, 4, false, "0x60", "0x63"},
+		{"origin", &Definition{XAxis: XAxisSpec{Bits: &XAxisBitsSpec{Origin: uintp(1)}}}, 0, 8, false, "1", "8"},
+		{"right to left", &Definition{XAxis: XAxisSpec{Bits: &XAxisBitsSpec{Direction: &rtl, Unit: &unit8}}}, 8, 4, false, "15", "12"},
+		{"register", &Definition{Mode: &register}, 32, 8, false, "63", "56"},
+		{"bytes", &Definition{Offsets: &OffsetsSpec{Unit: &byteUnit}}, 16, 32, false, "2", "5"},
+		{"within a byte", &Definition{Offsets: &OffsetsSpec{Unit: &byteUnit}}, 20, 4, false, "2", ""},
+		{"bytes and bits", &Definition{Offsets: &OffsetsSpec{Unit: &byteUnit, Format: &byteBit}}, 20, 6, false, "2.4", "3.1"},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+			first, last := data.Def.getOffsetLabels(data.Offset, data.Bits, data.Variable)
+			assert.Equal(t, data.First, first)
+			assert.Equal(t, data.Last, last)
+		})
+	}
+}
+
+func TestDrawOffsets(t *testing.T) {
+	t.Parallel()
+	placements := []Placement{
+		{Label: "a", Bits: uintp(8)},
+		{Label: "b", Bits: uintp(40)},
+	}
+
+	var plain bytes.Buffer
+	err := Draw(&Definition{Placements: placements}, &plain)
+	assert.NoError(t, err)
+	assert.NotContains(t, plain.String(), `placement-offset`)
+
+	var buf bytes.Buffer
+	err = Draw(&Definition{Offsets: &OffsetsSpec{}, Placements: placements}, &buf)
+	assert.NoError(t, err)
+	svg := buf.String()
+	assert.Contains(t, svg, `class="placement-offset-start" >0</text>`)
+	assert.Contains(t, svg, `class="placement-offset-end" >7</text>`)
+	assert.Contains(t, svg, `class="placement-offset-start" >8</text>`)
+	assert.Contains(t, svg, `class="placement-offset-end" >47</text>`)
+
+	show := false
+	buf.Reset()
+	err = Draw(&Definition{Offsets: &OffsetsSpec{Show: &show}, Placements: placements}, &buf)
+	assert.NoError(t, err)
+	assert.NotContains(t, buf.String(), `class="placement-offset`)
+}
+
+func TestValidateOffsets(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "unknown unit",
+			Source: "offsets:\n  unit: word\nplacements:\n  - label: a\n    bits: 8\n",
+			Error:  "offsets: unsupported unit: word",
+		},
+		{
+			Name:   "unknown format",
+			Source: "offsets:\n  format: roman\nplacements:\n  - label: a\n    bits: 8\n",
+			Error:  "offsets: unsupported label format: roman",
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadDefinition(strings.NewReader(data.Source))
+			assert.EqualError(t, err, data.Error)
+		})
+	}
+}
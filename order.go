@@ -0,0 +1,223 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ByteOrder is the order of the bytes of a field of more than one byte.
+type ByteOrder string
+
+const (
+	ByteOrderBigEndian    ByteOrder = "big-endian"
+	ByteOrderLittleEndian ByteOrder = "little-endian"
+)
+
+func (ByteOrder) schemaEnum() []string {
+	return []string{
+		string(ByteOrderBigEndian),
+		string(ByteOrderLittleEndian),
+	}
+}
+
+// BitOrder is the order in which fields fill a byte: from its most
+// significant bit, bit 0 being the MSB as in network protocols, or from its
+// least significant one, bit 0 being the LSB as in USB and CAN (Intel)
+// signals.
+type BitOrder string
+
+const (
+	BitOrderMSBFirst BitOrder = "msb-first"
+	BitOrderLSBFirst BitOrder = "lsb-first"
+)
+
+func (BitOrder) schemaEnum() []string {
+	return []string{
+		string(BitOrderMSBFirst),
+		string(BitOrderLSBFirst),
+	}
+}
+
+// naturalByteOrder is the byte order fields take when their bits are simply
+// laid out in the bit order: a field that runs over several bytes has its
+// most significant bits in the first byte when they are msb-first and in the
+// last one when they are lsb-first.
+func (o BitOrder) naturalByteOrder() ByteOrder {
+	if o == BitOrderLSBFirst {
+		return ByteOrderLittleEndian
+	}
+	return ByteOrderBigEndian
+}
+
+func byteOrderp(o ByteOrder) *ByteOrder {
+	return &o
+}
+
+func bitOrderp(o BitOrder) *BitOrder {
+	return &o
+}
+
+// GetByteOrder returns the byte order of the fields, which is big-endian
+// unless bits are lsb-first.
+func (d *Definition) GetByteOrder() ByteOrder {
+	if d.ByteOrder == nil {
+		return d.GetBitOrder().naturalByteOrder()
+	}
+	return *d.ByteOrder
+}
+
+func (d *Definition) GetBitOrder() BitOrder {
+	if d.BitOrder == nil {
+		return BitOrderMSBFirst
+	}
+	return *d.BitOrder
+}
+
+func (d *Definition) GetPlacementByteOrder(p Placement) ByteOrder {
+	if p.ByteOrder == nil {
+		return d.GetByteOrder()
+	}
+	return *p.ByteOrder
+}
+
+func (d *Definition) GetPlacementBitOrder(p Placement) BitOrder {
+	if p.BitOrder == nil {
+		return d.GetBitOrder()
+	}
+	return *p.BitOrder
+}
+
+// HasOrders tells whether the definition or any of its placements sets a
+// byte or bit order.
+func (d *Definition) HasOrders() bool {
+	if d.ByteOrder != nil || d.BitOrder != nil {
+		return true
+	}
+	for _, p := range d.Placements {
+		if p.ByteOrder != nil || p.BitOrder != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldOrder is how the bits of a fixed-size field are laid out: in the bit
+// order, with the bytes holding the field read little- or big-endian. Byte
+// orders other than the natural one of the bit order apply only to whole
+// bytes on byte boundaries, which are read in the byte order given; any
+// other field takes the natural one.
+type fieldOrder struct {
+	bits         BitOrder
+	littleEndian bool
+}
+
+// swapsBytes tells whether the bytes of the field are in the order other
+// than the natural one of its bits, which encoders do by reversing them.
+func (o fieldOrder) swapsBytes() bool {
+	return o.littleEndian != (o.bits.naturalByteOrder() == ByteOrderLittleEndian)
+}
+
+// getFieldOrders returns the order of each placement, laid out one after
+// another with variable-length fields taken to be whole bytes. Those and
+// fields of more than 64 bits on byte boundaries are strings of bytes, which
+// go out as they are.
+func (d *Definition) getFieldOrders() []fieldOrder {
+	orders := make([]fieldOrder, len(d.Placements))
+	bit := uint(0) // within the current byte
+	for i, p := range d.Placements {
+		if p.Bits == nil || (bit == 0 && *p.Bits > 64 && *p.Bits%8 == 0) {
+			orders[i] = fieldOrder{bits: BitOrderMSBFirst}
+			if p.Bits == nil {
+				bit = 0
+			}
+			continue
+		}
+
+		n := *p.Bits
+		bits := d.GetPlacementBitOrder(p)
+		byteOrder := bits.naturalByteOrder()
+		if bit == 0 && n > 8 && n%8 == 0 {
+			byteOrder = d.GetPlacementByteOrder(p)
+		}
+		bit = (bit + n) % 8
+		orders[i] = fieldOrder{bits: bits, littleEndian: byteOrder == ByteOrderLittleEndian}
+	}
+	return orders
+}
+
+func (d *Definition) validateOrders() error {
+	if d.ByteOrder != nil && !isValidByteOrder(*d.ByteOrder) {
+		return errors.Errorf("unsupported byte order: %s", *d.ByteOrder)
+	}
+	if d.BitOrder != nil && !isValidBitOrder(*d.BitOrder) {
+		return errors.Errorf("unsupported bit order: %s", *d.BitOrder)
+	}
+
+	bit := uint(0) // within the current byte
+	var last BitOrder
+	for _, p := range d.Placements {
+		if p.ByteOrder != nil && !isValidByteOrder(*p.ByteOrder) {
+			return errors.Errorf("placement %s: unsupported byte order: %s", p.GetKey(), *p.ByteOrder)
+		}
+		if p.BitOrder != nil && !isValidBitOrder(*p.BitOrder) {
+			return errors.Errorf("placement %s: unsupported bit order: %s", p.GetKey(), *p.BitOrder)
+		}
+		if p.Bits == nil {
+			if p.ByteOrder != nil || p.BitOrder != nil {
+				return errors.Errorf("placement %s: only fields with `bits` can have a byte or bit order", p.GetKey())
+			}
+			bit, last = 0, ""
+			continue
+		}
+
+		n := *p.Bits
+		bits := d.GetPlacementBitOrder(p)
+		if bit != 0 && last != "" && bits != last {
+			return errors.Errorf("placement %s: the bit order can only change on a byte boundary", p.GetKey())
+		}
+		if p.ByteOrder != nil && *p.ByteOrder != bits.naturalByteOrder() && n > 8 && (bit != 0 || n%8 != 0) {
+			return errors.Errorf("placement %s: %s fields whose bits are %s must be whole bytes on a byte boundary", p.GetKey(), *p.ByteOrder, bits)
+		}
+		bit = (bit + n) % 8
+		last = bits
+	}
+	return nil
+}
+
+func isValidByteOrder(o ByteOrder) bool {
+	return o == ByteOrderBigEndian || o == ByteOrderLittleEndian
+}
+
+func isValidBitOrder(o BitOrder) bool {
+	return o == BitOrderMSBFirst || o == BitOrderLSBFirst
+}
+
+// getOrderCaption describes the byte and bit order of the definition for the
+// header of the diagram, followed by the placements that differ from it.
+// It is empty when no order is set, as diagrams did not say before.
+func getOrderCaption(def *Definition) string {
+	if !def.HasOrders() {
+		return ""
+	}
+
+	caption := fmt.Sprintf("byte order: %s, bit order: %s", def.GetByteOrder(), def.GetBitOrder())
+	overrides := make([]string, 0)
+	for _, p := range def.Placements {
+		orders := make([]string, 0, 2)
+		if p.ByteOrder != nil && *p.ByteOrder != def.GetByteOrder() {
+			orders = append(orders, string(*p.ByteOrder))
+		}
+		if p.BitOrder != nil && *p.BitOrder != def.GetBitOrder() {
+			orders = append(orders, string(*p.BitOrder))
+		}
+		if len(orders) > 0 {
+			overrides = append(overrides, fmt.Sprintf("%s %s", p.Label, strings.Join(orders, " ")))
+		}
+	}
+	if len(overrides) > 0 {
+		caption += " (" + strings.Join(overrides, ", ") + ")"
+	}
+	return caption
+}
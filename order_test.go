@@ -0,0 +1,131 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestGetFieldOrders(t *testing.T) {
+	t.Parallel()
+	def := &Definition{
+		ByteOrder: byteOrderp(ByteOrderLittleEndian),
+		Placements: []Placement{
+			{Label: "a", Bits: uintp(4)},
+			{Label: "b", Bits: uintp(12)},
+			{Label: "c", Bits: uintp(16)},
+			{Label: "d", Bits: uintp(16), ByteOrder: byteOrderp(ByteOrderBigEndian)},
+			{Label: "e", Bits: uintp(8), BitOrder: bitOrderp(BitOrderLSBFirst)},
+			{Label: "f", Bits: uintp(16), BitOrder: bitOrderp(BitOrderLSBFirst)},
+			{Label: "g", Bits: uintp(128)},
+			{Label: "h", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+		},
+	}
+	assert.Equal(t, []fieldOrder{
+		{bits: BitOrderMSBFirst},
+		{bits: BitOrderMSBFirst},
+		{bits: BitOrderMSBFirst, littleEndian: true},
+		{bits: BitOrderMSBFirst},
+		{bits: BitOrderLSBFirst, littleEndian: true},
+		{bits: BitOrderLSBFirst, littleEndian: true},
+		{bits: BitOrderMSBFirst},
+		{bits: BitOrderMSBFirst},
+	}, def.getFieldOrders())
+}
+
+func TestGetByteOrder(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, ByteOrderBigEndian, (&Definition{}).GetByteOrder())
+	assert.Equal(t, ByteOrderLittleEndian, (&Definition{BitOrder: bitOrderp(BitOrderLSBFirst)}).GetByteOrder())
+	assert.Equal(t, ByteOrderBigEndian, (&Definition{BitOrder: bitOrderp(BitOrderLSBFirst), ByteOrder: byteOrderp(ByteOrderBigEndian)}).GetByteOrder())
+}
+
+func TestValidateOrders(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "unknown byte order",
+			Source: "byte-order: middle-endian\nplacements:\n  - label: a\n    bits: 8\n",
+			Error:  "unsupported byte order: middle-endian",
+		},
+		{
+			Name:   "unknown bit order",
+			Source: "placements:\n  - label: a\n    bits: 8\n    bit-order: random\n",
+			Error:  "placement a: unsupported bit order: random",
+		},
+		{
+			Name:   "variable-length",
+			Source: "placements:\n  - label: a\n    variable-length:\n      max-bits: 8\n    byte-order: little-endian\n",
+			Error:  "placement a: only fields with `bits` can have a byte or bit order",
+		},
+		{
+			Name:   "bit order changing within a byte",
+			Source: "placements:\n  - label: a\n    bits: 4\n  - label: b\n    bits: 4\n    bit-order: lsb-first\n",
+			Error:  "placement b: the bit order can only change on a byte boundary",
+		},
+		{
+			Name:   "little-endian bits",
+			Source: "placements:\n  - label: a\n    bits: 12\n    byte-order: little-endian\n",
+			Error:  "placement a: little-endian fields whose bits are msb-first must be whole bytes on a byte boundary",
+		},
+		{
+			Name:   "unaligned little-endian",
+			Source: "placements:\n  - label: a\n    bits: 4\n  - label: b\n    bits: 16\n    byte-order: little-endian\n",
+			Error:  "placement b: little-endian fields whose bits are msb-first must be whole bytes on a byte boundary",
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadDefinition(strings.NewReader(data.Source))
+			assert.EqualError(t, err, data.Error)
+		})
+	}
+}
+
+func TestValidOrders(t *testing.T) {
+	t.Parallel()
+	src := `byte-order: little-endian
+bit-order: lsb-first
+placements:
+  - label: a
+    bits: 4
+  - label: b
+    bits: 12
+  - label: c
+    bits: 16
+    byte-order: big-endian
+  - label: d
+    bits: 8
+    bit-order: msb-first
+`
+	def, err := LoadDefinition(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Equal(t, "byte order: little-endian, bit order: lsb-first (c big-endian, d msb-first)", getOrderCaption(def))
+}
+
+func TestDrawOrderHeader(t *testing.T) {
+	t.Parallel()
+	placements := []Placement{{Label: "a", Bits: uintp(32)}}
+
+	var plain bytes.Buffer
+	err := Draw(&Definition{Placements: placements}, &plain)
+	assert.NoError(t, err)
+	assert.NotContains(t, plain.String(), `class="header"`)
+
+	def := &Definition{ByteOrder: byteOrderp(ByteOrderLittleEndian), Placements: placements}
+	var buf bytes.Buffer
+	err = Draw(def, &buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `class="header" >byte order: little-endian, bit order: msb-first</text>`)
+	assert.Contains(t, buf.String(), `transform="translate(0,20)"`)
+	assert.Equal(t, calculateDimensions(&Definition{Placements: placements}).Canvas.Height+defaultHeaderHeight, calculateDimensions(def).Canvas.Height)
+}
@@ -0,0 +1,388 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+var p4Keywords = map[string]bool{
+	"action": true, "actions": true, "apply": true, "bit": true, "bool": true,
+	"const": true, "control": true, "default": true, "else": true,
+	"entries": true, "enum": true, "error": true, "exit": true, "extern": true,
+	"false": true, "header": true, "header_union": true, "if": true,
+	"in": true, "inout": true, "int": true, "key": true, "match_kind": true,
+	"out": true, "package": true, "parser": true, "return": true,
+	"select": true, "state": true, "string": true, "struct": true,
+	"switch": true, "table": true, "transition": true, "true": true,
+	"tuple": true, "type": true, "typedef": true, "varbit": true,
+	"verify": true, "void": true,
+}
+
+// ExportP4 writes a P4_16 header type for what def describes, with a bit<N>
+// field per placement and varbit<max-bits> for its variable-length one, as a
+// header can have only one. Fields with values take a serializable enum type.
+// Headers are big-endian and msb-first, so fields laid out otherwise cannot
+// be exported.
+func ExportP4(def *Definition, w io.Writer) error {
+	title := def.Name
+	if title == "" {
+		title = "Packet"
+	}
+
+	types := identifierSet{}
+	headerType := types.add(strings.TrimSuffix(snakeCaseIdentifier(title), "_t") + " t")
+	ids := identifierSet{}
+	orders := def.getFieldOrders()
+
+	var enums, fields strings.Builder
+	varbit := ""
+	bit := uint(0) // within the current byte
+	for i, p := range def.Placements {
+		label := p.Label
+		if p.Name != "" {
+			label = p.Name
+		}
+		// names that look like P4 fields already, as from P4 programs, are
+		// kept
+		id := label
+		if !isCIdentifier(id) || !unicode.IsLower(rune(id[0])) || ids[id] || p4Keywords[id] {
+			id = ids.add(label)
+			if p4Keywords[id] {
+				id = ids.add(label + " field")
+			}
+		}
+		ids[id] = true
+
+		switch {
+		case p.VariableLength != nil:
+			if varbit != "" {
+				return errors.Errorf("placement %s: a P4 header can have only one variable-length field, and %s is one", p.GetKey(), varbit)
+			}
+			varbit = p.GetKey()
+			bit = 0
+			fmt.Fprintf(&fields, "    varbit<%d> %s;\n", p.VariableLength.MaxBits, id)
+		case p.Bits != nil:
+			// lsb-first bytes and fields of whole bytes big-endian lay out as
+			// msb-first ones do
+			n := *p.Bits
+			if (orders[i].littleEndian && n > 8) || (orders[i].bits == BitOrderLSBFirst && (bit != 0 || n%8 != 0)) {
+				return errors.Errorf("placement %s: P4 headers are big-endian and msb-first", p.GetKey())
+			}
+			bit = (bit + n) % 8
+			typ := fmt.Sprintf("bit<%d>", n)
+			if len(p.Values) > 0 {
+				enum := types.add(id + " t")
+				fmt.Fprintf(&enums, "enum %s %s {\n", typ, enum)
+				members := identifierSet{}
+				for i, v := range p.Values {
+					sep := ","
+					if i == len(p.Values)-1 {
+						sep = ""
+					}
+					fmt.Fprintf(&enums, "    %s = %s%s\n", strings.ToUpper(members.add(v.Label)), p4Hex(v.Value), sep)
+				}
+				enums.WriteString("}\n\n")
+				typ = enum
+			}
+			fmt.Fprintf(&fields, "    %s %s;\n", typ, id)
+		default:
+			return errors.Errorf("placement %s has no size", p.GetKey())
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s, generated by packet-diagram.\n\n", title)
+	b.WriteString(enums.String())
+	fmt.Fprintf(&b, "header %s {\n", headerType)
+	b.WriteString(fields.String())
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func p4Hex(v uint64) string {
+	if v < 10 {
+		return strconv.FormatUint(v, 10)
+	}
+	return fmt.Sprintf("0x%x", v)
+}
+
+// p4Type is what a P4 type lays out as: a width, or a variable width up to
+// it, and the named values of serializable enums.
+type p4Type struct {
+	bits   uint
+	varbit bool
+	values []ValueSpec
+}
+
+// p4WidthPrefix is the width and signedness P4 integer literals may start
+// with, as in 8w6 or 16w0x800.
+var p4WidthPrefix = regexp.MustCompile(`^[0-9]+[ws]`)
+
+// ImportP4 reads the header types declared in a P4_16 program and makes a
+// Definition of each. Field types may be bit<N>, int<N>, varbit<N>, bool,
+// typedefs of them and serializable enums, whose members become the values
+// of the field. Widths may be integers, constants or macros defined as
+// integers.
+func ImportP4(r io.Reader) ([]*Definition, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// P4 shares its comments, preprocessor and tokens with C
+	toks, defines, err := tokenizeC(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &p4Parser{
+		cParser: cParser{toks: toks, defines: defines},
+		types:   map[string]*p4Type{},
+	}
+	defs, err := p.parseProgram()
+	if err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, errors.New("no header types found")
+	}
+	return defs, nil
+}
+
+type p4Parser struct {
+	cParser
+	types map[string]*p4Type
+}
+
+func (p *p4Parser) parseProgram() ([]*Definition, error) {
+	defs := make([]*Definition, 0)
+	for p.pos < len(p.toks) {
+		switch p.peek().text {
+		case "header":
+			p.next()
+			def, err := p.parseHeader()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, def)
+		case "typedef", "type":
+			p.next()
+			start := p.pos
+			typ, err := p.parseType()
+			if err != nil {
+				// a typedef of a type that cannot be in headers
+				p.pos = start
+				p.skipStatement()
+				continue
+			}
+			name := p.next()
+			if !isCIdentifier(name.text) {
+				return nil, errors.Errorf("line %d: expected a type name but found `%s`", name.line, name.text)
+			}
+			p.types[name.text] = typ
+			err = p.expect(";")
+			if err != nil {
+				return nil, err
+			}
+		case "enum":
+			p.next()
+			err := p.parseEnum()
+			if err != nil {
+				return nil, err
+			}
+		case "const":
+			p.next()
+			p.parseConstantDeclaration()
+		case "{":
+			p.skipBalanced("{", "}")
+		default:
+			p.next()
+		}
+	}
+	return defs, nil
+}
+
+func (p *p4Parser) parseHeader() (*Definition, error) {
+	name := p.next()
+	if !isCIdentifier(name.text) {
+		return nil, errors.Errorf("line %d: expected a header name but found `%s`", name.line, name.text)
+	}
+	err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+
+	def := &Definition{Name: name.text, Placements: make([]Placement, 0)}
+	for p.peek().text != "}" {
+		if p.pos >= len(p.toks) {
+			return nil, p.errorf("expected `}` but the input ended")
+		}
+		p.skipAnnotations()
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, errors.Wrapf(err, "header %s", name.text)
+		}
+		field := p.next()
+		if !isCIdentifier(field.text) {
+			return nil, errors.Errorf("line %d: expected a field name but found `%s`", field.line, field.text)
+		}
+		err = p.expect(";")
+		if err != nil {
+			return nil, err
+		}
+
+		pl := Placement{Label: field.text, Values: typ.values}
+		if typ.varbit {
+			pl.VariableLength = &VariableLengthPlacementSpec{MaxBits: typ.bits}
+		} else {
+			pl.Bits = newUint(typ.bits)
+		}
+		def.Placements = append(def.Placements, pl)
+	}
+	p.next()
+
+	err = def.validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "header %s", name.text)
+	}
+	return def, nil
+}
+
+func (p *p4Parser) parseType() (*p4Type, error) {
+	t := p.next()
+	switch t.text {
+	case "bool":
+		return &p4Type{bits: 1}, nil
+	case "bit", "int", "varbit":
+		bits := uint64(1)
+		if p.peek().text == "<" {
+			p.next()
+			n, err := p.parseWidth()
+			if err != nil {
+				return nil, err
+			}
+			bits = n
+			err = p.expect(">")
+			if err != nil {
+				return nil, err
+			}
+		} else if t.text != "bit" {
+			return nil, errors.Errorf("line %d: %s needs a width", t.line, t.text)
+		}
+		return &p4Type{bits: uint(bits), varbit: t.text == "varbit"}, nil
+	default:
+		if typ, ok := p.types[t.text]; ok {
+			return typ, nil
+		}
+		return nil, errors.Errorf("line %d: unknown type %s", t.line, t.text)
+	}
+}
+
+func (p *p4Parser) parseWidth() (uint64, error) {
+	t := p.next()
+	text := t.text
+	if v, ok := p.defines[text]; ok {
+		text = v
+	}
+	return p4Integer(text, t.line)
+}
+
+func p4Integer(text string, line int) (uint64, error) {
+	text = p4WidthPrefix.ReplaceAllString(strings.ReplaceAll(text, "_", ""), "")
+	n, err := strconv.ParseUint(text, 0, 64)
+	if err != nil {
+		return 0, errors.Errorf("line %d: expected an integer but found `%s`", line, text)
+	}
+	return n, nil
+}
+
+// parseEnum reads an enum declaration, keeping serializable ones, which have
+// an underlying type and values, as types.
+func (p *p4Parser) parseEnum() error {
+	var typ *p4Type
+	if p.peek().text == "bit" || p.peek().text == "int" {
+		var err error
+		typ, err = p.parseType()
+		if err != nil {
+			return err
+		}
+	}
+	name := p.next()
+	if typ == nil {
+		// enums without an underlying type cannot be in headers
+		p.skipBalanced("{", "}")
+		return nil
+	}
+
+	err := p.expect("{")
+	if err != nil {
+		return err
+	}
+	enum := &p4Type{bits: typ.bits, values: make([]ValueSpec, 0)}
+	for p.peek().text != "}" {
+		member := p.next()
+		if !isCIdentifier(member.text) {
+			return errors.Errorf("line %d: expected an enum member but found `%s`", member.line, member.text)
+		}
+		err = p.expect("=")
+		if err != nil {
+			return err
+		}
+		v := p.next()
+		text := v.text
+		if c, ok := p.defines[text]; ok {
+			text = c
+		}
+		n, err := p4Integer(text, v.line)
+		if err != nil {
+			return err
+		}
+		enum.values = append(enum.values, ValueSpec{Value: n, Label: member.text})
+		if p.peek().text == "," {
+			p.next()
+		}
+	}
+	p.next()
+	p.types[name.text] = enum
+	return nil
+}
+
+// parseConstantDeclaration keeps integer constants so that widths may use
+// them, skipping the others.
+func (p *p4Parser) parseConstantDeclaration() {
+	start := p.pos
+	for p.pos < len(p.toks) && p.peek().text != ";" && p.peek().text != "=" {
+		p.next()
+	}
+	if p.peek().text == "=" && p.pos-1 > start {
+		name := p.toks[p.pos-1].text
+		p.next()
+		if p.pos+1 < len(p.toks) && p.toks[p.pos+1].text == ";" {
+			if _, err := p4Integer(p.peek().text, 0); err == nil {
+				p.defines[name] = p.peek().text
+			}
+		}
+	}
+	p.skipStatement()
+}
+
+func (p *p4Parser) skipAnnotations() {
+	for p.peek().text == "@" {
+		p.next()
+		p.next()
+		if p.peek().text == "(" || p.peek().text == "[" {
+			open, close := p.peek().text, ")"
+			if open == "[" {
+				close = "]"
+			}
+			p.skipBalanced(open, close)
+		}
+	}
+}
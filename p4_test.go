@@ -0,0 +1,159 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestExportP4(t *testing.T) {
+	testGolden(t, goldenDefinitions, "testdata/p4", ".p4", ExportP4)
+}
+
+func TestExportP4Errors(t *testing.T) {
+	t.Parallel()
+	def := &Definition{Placements: []Placement{
+		{Label: "a", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+		{Label: "b", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+	}}
+	var buf bytes.Buffer
+	err := ExportP4(def, &buf)
+	assert.EqualError(t, err, "placement b: a P4 header can have only one variable-length field, and a is one")
+
+	def = &Definition{ByteOrder: byteOrderp(ByteOrderLittleEndian), Placements: []Placement{
+		{Label: "a", Bits: uintp(8)},
+		{Label: "b", Bits: uintp(16)},
+	}}
+	buf.Reset()
+	err = ExportP4(def, &buf)
+	assert.EqualError(t, err, "placement b: P4 headers are big-endian and msb-first")
+}
+
+func TestImportP4(t *testing.T) {
+	t.Parallel()
+	src := `#include <core.p4>
+#define MAX_OPTS 320
+
+const bit<32> ADDR_BITS = 32;
+typedef bit<48> macAddr_t;
+typedef bit<ADDR_BITS> ip4Addr_t;
+
+enum bit<16> ether_type_t {
+    IPV4 = 16w0x0800,
+    ARP  = 0x0806
+}
+
+/* link layer */
+@controller_header("packet_in")
+header ethernet_t {
+    macAddr_t dstAddr;
+    macAddr_t srcAddr;
+    ether_type_t etherType;
+}
+
+header ipv4_t {
+    bit<4>  version;
+    bool    flag;
+    int<3>  ecn;
+    @name("source") ip4Addr_t srcAddr;
+    varbit<MAX_OPTS> options;
+}
+
+struct headers {
+    ethernet_t ethernet;
+    ipv4_t     ipv4;
+}
+
+typedef ipv4_t[2] ipv4_stack_t;
+
+parser MyParser(packet_in packet, out headers hdr) {
+    state start {
+        packet.extract(hdr.ethernet);
+        transition accept;
+    }
+}
+`
+	defs, err := ImportP4(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Equal(t, []*Definition{
+		{
+			Name: "ethernet_t",
+			Placements: []Placement{
+				{Label: "dstAddr", Bits: uintp(48)},
+				{Label: "srcAddr", Bits: uintp(48)},
+				{Label: "etherType", Bits: uintp(16), Values: []ValueSpec{{Value: 0x800, Label: "IPV4"}, {Value: 0x806, Label: "ARP"}}},
+			},
+		},
+		{
+			Name: "ipv4_t",
+			Placements: []Placement{
+				{Label: "version", Bits: uintp(4)},
+				{Label: "flag", Bits: uintp(1)},
+				{Label: "ecn", Bits: uintp(3)},
+				{Label: "srcAddr", Bits: uintp(32)},
+				{Label: "options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320}},
+			},
+		},
+	}, defs)
+}
+
+func TestP4RoundTrip(t *testing.T) {
+	t.Parallel()
+	def := &Definition{
+		Name: "vlan_t",
+		Placements: []Placement{
+			{Label: "pcp", Bits: uintp(3)},
+			{Label: "dei", Bits: uintp(1)},
+			{Label: "vid", Bits: uintp(12), Values: []ValueSpec{{Value: 0, Label: "NONE"}, {Value: 4095, Label: "RESERVED"}}},
+			{Label: "tail", VariableLength: &VariableLengthPlacementSpec{MaxBits: 64}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := ExportP4(def, &buf)
+	assert.NoError(t, err)
+	defs, err := ImportP4(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Definition{def}, defs)
+}
+
+func TestImportP4Errors(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "none",
+			Source: "struct s { bit<8> a; }\n",
+			Error:  "no header types found",
+		},
+		{
+			Name:   "unknown type",
+			Source: "header h {\n    foo_t a;\n}\n",
+			Error:  "header h: line 2: unknown type foo_t",
+		},
+		{
+			Name:   "bad width",
+			Source: "header h {\n    bit<W> a;\n}\n",
+			Error:  "header h: line 2: expected an integer but found `W`",
+		},
+		{
+			Name:   "unterminated",
+			Source: "header h {\n    bit<8> a;\n",
+			Error:  "expected `}` but the input ended",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ImportP4(strings.NewReader(tt.Source))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.Error)
+		})
+	}
+}
@@ -41,3 +41,24 @@ func (p Polygon) findBoundingBox() (left uint, top uint, right uint, bottom uint
 	}
 	return
 }
+
+// topLeftCorner returns the leftmost point of the top edge of the polygon,
+// which for placements over several lines is where they start.
+func (p Polygon) topLeftCorner() (x int, y int) {
+	return p.topCorner(func(a, b int) bool { return a < b })
+}
+
+// topRightCorner returns the rightmost point of the top edge of the polygon.
+func (p Polygon) topRightCorner() (x int, y int) {
+	return p.topCorner(func(a, b int) bool { return a > b })
+}
+
+func (p Polygon) topCorner(better func(a, b int) bool) (x int, y int) {
+	x, y = p.xs[0], p.ys[0]
+	for i := range p.xs {
+		if p.ys[i] < y || (p.ys[i] == y && better(p.xs[i], x)) {
+			x, y = p.xs[i], p.ys[i]
+		}
+	}
+	return
+}
@@ -0,0 +1,444 @@
+package packetdiagram
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const bezierSegments = 16
+
+func DrawPNG(def *Definition, out io.Writer) error {
+	canvas := newRasterSurface()
+	err := draw(def, canvas)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(out, canvas.img)
+}
+
+// rasterSurface paints the primitives Draw emits for SVG onto an image.
+// Colors and text alignment are resolved from the stylesheet given to
+// Style, so the raster output follows the same theme as the SVG output.
+type rasterSurface struct {
+	img   *image.RGBA
+	rules map[string]map[string]string
+	face  font.Face
+
+	// groups holds the groups currently open; dx and dy are the sum of
+	// their offsets
+	groups []rasterGroup
+	dx, dy int
+
+	// patterns holds the tiles of the patterns defined so far, by id; the
+	// one being defined is painted onto in place of img
+	patterns map[string]*image.RGBA
+	defining *rasterPattern
+}
+
+// rasterGroup is an open group: the offset it translates by, and the id
+// that scopes style rules to it.
+type rasterGroup struct {
+	translation image.Point
+	id          string
+}
+
+// rasterPattern is a pattern being defined, with what it is drawn in place
+// of.
+type rasterPattern struct {
+	id     string
+	img    *image.RGBA
+	dx, dy int
+}
+
+func newRasterSurface() *rasterSurface {
+	return &rasterSurface{
+		rules:    map[string]map[string]string{},
+		face:     basicfont.Face7x13,
+		patterns: map[string]*image.RGBA{},
+	}
+}
+
+func (r *rasterSurface) Start(w int, h int, ns ...string) {
+	r.img = image.NewRGBA(image.Rect(0, 0, w, h))
+}
+
+func (r *rasterSurface) End() {}
+
+var translatePattern = regexp.MustCompile(`translate\(\s*(-?\d+)\s*[,\s]\s*(-?\d+)\s*\)`)
+
+// Gtransform supports the translations used to place diagrams side by side;
+// other transformations are ignored.
+func (r *rasterSurface) Gtransform(s string) {
+	t := image.Point{}
+	if m := translatePattern.FindStringSubmatch(s); m != nil {
+		t.X, _ = strconv.Atoi(m[1])
+		t.Y, _ = strconv.Atoi(m[2])
+	}
+	r.groups = append(r.groups, rasterGroup{translation: t})
+	r.dx += t.X
+	r.dy += t.Y
+}
+
+// Gid opens a group that rules scoped to #s apply in.
+func (r *rasterSurface) Gid(s string) {
+	r.groups = append(r.groups, rasterGroup{id: s})
+}
+
+func (r *rasterSurface) Gend() {
+	if len(r.groups) == 0 {
+		return
+	}
+	t := r.groups[len(r.groups)-1].translation
+	r.groups = r.groups[:len(r.groups)-1]
+	r.dx -= t.X
+	r.dy -= t.Y
+}
+
+func (r *rasterSurface) Def() {}
+
+func (r *rasterSurface) DefEnd() {}
+
+// Pattern paints what is drawn up to PatternEnd onto a tile of w by h pixels,
+// which fills what refers to it by url(#id). Only patterns in user space
+// starting at the origin are supported.
+func (r *rasterSurface) Pattern(id string, x int, y int, w int, h int, putype string, s ...string) {
+	r.defining = &rasterPattern{id: id, img: r.img, dx: r.dx, dy: r.dy}
+	r.img = image.NewRGBA(image.Rect(0, 0, w, h))
+	r.dx, r.dy = 0, 0
+}
+
+func (r *rasterSurface) PatternEnd() {
+	if r.defining == nil {
+		return
+	}
+	r.patterns[r.defining.id] = r.img
+	r.img, r.dx, r.dy = r.defining.img, r.defining.dx, r.defining.dy
+	r.defining = nil
+}
+
+var patternURLPattern = regexp.MustCompile(`^url\(\s*#([^)\s]+)\s*\)$`)
+
+// getPattern returns the tile of the pattern fill refers to, if any.
+func (r *rasterSurface) getPattern(fill string) (*image.RGBA, bool) {
+	m := patternURLPattern.FindStringSubmatch(strings.TrimSpace(fill))
+	if m == nil {
+		return nil, false
+	}
+	tile, ok := r.patterns[m[1]]
+	return tile, ok
+}
+
+func (r *rasterSurface) Style(scriptype string, data ...string) {
+	for _, d := range data {
+		for _, rule := range strings.Split(d, "}") {
+			i := strings.Index(rule, "{")
+			if i < 0 {
+				continue
+			}
+			selector := strings.TrimSpace(rule[:i])
+			if _, ok := r.rules[selector]; !ok {
+				r.rules[selector] = map[string]string{}
+			}
+			mergeProperties(r.rules[selector], parseDeclarations(rule[i+1:]))
+		}
+	}
+}
+
+func (r *rasterSurface) Rect(x int, y int, w int, h int, s ...string) {
+	r.polygon("rect", []int{x, x + w, x + w, x, x}, []int{y, y, y + h, y + h, y}, s)
+}
+
+func (r *rasterSurface) Polygon(x []int, y []int, s ...string) {
+	r.polygon("polygon", x, y, s)
+}
+
+// polygon paints the outline of element, which is styled by the rules for
+// its element name.
+func (r *rasterSurface) polygon(element string, x []int, y []int, s []string) {
+	x, y = r.translate(x, y)
+	props := r.properties(element, s)
+	fill := propertyOrDefault(props, "fill", "black")
+	if tile, ok := r.getPattern(fill); ok {
+		// tiled from the origin of the element's user space
+		b := tile.Bounds()
+		dx, dy := r.dx, r.dy
+		r.paintPolygon(x, y, func(px, py int) color.Color {
+			return tile.At(mod(px-dx, b.Dx()), mod(py-dy, b.Dy()))
+		})
+	} else if c, ok := parseColor(fill); ok {
+		r.fillPolygon(x, y, c)
+	}
+	if stroke, ok := parseColor(propertyOrDefault(props, "stroke", "none")); ok {
+		for i := range x {
+			j := (i + 1) % len(x)
+			r.line(x[i], y[i], x[j], y[j], stroke)
+		}
+	}
+}
+
+func (r *rasterSurface) Line(x1 int, y1 int, x2 int, y2 int, s ...string) {
+	x1, y1, x2, y2 = x1+r.dx, y1+r.dy, x2+r.dx, y2+r.dy
+	props := r.properties("line", s)
+	if stroke, ok := parseColor(propertyOrDefault(props, "stroke", "none")); ok {
+		r.line(x1, y1, x2, y2, stroke)
+	}
+}
+
+func (r *rasterSurface) Bezier(sx int, sy int, cx int, cy int, px int, py int, ex int, ey int, s ...string) {
+	props := r.properties("path", s)
+	stroke, ok := parseColor(propertyOrDefault(props, "stroke", "none"))
+	if !ok {
+		return
+	}
+
+	sx, sy, cx, cy, px, py, ex, ey = sx+r.dx, sy+r.dy, cx+r.dx, cy+r.dy, px+r.dx, py+r.dy, ex+r.dx, ey+r.dy
+	lastX, lastY := sx, sy
+	for i := 1; i <= bezierSegments; i++ {
+		t := float64(i) / bezierSegments
+		u := 1 - t
+		x := u*u*u*float64(sx) + 3*u*u*t*float64(cx) + 3*u*t*t*float64(px) + t*t*t*float64(ex)
+		y := u*u*u*float64(sy) + 3*u*u*t*float64(cy) + 3*u*t*t*float64(py) + t*t*t*float64(ey)
+		r.line(lastX, lastY, int(x+0.5), int(y+0.5), stroke)
+		lastX, lastY = int(x+0.5), int(y+0.5)
+	}
+}
+
+func (r *rasterSurface) Text(x int, y int, t string, s ...string) {
+	props := r.properties("text", s)
+	fill, ok := parseColor(propertyOrDefault(props, "fill", "black"))
+	if !ok {
+		return
+	}
+
+	d := &font.Drawer{
+		Dst:  r.img,
+		Src:  image.NewUniform(fill),
+		Face: r.face,
+	}
+	t = strings.ReplaceAll(t, "︙", ":")
+	width := d.MeasureString(t).Round()
+	switch props["text-anchor"] {
+	case "middle":
+		x -= width / 2
+	case "end":
+		x -= width
+	}
+	d.Dot = fixed.P(x+r.dx, y+r.dy)
+	d.DrawString(t)
+}
+
+func (r *rasterSurface) translate(x []int, y []int) ([]int, []int) {
+	tx := make([]int, len(x))
+	ty := make([]int, len(y))
+	for i := range x {
+		tx[i] = x[i] + r.dx
+		ty[i] = y[i] + r.dy
+	}
+	return tx, ty
+}
+
+// properties resolves the presentation of an element in the same order of
+// precedence as a browser would for our stylesheet: presentation attributes
+// first, then element and class rules, then those scoped to the ids of the
+// groups it is in, then the inline style attribute.
+func (r *rasterSurface) properties(element string, s []string) map[string]string {
+	attrs := parseAttributes(s)
+
+	props := map[string]string{}
+	for _, name := range []string{"fill", "stroke"} {
+		if v, ok := attrs[name]; ok {
+			props[name] = v
+		}
+	}
+	scopes := []string{""}
+	for _, g := range r.groups {
+		if g.id != "" {
+			scopes = append(scopes, "#"+g.id+" ")
+		}
+	}
+	for _, scope := range scopes {
+		mergeProperties(props, r.rules[scope+element])
+		for _, class := range strings.Fields(attrs["class"]) {
+			mergeProperties(props, r.rules[scope+"."+class])
+			mergeProperties(props, r.rules[scope+element+"."+class])
+		}
+	}
+	mergeProperties(props, parseDeclarations(attrs["style"]))
+
+	return props
+}
+
+func (r *rasterSurface) fillPolygon(xs []int, ys []int, c color.Color) {
+	r.paintPolygon(xs, ys, func(x, y int) color.Color { return c })
+}
+
+// paintPolygon sets the pixels inside the polygon to the colors paint
+// gives for them.
+func (r *rasterSurface) paintPolygon(xs []int, ys []int, paint func(x, y int) color.Color) {
+	if len(xs) == 0 {
+		return
+	}
+
+	top, bottom := ys[0], ys[0]
+	for _, y := range ys {
+		if y < top {
+			top = y
+		}
+		if y > bottom {
+			bottom = y
+		}
+	}
+
+	for y := top; y < bottom; y++ {
+		scan := float64(y) + 0.5
+		crossings := make([]float64, 0)
+		for i := range xs {
+			j := (i + 1) % len(xs)
+			y1, y2 := float64(ys[i]), float64(ys[j])
+			if (y1 <= scan && scan < y2) || (y2 <= scan && scan < y1) {
+				x1, x2 := float64(xs[i]), float64(xs[j])
+				crossings = append(crossings, x1+(scan-y1)*(x2-x1)/(y2-y1))
+			}
+		}
+		sort.Float64s(crossings)
+
+		for i := 0; i+1 < len(crossings); i += 2 {
+			for x := int(crossings[i] + 0.5); x < int(crossings[i+1]+0.5); x++ {
+				r.img.Set(x, y, paint(x, y))
+			}
+		}
+	}
+}
+
+func (r *rasterSurface) line(x1, y1, x2, y2 int, c color.Color) {
+	dx := abs(x2 - x1)
+	dy := -abs(y2 - y1)
+	sx, sy := 1, 1
+	if x2 < x1 {
+		sx = -1
+	}
+	if y2 < y1 {
+		sy = -1
+	}
+
+	e := dx + dy
+	for {
+		r.img.Set(x1, y1, c)
+		if x1 == x2 && y1 == y2 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x1 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y1 += sy
+		}
+	}
+}
+
+func mod(x, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return ((x % n) + n) % n
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+var attributePattern = regexp.MustCompile(`([\w-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+func parseAttributes(s []string) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range s {
+		for _, m := range attributePattern.FindAllStringSubmatch(a, -1) {
+			attrs[m[1]] = m[2] + m[3]
+		}
+	}
+	return attrs
+}
+
+func parseDeclarations(s string) map[string]string {
+	decls := map[string]string{}
+	for _, decl := range strings.Split(s, ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		decls[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return decls
+}
+
+func mergeProperties(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+func propertyOrDefault(props map[string]string, name, def string) string {
+	if v, ok := props[name]; ok {
+		return v
+	}
+	return def
+}
+
+// parseColor understands the color notations used in themes: SVG color
+// keywords, #rgb, #rrggbb and rgb(r, g, b). It reports false for "none"
+// and for anything it cannot parse, in which case nothing is painted.
+func parseColor(s string) (color.Color, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == "" || s == "none" || s == "transparent":
+		return nil, false
+	case strings.HasPrefix(s, "#"):
+		hex := s[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return nil, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return nil, false
+		}
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		parts := strings.Split(s[4:len(s)-1], ",")
+		if len(parts) != 3 {
+			return nil, false
+		}
+		rgb := make([]uint8, 3)
+		for i, part := range parts {
+			v, err := strconv.ParseUint(strings.TrimSpace(part), 10, 8)
+			if err != nil {
+				return nil, false
+			}
+			rgb[i] = uint8(v)
+		}
+		return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff}, true
+	default:
+		c, ok := colornames.Map[s]
+		return c, ok
+	}
+}
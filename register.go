@@ -0,0 +1,159 @@
+package packetdiagram
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+type Mode string
+
+const (
+	ModePacket   Mode = "packet"
+	ModeRegister Mode = "register"
+)
+
+func (Mode) schemaEnum() []string {
+	return []string{
+		string(ModePacket),
+		string(ModeRegister),
+	}
+}
+
+// AccessType is how software may access a register field.
+type AccessType string
+
+const (
+	AccessTypeRW  AccessType = "RW"  // read/write
+	AccessTypeRO  AccessType = "RO"  // read only
+	AccessTypeWO  AccessType = "WO"  // write only
+	AccessTypeW1C AccessType = "W1C" // write 1 to clear
+	AccessTypeW1S AccessType = "W1S" // write 1 to set
+	AccessTypeW1T AccessType = "W1T" // write 1 to toggle
+	AccessTypeRC  AccessType = "RC"  // cleared on read
+	AccessTypeRS  AccessType = "RS"  // set on read
+)
+
+var accessTypeFills = map[AccessType]string{
+	AccessTypeRW:  "#dbeafe",
+	AccessTypeRO:  "#e5e7eb",
+	AccessTypeWO:  "#fef3c7",
+	AccessTypeW1C: "#fde2e2",
+	AccessTypeW1S: "#fde2e2",
+	AccessTypeW1T: "#fde2e2",
+	AccessTypeRC:  "#ede9fe",
+	AccessTypeRS:  "#ede9fe",
+}
+
+func (AccessType) schemaEnum() []string {
+	return []string{
+		string(AccessTypeRW),
+		string(AccessTypeRO),
+		string(AccessTypeWO),
+		string(AccessTypeW1C),
+		string(AccessTypeW1S),
+		string(AccessTypeW1T),
+		string(AccessTypeRC),
+		string(AccessTypeRS),
+	}
+}
+
+func (d *Definition) IsRegisterMode() bool {
+	return d.Mode != nil && *d.Mode == ModeRegister
+}
+
+// GetFill returns the fill of the placement, which defaults to the color of
+// its access type.
+func (p Placement) GetFill() *string {
+	if p.Fill != nil {
+		return p.Fill
+	}
+	if p.Access != nil {
+		if fill, ok := accessTypeFills[*p.Access]; ok {
+			return &fill
+		}
+	}
+	return nil
+}
+
+// GetResetLabel formats the reset value of the placement the way datasheets
+// do: a single digit for 1-bit fields, zero-padded hex otherwise.
+func (p Placement) GetResetLabel() string {
+	if p.Reset == nil {
+		return ""
+	}
+	bits := p.GetBits()
+	if bits == 1 {
+		return fmt.Sprintf("%d", *p.Reset)
+	}
+	return fmt.Sprintf("0x%0*X", (bits+3)/4, *p.Reset)
+}
+
+// GetBitRange returns the bits a placement starting at offset covers within
+// its word, numbered MSB-first, as in [15:8].
+func (d *Definition) GetBitRange(offset, bits uint) string {
+	w := d.GetBitsPerLine()
+	msb := w - 1 - offset%w
+	if bits <= 1 {
+		return fmt.Sprintf("[%d]", msb)
+	}
+	return fmt.Sprintf("[%d:%d]", msb, msb-bits+1)
+}
+
+func (p Placement) validateRegisterSpec() error {
+	if p.Access != nil {
+		if _, ok := accessTypeFills[*p.Access]; !ok {
+			return errors.Errorf("placement %s: unknown access type %s", p.GetKey(), *p.Access)
+		}
+	}
+	if p.Reset != nil && p.Bits != nil && *p.Bits < 64 && *p.Reset>>*p.Bits != 0 {
+		return errors.Errorf("placement %s: reset value %#x does not fit in %d bits", p.GetKey(), *p.Reset, *p.Bits)
+	}
+	return nil
+}
+
+// validateRegister checks that the definition describes 32- or 64-bit words
+// with every field inside a single word.
+func (d *Definition) validateRegister() error {
+	if w := d.GetBitsPerLine(); w != 32 && w != 64 {
+		return errors.Errorf("registers are 32 or 64 bits wide, not %d", w)
+	}
+
+	offsets := d.GetPlacementOffsets()
+	for i, p := range d.Placements {
+		if p.VariableLength != nil {
+			return errors.Errorf("placement %s: registers cannot have variable-length fields", p.GetKey())
+		}
+		if p.Bits == nil {
+			continue
+		}
+		w := d.GetBitsPerLine()
+		if offsets[i]%w+*p.Bits > w {
+			return errors.Errorf("placement %s: %s crosses the boundary of a %d-bit word", p.GetKey(), d.GetBitRange(offsets[i], *p.Bits), w)
+		}
+	}
+	return nil
+}
+
+// drawRegisterFieldText lays out a field the way datasheets do: its bit
+// range and access type above the label, its reset value below.
+func drawRegisterFieldText(def *Definition, dim Dimensions, p Placement, offset uint, polygon Polygon, canvas surface) {
+	left, top, right, bottom := polygon.findBoundingBox()
+	x := int(left+right) / 2
+	h := int(bottom - top)
+
+	// narrow fields only have room for the bit range; their access type
+	// still shows in their color
+	header := def.GetBitRange(offset, p.GetBits())
+	if p.Access != nil {
+		withAccess := header + " " + string(*p.Access)
+		if uint(len(withAccess))*def.GetAxisTitleTextSizeInPixels() < right-left {
+			header = withAccess
+		}
+	}
+	canvas.Text(x, int(top)+h/4+2, header, `class="placement-register"`)
+	canvas.Text(x, int(top)+h/2+h/12, p.Label, `class="placement"`)
+	if p.Reset != nil {
+		canvas.Text(x, int(bottom)-h/8, p.GetResetLabel(), `class="placement-register"`)
+	}
+}
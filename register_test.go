@@ -0,0 +1,127 @@
+package packetdiagram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestGetBitRange(t *testing.T) {
+	testData := []struct {
+		OctetsPerLine uint
+		Offset        uint
+		Bits          uint
+		Expected      string
+	}{
+		{OctetsPerLine: 4, Offset: 0, Bits: 16, Expected: "[31:16]"},
+		{OctetsPerLine: 4, Offset: 16, Bits: 8, Expected: "[15:8]"},
+		{OctetsPerLine: 4, Offset: 31, Bits: 1, Expected: "[0]"},
+		{OctetsPerLine: 4, Offset: 40, Bits: 8, Expected: "[23:16]"},
+		{OctetsPerLine: 8, Offset: 0, Bits: 4, Expected: "[63:60]"},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Expected, func(t *testing.T) {
+			t.Parallel()
+			def := &Definition{OctetsPerLine: uintp(tt.OctetsPerLine)}
+			assert.Equal(t, tt.Expected, def.GetBitRange(tt.Offset, tt.Bits))
+		})
+	}
+}
+
+func TestGetResetLabel(t *testing.T) {
+	reset := uint64(0x1f)
+	one := uint64(1)
+	testData := []struct {
+		Name      string
+		Placement Placement
+		Expected  string
+	}{
+		{Name: "no reset", Placement: Placement{Bits: uintp(8)}, Expected: ""},
+		{Name: "one bit", Placement: Placement{Bits: uintp(1), Reset: &one}, Expected: "1"},
+		{Name: "byte", Placement: Placement{Bits: uintp(8), Reset: &reset}, Expected: "0x1F"},
+		{Name: "padded", Placement: Placement{Bits: uintp(16), Reset: &reset}, Expected: "0x001F"},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.Expected, tt.Placement.GetResetLabel())
+		})
+	}
+}
+
+func TestLoadRegisterDefinition(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name: "valid",
+			Source: `
+mode: register
+placements:
+  - {label: Reserved, bits: 24, access: RO}
+  - {label: IRQ, bits: 1, access: W1C, reset: 0}
+  - {label: Prescaler, bits: 7, access: RW, reset: 0x7f}
+`,
+		},
+		{
+			Name: "unknown access type",
+			Source: `
+mode: register
+placements:
+  - {label: A, bits: 32, access: RWX}
+`,
+			Error: "unknown access type",
+		},
+		{
+			Name: "reset too large",
+			Source: `
+mode: register
+placements:
+  - {label: A, bits: 4, reset: 0x10}
+  - {label: B, bits: 28}
+`,
+			Error: "does not fit",
+		},
+		{
+			Name: "field across words",
+			Source: `
+mode: register
+placements:
+  - {label: A, bits: 24}
+  - {label: B, bits: 16}
+`,
+			Error: "crosses the boundary",
+		},
+		{
+			Name: "odd word size",
+			Source: `
+mode: register
+octets-per-line: 2
+placements:
+  - {label: A, bits: 16}
+`,
+			Error: "32 or 64 bits",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := LoadDefinition(strings.NewReader(tt.Source))
+			if tt.Error == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.Error)
+		})
+	}
+}
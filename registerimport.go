@@ -0,0 +1,110 @@
+package packetdiagram
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const reservedFieldLabel = "Reserved"
+
+// registerField is a field of a register as hardware description formats
+// give it: by its least significant bit and width.
+type registerField struct {
+	name   string
+	lsb    uint
+	width  uint
+	access *AccessType
+	reset  *uint64
+}
+
+// newRegisterDefinition lays out the fields of a register MSB-first, filling
+// the bits no field covers with reserved placements.
+func newRegisterDefinition(name string, width uint, fields []registerField) (*Definition, error) {
+	if width != 32 && width != 64 {
+		return nil, errors.Errorf("register %s is %d bits wide; only 32- and 64-bit registers are supported", name, width)
+	}
+
+	fields = append([]registerField(nil), fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].lsb > fields[j].lsb
+	})
+
+	mode := ModeRegister
+	def := &Definition{
+		Name:          name,
+		Mode:          &mode,
+		OctetsPerLine: newUint(width / 8),
+		XAxis:         XAxisSpec{Bits: &XAxisBitsSpec{}},
+		Placements:    make([]Placement, 0, len(fields)),
+	}
+
+	next := width // the bit above the lowest one laid out so far
+	for _, f := range fields {
+		if f.width == 0 || f.lsb+f.width > width {
+			return nil, errors.Errorf("register %s: field %s does not fit in %d bits", name, f.name, width)
+		}
+		msb := f.lsb + f.width
+		if msb > next {
+			return nil, errors.Errorf("register %s: field %s overlaps another field", name, f.name)
+		}
+		if msb < next {
+			def.Placements = append(def.Placements, Placement{Label: reservedFieldLabel, Bits: newUint(next - msb)})
+		}
+		def.Placements = append(def.Placements, Placement{
+			Label:  f.name,
+			Bits:   newUint(f.width),
+			Access: f.access,
+			Reset:  f.reset,
+		})
+		next = f.lsb
+	}
+	if next > 0 {
+		def.Placements = append(def.Placements, Placement{Label: reservedFieldLabel, Bits: newUint(next)})
+	}
+
+	err := def.validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "register %s", name)
+	}
+	return def, nil
+}
+
+// parseHDLNumber reads the integer notations of hardware description
+// formats: decimal, 0x hex and Verilog style literals such as 32'h1F or 'b1,
+// with _ separating digits.
+func parseHDLNumber(s string) (uint64, error) {
+	v := strings.ReplaceAll(strings.TrimSpace(s), "_", "")
+	if i := strings.Index(v, "'"); i >= 0 && i+1 < len(v) {
+		base := 10
+		switch strings.ToLower(v[i+1 : i+2]) {
+		case "h":
+			base = 16
+		case "b":
+			base = 2
+		case "o":
+			base = 8
+		case "d":
+			base = 10
+		default:
+			return 0, errors.Errorf("invalid number: %s", s)
+		}
+		n, err := strconv.ParseUint(v[i+2:], base, 64)
+		if err != nil {
+			return 0, errors.Errorf("invalid number: %s", s)
+		}
+		return n, nil
+	}
+
+	n, err := strconv.ParseUint(v, 0, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid number: %s", s)
+	}
+	return n, nil
+}
+
+func newUint(u uint) *uint {
+	return &u
+}
@@ -0,0 +1,515 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var rustKeywords = map[string]bool{
+	"as": true, "async": true, "await": true, "break": true, "const": true,
+	"continue": true, "crate": true, "dyn": true, "else": true, "enum": true,
+	"extern": true, "false": true, "fn": true, "for": true, "if": true,
+	"impl": true, "in": true, "let": true, "loop": true, "match": true,
+	"mod": true, "move": true, "mut": true, "pub": true, "ref": true,
+	"return": true, "self": true, "static": true, "struct": true,
+	"super": true, "trait": true, "true": true, "type": true, "unsafe": true,
+	"use": true, "where": true, "while": true, "abstract": true,
+	"become": true, "box": true, "do": true, "final": true, "macro": true,
+	"override": true, "priv": true, "try": true, "typeof": true,
+	"unsized": true, "virtual": true, "yield": true,
+}
+
+// rustField is a placement as a field of the generated struct. Fixed-size
+// fields are addressed within their group, the run of them that starts at
+// the beginning or after a variable-length field.
+type rustField struct {
+	id    string
+	label string
+	typ   string
+	bits  uint
+	reset uint64
+
+	offset uint // in bits, within the group of fixed-size fields
+	group  int
+	array  uint // the length of byte array fields
+	le     bool // whether the bytes holding the field are little-endian
+
+	vary   bool
+	last   bool
+	max    uint   // in bytes, for variable-length fields
+	min    uint   // in bytes, for variable-length fields
+	length string // the field giving the length of a variable-length field
+	after  uint   // bytes of fixed size that follow a variable-length field
+	values []ValueSpec
+}
+
+// ExportRust writes a Rust module with a struct for what def describes and
+// parse and write functions that unpack and pack its fields with explicit
+// shifts and masks, in the byte and bit order of the definition. Fields of
+// up to 64 bits become the smallest unsigned integer holding them and longer
+// byte-aligned ones byte arrays.
+// Variable-length fields become Vec<u8>, checked against their minimum and
+// maximum length; one with a length field takes as many bytes as it says, a
+// trailing one the rest of the input and any other one what the fields
+// after it leave. Reset values are the defaults and values become
+// associated constants.
+func ExportRust(def *Definition, w io.Writer) error {
+	title := def.Name
+	if title == "" {
+		title = "Packet"
+	}
+	typeName := pascalCaseIdentifier(title)
+
+	fields, groups, err := rustFields(def)
+	if err != nil {
+		return err
+	}
+	minLen := uint(0)
+	for _, g := range groups {
+		minLen += g
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s, generated by packet-diagram.\n\n", title)
+
+	b.WriteString("#[derive(Debug, Clone, PartialEq, Eq)]\n")
+	fmt.Fprintf(&b, "pub struct %s {\n", typeName)
+	for _, f := range fields {
+		fmt.Fprintf(&b, "    pub %s: %s,\n", f.id, f.typ)
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString(rustErrorType)
+
+	fmt.Fprintf(&b, "impl Default for %s {\n", typeName)
+	b.WriteString("    fn default() -> Self {\n")
+	fmt.Fprintf(&b, "        %s {\n", typeName)
+	for _, f := range fields {
+		var v string
+		switch {
+		case f.vary:
+			v = "Vec::new()"
+		case f.array > 0:
+			v = fmt.Sprintf("[0; %d]", f.array)
+		default:
+			v = rustHex(f.reset)
+		}
+		fmt.Fprintf(&b, "            %s: %s,\n", f.id, v)
+	}
+	b.WriteString("        }\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "impl %s {\n", typeName)
+	b.WriteString("    /// The length of the fixed-size fields in bytes.\n")
+	fmt.Fprintf(&b, "    pub const MIN_LEN: usize = %d;\n", minLen)
+	consts := identifierSet{}
+	for _, f := range fields {
+		if f.vary && f.min > 0 {
+			fmt.Fprintf(&b, "    pub const %s_MIN_LEN: usize = %d;\n", strings.ToUpper(f.id), f.min)
+		}
+		if f.vary {
+			fmt.Fprintf(&b, "    pub const %s_MAX_LEN: usize = %d;\n", strings.ToUpper(f.id), f.max)
+		}
+		for _, v := range f.values {
+			name := strings.ToUpper(consts.add(f.id + " " + v.Label))
+			fmt.Fprintf(&b, "    pub const %s: %s = %s;\n", name, f.typ, rustHex(v.Value))
+		}
+	}
+
+	b.WriteString("\n    pub fn parse(buf: &[u8]) -> Result<Self, Error> {\n")
+	b.WriteString("        if buf.len() < Self::MIN_LEN {\n")
+	b.WriteString("            return Err(Error::Truncated);\n")
+	b.WriteString("        }\n")
+	for _, f := range fields {
+		if !f.vary {
+			writeRustRead(&b, f)
+			continue
+		}
+
+		// pos is where the variable-length field starts, after the group of
+		// fixed-size fields before it
+		start := groups[f.group]
+		if f.group == 0 {
+			mut := ""
+			if !f.last {
+				mut = "mut "
+			}
+			fmt.Fprintf(&b, "        let %spos = %d;\n", mut, start)
+		} else if start > 0 {
+			fmt.Fprintf(&b, "        pos += %d;\n", start)
+		}
+		switch {
+		case f.length != "":
+			fmt.Fprintf(&b, "        let %s_len = %s as usize;\n", f.id, f.length)
+		case f.last:
+			fmt.Fprintf(&b, "        let %s_len = buf.len() - pos;\n", f.id)
+		default:
+			fmt.Fprintf(&b, "        // what the fields after %s leave of the input; change this to read\n", f.id)
+			b.WriteString("        // its length from the input instead\n")
+			fmt.Fprintf(&b, "        let %s_len = buf.len().saturating_sub(pos + %d);\n", f.id, f.after)
+		}
+		fmt.Fprintf(&b, "        if %s_len > Self::%s_MAX_LEN {\n", f.id, strings.ToUpper(f.id))
+		fmt.Fprintf(&b, "            return Err(Error::TooLong(%s));\n", strconv.Quote(f.id))
+		b.WriteString("        }\n")
+		if f.min > 0 {
+			fmt.Fprintf(&b, "        if %s_len < Self::%s_MIN_LEN {\n", f.id, strings.ToUpper(f.id))
+			fmt.Fprintf(&b, "            return Err(Error::TooShort(%s));\n", strconv.Quote(f.id))
+			b.WriteString("        }\n")
+		}
+		if f.length != "" {
+			end := fmt.Sprintf("pos + %s_len", f.id)
+			if f.after > 0 {
+				end = fmt.Sprintf("%s + %d", end, f.after)
+			}
+			fmt.Fprintf(&b, "        if buf.len() < %s {\n", end)
+			b.WriteString("            return Err(Error::Truncated);\n")
+			b.WriteString("        }\n")
+		}
+		fmt.Fprintf(&b, "        let %s = buf[pos..pos + %s_len].to_vec();\n", f.id, f.id)
+		if !f.last {
+			fmt.Fprintf(&b, "        pos += %s_len;\n", f.id)
+		}
+	}
+	fmt.Fprintf(&b, "        Ok(%s {\n", typeName)
+	for _, f := range fields {
+		fmt.Fprintf(&b, "            %s,\n", f.id)
+	}
+	b.WriteString("        })\n")
+	b.WriteString("    }\n")
+
+	b.WriteString("\n    /// Checks that the fields fit in their bits and variable-length fields\n")
+	b.WriteString("    /// are as long as they may be and their length fields say, as write\n")
+	b.WriteString("    /// assumes.\n")
+	b.WriteString("    pub fn validate(&self) -> Result<(), Error> {\n")
+	for _, f := range fields {
+		switch {
+		case f.vary:
+			fmt.Fprintf(&b, "        if self.%s.len() > Self::%s_MAX_LEN {\n", f.id, strings.ToUpper(f.id))
+			fmt.Fprintf(&b, "            return Err(Error::TooLong(%s));\n", strconv.Quote(f.id))
+			b.WriteString("        }\n")
+			if f.min > 0 {
+				fmt.Fprintf(&b, "        if self.%s.len() < Self::%s_MIN_LEN {\n", f.id, strings.ToUpper(f.id))
+				fmt.Fprintf(&b, "            return Err(Error::TooShort(%s));\n", strconv.Quote(f.id))
+				b.WriteString("        }\n")
+			}
+			if f.length != "" {
+				fmt.Fprintf(&b, "        if self.%s as usize != self.%s.len() {\n", f.length, f.id)
+				fmt.Fprintf(&b, "            return Err(Error::LengthMismatch(%s));\n", strconv.Quote(f.id))
+				b.WriteString("        }\n")
+			}
+		case f.array == 0 && f.bits < rustTypeBits(f.typ):
+			fmt.Fprintf(&b, "        if self.%s >> %d != 0 {\n", f.id, f.bits)
+			fmt.Fprintf(&b, "            return Err(Error::OutOfRange(%s));\n", strconv.Quote(f.id))
+			b.WriteString("        }\n")
+		}
+	}
+	b.WriteString("        Ok(())\n")
+	b.WriteString("    }\n")
+
+	b.WriteString("\n    /// Appends the packet to out. Bits of fields beyond their width are\n")
+	b.WriteString("    /// dropped and variable-length fields are written whole; see validate.\n")
+	b.WriteString("    pub fn write(&self, out: &mut Vec<u8>) {\n")
+	group := -1
+	for _, f := range fields {
+		if f.vary {
+			fmt.Fprintf(&b, "        out.extend_from_slice(&self.%s);\n", f.id)
+			continue
+		}
+		if f.group != group {
+			group = f.group
+			b.WriteString("        let at = out.len();\n")
+			fmt.Fprintf(&b, "        out.resize(at + %d, 0);\n", groups[group])
+		}
+		writeRustWrite(&b, f)
+	}
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+const rustErrorType = `#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Error {
+    /// The input is shorter than the fixed-size fields.
+    Truncated,
+    /// A variable-length field is longer than it may be.
+    TooLong(&'static str),
+    /// A variable-length field is shorter than it must be.
+    TooShort(&'static str),
+    /// A variable-length field is not as long as its length field says.
+    LengthMismatch(&'static str),
+    /// A field does not fit in its bits.
+    OutOfRange(&'static str),
+}
+
+impl std::fmt::Display for Error {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Error::Truncated => write!(f, "the input is too short"),
+            Error::TooLong(field) => write!(f, "{} is longer than it may be", field),
+            Error::TooShort(field) => write!(f, "{} is shorter than it must be", field),
+            Error::LengthMismatch(field) => write!(f, "{} is not as long as its length field says", field),
+            Error::OutOfRange(field) => write!(f, "{} does not fit in its bits", field),
+        }
+    }
+}
+
+impl std::error::Error for Error {}
+
+`
+
+// rustFields returns the fields of the struct and the length in bytes of
+// each group of fixed-size fields.
+func rustFields(def *Definition) ([]rustField, []uint, error) {
+	ids := identifierSet{}
+	orders := def.getFieldOrders()
+	fields := make([]rustField, 0, len(def.Placements))
+	groups := []uint{0}
+
+	bit := uint(0) // within the current group
+	for i, p := range def.Placements {
+		label := p.Label
+		if p.Name != "" {
+			label = p.Name
+		}
+		id := ids.add(label)
+		if rustKeywords[id] {
+			id = ids.add(id + " field")
+		}
+		f := rustField{id: id, label: p.Label, group: len(groups) - 1, offset: bit, le: orders[i].littleEndian}
+
+		switch {
+		case p.VariableLength != nil:
+			if bit%8 != 0 {
+				return nil, nil, errors.Errorf("placement %s: variable-length fields must start on a byte boundary", p.GetKey())
+			}
+			f.typ, f.vary, f.last = "Vec<u8>", true, i == len(def.Placements)-1
+			f.max = (p.VariableLength.MaxBits + 7) / 8
+			f.min = (p.VariableLength.GetMinBits() + 7) / 8
+			if j := def.getLengthField(p); j >= 0 {
+				if j > i {
+					return nil, nil, errors.Errorf("placement %s: Rust reads the length field %s after it", p.GetKey(), p.VariableLength.LengthField)
+				}
+				if fields[j].array > 0 {
+					return nil, nil, errors.Errorf("placement %s: length field %s must be 64 bits or fewer", p.GetKey(), p.VariableLength.LengthField)
+				}
+				f.length = fields[j].id
+			}
+			groups[len(groups)-1] = bit / 8
+			groups = append(groups, 0)
+			bit = 0
+		case p.Bits != nil:
+			n := *p.Bits
+			f.bits = n
+			switch {
+			case n <= 8:
+				f.typ = "u8"
+			case n <= 16:
+				f.typ = "u16"
+			case n <= 32:
+				f.typ = "u32"
+			case n <= 64:
+				f.typ = "u64"
+			case bit%8 == 0 && n%8 == 0:
+				f.array = n / 8
+				f.typ = fmt.Sprintf("[u8; %d]", f.array)
+			default:
+				return nil, nil, errors.Errorf("placement %s: fields over 64 bits must be whole bytes on a byte boundary", p.GetKey())
+			}
+			if p.Reset != nil {
+				f.reset = *p.Reset
+			}
+			if f.array == 0 {
+				f.values = p.Values
+			}
+			bit += n
+		default:
+			return nil, nil, errors.Errorf("placement %s has no size", p.GetKey())
+		}
+		fields = append(fields, f)
+	}
+	groups[len(groups)-1] = (bit + 7) / 8
+
+	// the fixed-size bytes after each variable-length field
+	for i := range fields {
+		if !fields[i].vary {
+			continue
+		}
+		for _, g := range groups[fields[i].group+1:] {
+			fields[i].after += g
+		}
+	}
+	return fields, groups, nil
+}
+
+func rustTypeBits(typ string) uint {
+	n, _ := strconv.Atoi(strings.TrimPrefix(typ, "u"))
+	return uint(n)
+}
+
+func rustHex(v uint64) string {
+	if v < 10 {
+		return strconv.FormatUint(v, 10)
+	}
+	return fmt.Sprintf("0x%x", v)
+}
+
+// rustIndex is the index of a byte of a group in parse, where groups after
+// the first start at pos.
+func rustIndex(f rustField, byteOffset uint) string {
+	if f.group == 0 {
+		return strconv.FormatUint(uint64(byteOffset), 10)
+	}
+	if byteOffset == 0 {
+		return "pos"
+	}
+	return fmt.Sprintf("pos + %d", byteOffset)
+}
+
+func rustAccumulator(bytes uint) (string, uint) {
+	for _, n := range []uint{8, 16, 32, 64, 128} {
+		if bytes*8 <= n {
+			return "u" + strconv.Itoa(int(n)), n
+		}
+	}
+	return "u128", 128
+}
+
+// rustShift is the shift of a field within the k bytes holding it read as
+// an integer, from the least significant bit, and the shift of the j-th of
+// those bytes within the integer.
+func rustShift(f rustField, k uint) (shift uint, byteShift func(j uint) uint) {
+	in := f.offset % 8
+	if f.le {
+		return in, func(j uint) uint { return 8 * j }
+	}
+	return k*8 - in - f.bits, func(j uint) uint { return 8 * (k - 1 - j) }
+}
+
+func writeRustRead(b *strings.Builder, f rustField) {
+	s, in := f.offset/8, f.offset%8
+	if f.array > 0 {
+		fmt.Fprintf(b, "        let mut %s = [0; %d];\n", f.id, f.array)
+		fmt.Fprintf(b, "        %s.copy_from_slice(&buf[%s..%s]);\n", f.id, rustIndex(f, s), rustIndex(f, s+f.array))
+		return
+	}
+
+	k := (in + f.bits + 7) / 8
+	shift, byteShift := rustShift(f, k)
+	acc, accBits := rustAccumulator(k)
+
+	if in == 0 && f.bits == rustTypeBits(f.typ) && acc == f.typ {
+		if k == 1 {
+			fmt.Fprintf(b, "        let %s = buf[%s];\n", f.id, rustIndex(f, s))
+			return
+		}
+		bytes := make([]string, 0, k)
+		for j := uint(0); j < k; j++ {
+			bytes = append(bytes, fmt.Sprintf("buf[%s]", rustIndex(f, s+j)))
+		}
+		fmt.Fprintf(b, "        let %s = %s::%s([%s]);\n", f.id, f.typ, rustBytesFunc("from", f.le), strings.Join(bytes, ", "))
+		return
+	}
+
+	terms := make([]string, 0, k)
+	for j := uint(0); j < k; j++ {
+		term := fmt.Sprintf("buf[%s]", rustIndex(f, s+j))
+		if acc != "u8" {
+			term = fmt.Sprintf("%s::from(%s)", acc, term)
+		}
+		if sh := byteShift(j); sh > 0 {
+			term = fmt.Sprintf("%s << %d", term, sh)
+		}
+		terms = append(terms, term)
+	}
+	expr := strings.Join(terms, " | ")
+	if shift > 0 {
+		if k > 1 {
+			expr = "(" + expr + ")"
+		}
+		expr = fmt.Sprintf("%s >> %d", expr, shift)
+	}
+	if f.bits < accBits-shift {
+		if k > 1 || shift > 0 {
+			expr = "(" + expr + ")"
+		}
+		expr = fmt.Sprintf("%s & %s", expr, rustMask(f.bits))
+	}
+	if acc != f.typ {
+		expr = fmt.Sprintf("(%s) as %s", expr, f.typ)
+	}
+	fmt.Fprintf(b, "        let %s = %s;\n", f.id, expr)
+}
+
+func writeRustWrite(b *strings.Builder, f rustField) {
+	s, in := f.offset/8, f.offset%8
+	at := func(i uint) string {
+		if i == 0 {
+			return "at"
+		}
+		return fmt.Sprintf("at + %d", i)
+	}
+	if f.array > 0 {
+		fmt.Fprintf(b, "        out[%s..%s].copy_from_slice(&self.%s);\n", at(s), at(s+f.array), f.id)
+		return
+	}
+
+	k := (in + f.bits + 7) / 8
+	shift, byteShift := rustShift(f, k)
+	acc, _ := rustAccumulator(k)
+
+	if in == 0 && f.bits == rustTypeBits(f.typ) && acc == f.typ {
+		if k == 1 {
+			fmt.Fprintf(b, "        out[%s] = self.%s;\n", at(s), f.id)
+			return
+		}
+		fmt.Fprintf(b, "        out[%s..%s].copy_from_slice(&self.%s.%s());\n", at(s), at(s+k), f.id, rustBytesFunc("to", f.le))
+		return
+	}
+
+	v := fmt.Sprintf("self.%s", f.id)
+	if acc != f.typ {
+		v = fmt.Sprintf("%s::from(%s)", acc, v)
+	}
+	if f.bits < rustTypeBits(acc) {
+		v = fmt.Sprintf("%s & %s", v, rustMask(f.bits))
+		if shift > 0 {
+			v = "(" + v + ")"
+		}
+	}
+	if shift > 0 {
+		v = fmt.Sprintf("%s << %d", v, shift)
+	}
+	if k == 1 {
+		fmt.Fprintf(b, "        out[%s] |= %s;\n", at(s), v)
+		return
+	}
+	fmt.Fprintf(b, "        let v = %s;\n", v)
+	for j := uint(0); j < k; j++ {
+		sh := byteShift(j)
+		if sh == 0 {
+			fmt.Fprintf(b, "        out[%s] |= v as u8;\n", at(s+j))
+		} else {
+			fmt.Fprintf(b, "        out[%s] |= (v >> %d) as u8;\n", at(s+j), sh)
+		}
+	}
+}
+
+// rustBytesFunc names the conversion between integers and their bytes, as in
+// from_be_bytes or to_le_bytes.
+func rustBytesFunc(dir string, le bool) string {
+	if le {
+		return dir + "_le_bytes"
+	}
+	return dir + "_be_bytes"
+}
+
+func rustMask(bits uint) string {
+	return fmt.Sprintf("0x%x", uint64(1)<<bits-1)
+}
@@ -0,0 +1,51 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestExportRust(t *testing.T) {
+	testGolden(t, goldenDefinitions, "testdata/rust", ".rs", ExportRust)
+}
+
+func TestExportRustErrors(t *testing.T) {
+	testData := []struct {
+		Name       string
+		Placements []Placement
+		Error      string
+	}{
+		{
+			Name:       "unaligned variable-length",
+			Placements: []Placement{{Label: "a", Bits: uintp(3)}, {Label: "b", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}}},
+			Error:      "placement b: variable-length fields must start on a byte boundary",
+		},
+		{
+			Name:       "wide unaligned",
+			Placements: []Placement{{Label: "a", Bits: uintp(4)}, {Label: "b", Bits: uintp(68)}},
+			Error:      "placement b: fields over 64 bits must be whole bytes on a byte boundary",
+		},
+		{
+			Name:       "length field after",
+			Placements: []Placement{{Label: "b", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32, LengthField: "a"}}, {Label: "a", Bits: uintp(8)}},
+			Error:      "placement b: Rust reads the length field a after it",
+		},
+		{
+			Name:       "wide length field",
+			Placements: []Placement{{Label: "a", Bits: uintp(72)}, {Label: "b", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32, LengthField: "a"}}},
+			Error:      "placement b: length field a must be 64 bits or fewer",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			err := ExportRust(&Definition{Placements: tt.Placements}, &buf)
+			assert.EqualError(t, err, tt.Error)
+		})
+	}
+}
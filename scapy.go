@@ -0,0 +1,169 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// scapyReserved are the attributes of Scapy packets and the Python keywords
+// that fields cannot be called.
+var scapyReserved = map[string]bool{
+	"name": true, "fields": true, "fields_desc": true, "payload": true,
+	"underlayer": true, "parent": true, "time": true, "original": true,
+	"explicit": true, "comment": true, "direction": true, "wirelen": true,
+	"and": true, "as": true, "assert": true, "async": true, "await": true,
+	"break": true, "class": true, "continue": true, "def": true, "del": true,
+	"elif": true, "else": true, "except": true, "finally": true, "for": true,
+	"from": true, "global": true, "if": true, "import": true, "in": true,
+	"is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true,
+	"with": true, "yield": true,
+}
+
+// scapyByteFields are the Scapy fields of whole bytes, and their enum
+// counterparts, by width.
+var scapyByteFields = map[uint][2]string{
+	8:  {"ByteField", "ByteEnumField"},
+	16: {"ShortField", "ShortEnumField"},
+	32: {"IntField", "IntEnumField"},
+	64: {"LongField", ""},
+}
+
+// scapyLEByteFields are the little-endian counterparts of scapyByteFields.
+var scapyLEByteFields = map[uint][2]string{
+	8:  {"ByteField", "ByteEnumField"},
+	16: {"LEShortField", "LEShortEnumField"},
+	32: {"LEIntField", "LEIntEnumField"},
+	64: {"LELongField", ""},
+}
+
+// ExportScapy writes a Python module with a Scapy Packet subclass for what
+// def describes. Byte-aligned fields of 8, 16, 32 or 64 bits become
+// ByteField, ShortField, IntField or LongField, other multiples of bytes
+// StrFixedLenField and the rest BitField; fields with values use the enum
+// variant, or BitEnumField. Little-endian fields take the LE variants, and
+// runs of LEBitField pack lsb-first. Reset values are the defaults.
+// Variable-length fields are StrLenFields as long as their length field says.
+// Without one, a trailing variable-length field takes the rest of the packet
+// and any other one is a StrLenField whose length is left to fill in.
+func ExportScapy(def *Definition, w io.Writer) error {
+	title := def.Name
+	if title == "" {
+		title = "Packet"
+	}
+
+	ids := identifierSet{}
+	orders := def.getFieldOrders()
+	imports := map[string]bool{}
+	fields := make([]string, 0, len(def.Placements))
+	comments := make([]string, 0, len(def.Placements))
+	fieldIDs := make([]string, 0, len(def.Placements))
+
+	offset := uint(0) // within the current byte
+	for i, p := range def.Placements {
+		label := p.Label
+		if p.Name != "" {
+			label = p.Name
+		}
+		id := ids.add(label)
+		if scapyReserved[id] {
+			id = ids.add(label + " field")
+		}
+		fieldIDs = append(fieldIDs, id)
+		name := strconv.Quote(id)
+
+		var field, comment string
+		switch {
+		case p.VariableLength != nil:
+			if offset != 0 {
+				return errors.Errorf("placement %s: variable-length fields must start on a byte boundary", p.GetKey())
+			}
+			if j := def.getLengthField(p); j >= 0 {
+				if j > i {
+					return errors.Errorf("placement %s: Scapy reads the length field %s after it", p.GetKey(), p.VariableLength.LengthField)
+				}
+				field = fmt.Sprintf(`StrLenField(%s, b"", length_from=lambda pkt: pkt.%s, max_length=%d)`, name, fieldIDs[j], (p.VariableLength.MaxBits+7)/8)
+				break
+			}
+			if i == len(def.Placements)-1 {
+				field = fmt.Sprintf(`StrField(%s, b"")`, name)
+				break
+			}
+			field = fmt.Sprintf(`StrLenField(%s, b"", length_from=lambda pkt: 0, max_length=%d)`, name, (p.VariableLength.MaxBits+7)/8)
+			comment = "  # give the length in bytes"
+		case p.Bits != nil:
+			n := *p.Bits
+			reset := uint64(0)
+			if p.Reset != nil {
+				reset = *p.Reset
+			}
+			values := scapyEnum(p.Values)
+
+			byteFields, ok := scapyByteFields[n]
+			bitField, bitEnumField := "BitField", "BitEnumField"
+			if orders[i].littleEndian {
+				byteFields, ok = scapyLEByteFields[n]
+				bitField, bitEnumField = "LEBitField", "LEBitEnumField"
+			}
+			switch {
+			case offset == 0 && ok && (values == "" || byteFields[1] != ""):
+				if values == "" {
+					field = fmt.Sprintf("%s(%s, %d)", byteFields[0], name, reset)
+				} else {
+					field = fmt.Sprintf("%s(%s, %d, %s)", byteFields[1], name, reset, values)
+				}
+			case offset == 0 && n%8 == 0 && n > 64:
+				field = fmt.Sprintf(`StrFixedLenField(%s, b"", length=%d)`, name, n/8)
+			case values != "":
+				field = fmt.Sprintf("%s(%s, %d, %d, %s)", bitEnumField, name, reset, n, values)
+			default:
+				field = fmt.Sprintf("%s(%s, %d, %d)", bitField, name, reset, n)
+			}
+			offset = (offset + n) % 8
+		default:
+			return errors.Errorf("placement %s has no size", p.GetKey())
+		}
+		imports[field[:strings.Index(field, "(")]] = true
+		fields = append(fields, field)
+		comments = append(comments, comment)
+	}
+
+	names := make([]string, 0, len(imports))
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Scapy layer for %s, generated by packet-diagram.\n\n", title)
+	if len(names) > 0 {
+		fmt.Fprintf(&b, "from scapy.fields import %s\n", strings.Join(names, ", "))
+	}
+	b.WriteString("from scapy.packet import Packet\n\n\n")
+	fmt.Fprintf(&b, "class %s(Packet):\n", pascalCaseIdentifier(title))
+	fmt.Fprintf(&b, "    name = %s\n", strconv.Quote(title))
+	b.WriteString("    fields_desc = [\n")
+	for i, f := range fields {
+		fmt.Fprintf(&b, "        %s,%s\n", f, comments[i])
+	}
+	b.WriteString("    ]\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func scapyEnum(values []ValueSpec) string {
+	if len(values) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(values))
+	for _, v := range values {
+		items = append(items, fmt.Sprintf("%d: %s", v.Value, strconv.Quote(v.Label)))
+	}
+	return "{" + strings.Join(items, ", ") + "}"
+}
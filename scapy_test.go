@@ -0,0 +1,34 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestExportScapy(t *testing.T) {
+	testGolden(t, goldenDefinitions, "testdata/scapy", ".py", ExportScapy)
+}
+
+func TestExportScapyErrors(t *testing.T) {
+	t.Parallel()
+	def := &Definition{Placements: []Placement{
+		{Label: "a", Bits: uintp(3)},
+		{Label: "b", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}},
+	}}
+	var buf bytes.Buffer
+	err := ExportScapy(def, &buf)
+	assert.EqualError(t, err, "placement b: variable-length fields must start on a byte boundary")
+}
+
+func TestExportScapyLengthFieldAfter(t *testing.T) {
+	t.Parallel()
+	def := &Definition{Placements: []Placement{
+		{Label: "a", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32, LengthField: "len"}},
+		{Label: "len", Bits: uintp(8)},
+	}}
+	var buf bytes.Buffer
+	err := ExportScapy(def, &buf)
+	assert.EqualError(t, err, "placement a: Scapy reads the length field len after it")
+}
@@ -0,0 +1,135 @@
+package packetdiagram
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const (
+	jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+	jsonSchemaID    = "https://raw.githubusercontent.com/bitbears-dev/packet-diagram/main/schema/packet-diagram.schema.json"
+)
+
+// schemaEnum is implemented by string types that only take a fixed set of
+// values, so that editors can offer them as completions.
+type schemaEnum interface {
+	schemaEnum() []string
+}
+
+// schemaExtension is implemented by types whose constraints cannot be
+// derived from their fields alone.
+type schemaExtension interface {
+	extendSchema(schema map[string]interface{})
+}
+
+func (XAxisBitsDirection) schemaEnum() []string {
+	return []string{
+		string(XAxisBitsDirectionLeftToRight),
+		string(XAxisBitsDirectionRightToLeft),
+	}
+}
+
+func (Placement) extendSchema(schema map[string]interface{}) {
+	schema["anyOf"] = []interface{}{
+		map[string]interface{}{"required": []string{"bits"}},
+		map[string]interface{}{"required": []string{"variable-length"}},
+		map[string]interface{}{"required": []string{"structure"}},
+	}
+}
+
+// WriteJSONSchema writes a JSON Schema describing definition files, derived
+// from the Definition type. Each document of a multi-document YAML file
+// follows it. The same schema applies to YAML, JSON and TOML
+// definitions.
+func WriteJSONSchema(w io.Writer) error {
+	schema := typeSchema(reflect.TypeOf(definitionFile{}))
+	schema["$schema"] = jsonSchemaDraft
+	schema["$id"] = jsonSchemaID
+	schema["title"] = "packet-diagram definition"
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	schema := map[string]interface{}{}
+	switch t.Kind() {
+	case reflect.Struct:
+		schema["type"] = "object"
+		schema["additionalProperties"] = false
+		properties := map[string]interface{}{}
+		for _, f := range yamlFields(t) {
+			properties[f.name] = typeSchema(f.typ)
+		}
+		schema["properties"] = properties
+	case reflect.Slice, reflect.Array:
+		schema["type"] = "array"
+		schema["items"] = typeSchema(t.Elem())
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = typeSchema(t.Elem())
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	case reflect.String:
+		schema["type"] = "string"
+	}
+
+	v := reflect.Zero(t).Interface()
+	if e, ok := v.(schemaEnum); ok {
+		schema["enum"] = e.schemaEnum()
+	}
+	if e, ok := v.(schemaExtension); ok {
+		e.extendSchema(schema)
+	}
+
+	return schema
+}
+
+type yamlField struct {
+	name string
+	typ  reflect.Type
+}
+
+// yamlFields lists the keys a struct is read from, in declaration order,
+// with the fields of inlined structs in place.
+func yamlFields(t reflect.Type) []yamlField {
+	fields := make([]yamlField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		parts := strings.Split(f.Tag.Get("yaml"), ",")
+		name := parts[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && len(parts) > 1 && parts[1] == "inline" {
+			fields = append(fields, yamlFields(f.Type)...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, yamlField{name: name, typ: f.Type})
+	}
+	return fields
+}
@@ -0,0 +1,212 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+
+	svg "github.com/ajstarks/svgo"
+	"github.com/pkg/errors"
+)
+
+const (
+	stackBandHeight     = 40
+	stackBandGap        = 10
+	stackPixelsPerOctet = 8
+	stackMinHeaderWidth = 100
+	stackPayloadWidth   = 120
+	stackLabelCharWidth = 8
+	stackZoomHeight     = 60
+	stackDetailGap      = 40
+	stackPayloadLabel   = "payload"
+)
+
+// StackLayer is one header of a protocol stack, outermost first.
+type StackLayer struct {
+	Label      string
+	Definition *Definition
+}
+
+// GetLabel returns the label of the layer, falling back to the name of its
+// definition.
+func (l StackLayer) GetLabel() string {
+	if l.Label != "" {
+		return l.Label
+	}
+	return l.Definition.Name
+}
+
+// GetLength describes how many octets the header takes up, as a range when
+// it has variable-length placements.
+func (l StackLayer) GetLength() string {
+	min := uint(0)
+	for _, p := range l.Definition.Placements {
+		if p.VariableLength == nil {
+			min += p.GetBits()
+		} else {
+			min += p.VariableLength.GetMinBits()
+		}
+	}
+	max := l.Definition.GetTotalPlacementBits()
+
+	if min == max {
+		if max > 0 && max <= 8 {
+			return "1 byte"
+		}
+		return fmt.Sprintf("%d bytes", (max+7)/8)
+	}
+	return fmt.Sprintf("%d-%d bytes", (min+7)/8, (max+7)/8)
+}
+
+// DrawStack draws the layers of a protocol stack as bands, each nested in
+// the payload of the one before it, above the detailed diagram of every
+// layer. Zoom lines link each band to its diagram.
+func DrawStack(layers []StackLayer, out io.Writer) error {
+	return drawStack(layers, svg.New(out))
+}
+
+func DrawStackPNG(layers []StackLayer, out io.Writer) error {
+	canvas := newRasterSurface()
+	err := drawStack(layers, canvas)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(out, canvas.img)
+}
+
+func RenderStack(layers []StackLayer, format Format, out io.Writer) error {
+	switch format {
+	case FormatSVG:
+		return DrawStack(layers, out)
+	case FormatPNG:
+		return DrawStackPNG(layers, out)
+	default:
+		return errors.Errorf("unsupported format for stack diagrams: %s", format)
+	}
+}
+
+// stackLayout holds where the parts of a stack diagram go.
+type stackLayout struct {
+	headerXs     []uint
+	headerWidths []uint
+	bandsWidth   uint
+	bandsHeight  uint
+
+	detailXs   []uint
+	detailY    uint
+	detailDims []Dimensions
+
+	canvas Dimension
+}
+
+func calculateStackLayout(layers []StackLayer) stackLayout {
+	l := stackLayout{
+		headerXs:     make([]uint, len(layers)),
+		headerWidths: make([]uint, len(layers)),
+		detailXs:     make([]uint, len(layers)),
+		detailDims:   make([]Dimensions, len(layers)),
+	}
+
+	x := uint(0)
+	for i, layer := range layers {
+		l.headerXs[i] = x
+		l.headerWidths[i] = maxUint(
+			stackMinHeaderWidth,
+			layer.Definition.GetTotalPlacementOctets()*stackPixelsPerOctet,
+			uint(len(layer.GetLabel())+2)*stackLabelCharWidth,
+			uint(len(layer.GetLength())+2)*stackLabelCharWidth,
+		)
+		x += l.headerWidths[i]
+	}
+	l.bandsWidth = x + stackPayloadWidth
+	l.bandsHeight = uint(len(layers)+1)*(stackBandHeight+stackBandGap) - stackBandGap
+
+	l.detailY = l.bandsHeight + stackZoomHeight
+	x = 0
+	detailsHeight := uint(0)
+	for i, layer := range layers {
+		if i > 0 {
+			x += stackDetailGap
+		}
+		l.detailXs[i] = x
+		l.detailDims[i] = calculateDimensions(layer.Definition)
+		x += l.detailDims[i].Canvas.Width
+		detailsHeight = maxUint(detailsHeight, l.detailDims[i].Canvas.Height)
+	}
+
+	l.canvas = Dimension{
+		Width:  maxUint(l.bandsWidth, x),
+		Height: l.detailY + detailsHeight,
+	}
+	return l
+}
+
+func drawStack(layers []StackLayer, canvas surface) error {
+	if len(layers) == 0 {
+		return errors.New("a stack needs at least one layer")
+	}
+	layers = append([]StackLayer(nil), layers...)
+	for i := range layers {
+		if layers[i].GetLabel() == "" {
+			layers[i].Label = fmt.Sprintf("layer %d", i+1)
+		}
+	}
+
+	l := calculateStackLayout(layers)
+	outer := layers[0].Definition
+
+	canvas.Start(int(l.canvas.Width), int(l.canvas.Height))
+	canvas.Style("text/css", getStyleForStack(outer))
+	// each layer is drawn with its own theme
+	for i, layer := range layers {
+		defineScopedStyles(layer.Definition, l.detailDims[i], getStackLayerScope(i), canvas)
+	}
+	defs := make([]*Definition, len(layers))
+	for i := range layers {
+		defs[i] = layers[i].Definition
+	}
+	definePatterns(canvas, defs...)
+	canvas.Rect(0, 0, int(l.canvas.Width), int(l.canvas.Height), "id='background'", fmt.Sprintf("fill='%s'", outer.GetBackgroundColor()), "stroke='none'")
+
+	// zoom lines go first so that the bands they pass hide them
+	for i := range layers {
+		bottom := int(i*(stackBandHeight+stackBandGap) + stackBandHeight)
+		left := int(l.headerXs[i])
+		right := int(l.headerXs[i] + l.headerWidths[i])
+		detailLeft := int(l.detailXs[i])
+		detailRight := int(l.detailXs[i] + l.detailDims[i].Canvas.Width)
+		canvas.Line(left, bottom, detailLeft, int(l.detailY), `class="stack-zoom"`)
+		canvas.Line(right, bottom, detailRight, int(l.detailY), `class="stack-zoom"`)
+	}
+
+	for i, layer := range layers {
+		y := int(i * (stackBandHeight + stackBandGap))
+		x := int(l.headerXs[i])
+		w := int(l.headerWidths[i])
+		canvas.Rect(x, y, w, stackBandHeight, `class="stack-header"`)
+		canvas.Text(x+w/2, y+stackBandHeight/2-2, layer.GetLabel(), `class="stack-label"`)
+		canvas.Text(x+w/2, y+stackBandHeight-6, layer.GetLength(), `class="stack-length"`)
+		canvas.Rect(x+w, y, int(l.bandsWidth)-x-w, stackBandHeight, `class="stack-payload"`)
+	}
+	y := int(len(layers) * (stackBandHeight + stackBandGap))
+	x := int(l.headerXs[len(layers)-1] + l.headerWidths[len(layers)-1])
+	w := int(l.bandsWidth) - x
+	canvas.Rect(x, y, w, stackBandHeight, `class="stack-payload"`)
+	canvas.Text(x+w/2, y+stackBandHeight/2+4, stackPayloadLabel, `class="stack-label"`)
+
+	for i, layer := range layers {
+		canvas.Gtransform(fmt.Sprintf("translate(%d,%d)", l.detailXs[i], l.detailY))
+		canvas.Gid(getStackLayerScope(i))
+		drawDiagram(layer.Definition, l.detailDims[i], canvas)
+		canvas.Gend()
+		canvas.Gend()
+	}
+
+	canvas.End()
+	return nil
+}
+
+func getStackLayerScope(i int) string {
+	return fmt.Sprintf("stack-layer-%d", i+1)
+}
@@ -0,0 +1,97 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestStackLayerGetLength(t *testing.T) {
+	testData := []struct {
+		Name       string
+		Placements []Placement
+		Expected   string
+	}{
+		{
+			Name: "fixed",
+			Placements: []Placement{
+				{Label: "Source Port", Bits: uintp(16)},
+				{Label: "Destination Port", Bits: uintp(16)},
+				{Label: "Length", Bits: uintp(16)},
+				{Label: "Checksum", Bits: uintp(16)},
+			},
+			Expected: "8 bytes",
+		},
+		{
+			Name: "variable-length",
+			Placements: []Placement{
+				{Label: "Header", Bits: uintp(160)},
+				{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320}},
+			},
+			Expected: "20-60 bytes",
+		},
+		{
+			Name: "fewest bits",
+			Placements: []Placement{
+				{Label: "Header", Bits: uintp(160)},
+				{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 320, MinBits: uintp(32)}},
+			},
+			Expected: "24-60 bytes",
+		},
+		{
+			Name: "partial octet",
+			Placements: []Placement{
+				{Label: "Flags", Bits: uintp(3)},
+			},
+			Expected: "1 byte",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			layer := StackLayer{Definition: &Definition{Placements: tt.Placements}}
+			assert.Equal(t, tt.Expected, layer.GetLength())
+		})
+	}
+}
+
+func TestDrawStackLayerThemes(t *testing.T) {
+	t.Parallel()
+	layers := []StackLayer{
+		{Label: "outer", Definition: &Definition{
+			Theme:      &ThemeSpec{Text: &TextSpec{Color: stringp("navy"), Size: stringp("12pt")}},
+			Placements: []Placement{{Label: "a", Bits: uintp(16)}},
+		}},
+		{Label: "inner", Definition: &Definition{
+			Placements: []Placement{{Label: "b", Bits: uintp(16)}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	err := DrawStack(layers, &buf)
+	assert.NoError(t, err)
+	svg := buf.String()
+	assert.Contains(t, svg, `#stack-layer-1 text.placement{fill:navy;font-family:Helvetica;font-size:12pt;`)
+	assert.Contains(t, svg, `#stack-layer-2 text.placement{fill:black;font-family:Helvetica;font-size:9pt;`)
+	assert.Contains(t, svg, `<g id="stack-layer-1">`)
+	assert.Contains(t, svg, `<g id="stack-layer-2">`)
+
+	canvas := newRasterSurface()
+	err = drawStack(layers, canvas)
+	assert.NoError(t, err)
+	navy, _ := parseColor("navy")
+	found := map[bool]int{}
+	b := canvas.img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if canvas.img.At(x, y) == navy {
+				found[x < b.Max.X/2]++
+			}
+		}
+	}
+	assert.True(t, found[true] > 0, "the outer layer keeps its theme")
+	assert.Equal(t, 0, found[false], "the inner layer keeps its own")
+}
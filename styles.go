@@ -3,18 +3,50 @@ package packetdiagram
 import (
 	"fmt"
 	"strings"
-
-	svg "github.com/ajstarks/svgo"
 )
 
-func defineStyles(def *Definition, dim Dimensions, canvas *svg.SVG) {
+func defineStyles(def *Definition, dim Dimensions, canvas surface) {
+	canvas.Style("text/css", getStyles(def, dim))
+}
+
+// defineScopedStyles defines the styles of a diagram drawn in the group with
+// id scope, for diagrams with different themes to share a canvas.
+func defineScopedStyles(def *Definition, dim Dimensions, scope string, canvas surface) {
+	canvas.Style("text/css", scopeStyle(getStyles(def, dim), scope))
+}
+
+func getStyles(def *Definition, dim Dimensions) string {
 	style := getStyleForXAxisBits(def, dim) + "\n"
 	style += getStyleForXAxisOctets(def, dim) + "\n"
 	style += getStyleForYAxisBits(def, dim) + "\n"
 	style += getStyleForYAxisOctets(def, dim) + "\n"
 	style += getStyleForPlacements(def, dim) + "\n"
 	style += getStyleForBreakMark(def, dim) + "\n"
-	canvas.Style("text/css", style)
+	if def.ShouldShowOffsets() {
+		style += getStyleForPlacementOffsets(def, dim) + "\n"
+	}
+	if dim.Header.Height > 0 {
+		style += getStyleForHeader(def, dim) + "\n"
+	}
+	return style
+}
+
+// scopeStyle limits the rules of style to the elements inside the one with
+// id scope.
+func scopeStyle(style, scope string) string {
+	var b strings.Builder
+	for _, rule := range strings.SplitAfter(style, "}") {
+		i := strings.Index(rule, "{")
+		if i < 0 {
+			b.WriteString(rule)
+			continue
+		}
+		selector := strings.TrimLeft(rule[:i], "\n ")
+		b.WriteString(rule[:i-len(selector)])
+		b.WriteString("#" + scope + " " + selector)
+		b.WriteString(rule[i:])
+	}
+	return b.String()
 }
 
 func shrinkStyle(style string) string {
@@ -124,18 +156,121 @@ text.placement{
 	font-family:%s;
 	font-size:%s;
 	text-anchor:middle;
+}
+
+text.placement-register{
+	fill:%s;
+	font-family:%s;
+	font-size:%s;
+	text-anchor:middle;
 }`,
 		def.GetTextColor(),
 		def.GetTextFontFamily(),
 		def.GetTextSize(),
+		def.GetTextColor(),
+		def.GetTextFontFamily(),
+		def.GetAxisTitleTextSize(),
+	))
+}
+
+func getStyleForPlacementOffsets(def *Definition, dim Dimensions) string {
+	return shrinkStyle(fmt.Sprintf(`
+text.placement-offset-start{
+	fill:%s;
+	font-family:%s;
+	font-size:%s;
+	text-anchor:start;
+}
+
+text.placement-offset-end{
+	fill:%s;
+	font-family:%s;
+	font-size:%s;
+	text-anchor:end;
+}`,
+		def.GetTextColor(),
+		def.GetTextFontFamily(),
+		def.GetAxisTitleTextSize(),
+		def.GetTextColor(),
+		def.GetTextFontFamily(),
+		def.GetAxisTitleTextSize(),
 	))
 }
 
 func getStyleForBreakMark(def *Definition, dim Dimensions) string {
-	return shrinkStyle(`
+	return shrinkStyle(fmt.Sprintf(`
 path.breakmark{
 	fill:none;
+	stroke:%s;
+}
+line.breakmark{
+	stroke:%s;
+}
+rect.breakmark-gap{
+	fill:%s;
+	stroke:none;
+}
+polygon.breakmark-gap{
+	fill:%s;
+	stroke:none;
+}
+rect.breakmark-dot{
+	fill:%s;
+	stroke:none;
+}`,
+		def.GetBreakMarkStroke(),
+		def.GetBreakMarkStroke(),
+		def.GetBackgroundColor(),
+		def.GetBackgroundColor(),
+		def.GetBreakMarkStroke(),
+	))
+}
+
+func getStyleForHeader(def *Definition, dim Dimensions) string {
+	return shrinkStyle(fmt.Sprintf(`
+text.header{
+	fill:%s;
+	font-family:%s;
+	font-size:%s;
+	text-anchor:start;
+}`,
+		def.GetTextColor(),
+		def.GetTextFontFamily(),
+		def.GetAxisTitleTextSize(),
+	))
+}
+
+func getStyleForStack(def *Definition) string {
+	return shrinkStyle(fmt.Sprintf(`
+rect.stack-header{
+	fill:white;
 	stroke:black;
+}
+rect.stack-payload{
+	fill:%s;
+	stroke:black;
+	stroke-dasharray:4 2;
+}
+line.stack-zoom{
+	stroke:gray;
+	stroke-dasharray:3 3;
+}
+text.stack-label{
+	fill:%s;
+	font-family:%s;
+	font-size:%s;
+	text-anchor:middle;
+}
+text.stack-length{
+	fill:%s;
+	font-size:%s;
+	text-anchor:middle;
 }`,
-	)
+		def.GetBackgroundColor(),
+		def.GetTextColor(),
+		def.GetTextFontFamily(),
+		def.GetTextSize(),
+		def.GetTextColor(),
+		def.GetAxisTitleTextSize(),
+	))
 }
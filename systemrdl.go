@@ -0,0 +1,639 @@
+package packetdiagram
+
+import (
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const defaultSystemRDLRegWidth = 32
+
+// The subset of SystemRDL 2.0 describing register layouts: addrmap, regfile,
+// reg and field components, named or anonymous, with their instances, bit
+// ranges, reset values, access properties and `default` assignments.
+// Parameters, enums, user-defined properties and dynamic assignments are
+// read but ignored.
+
+type rdlToken struct {
+	text string
+	line int
+	str  bool // a string literal, text unquoted
+}
+
+type rdlComponent struct {
+	kind  string
+	name  string
+	props map[string]string
+	insts []rdlInstance
+}
+
+type rdlInstance struct {
+	comp *rdlComponent
+	name string
+	dims [][]string // [n] or [msb:lsb]
+	at   string     // @ address, or LSB for fields
+	init string     // = reset
+}
+
+type rdlScope struct {
+	parent   *rdlScope
+	types    map[string]*rdlComponent
+	defaults map[string]string
+}
+
+func newRDLScope(parent *rdlScope) *rdlScope {
+	return &rdlScope{
+		parent:   parent,
+		types:    map[string]*rdlComponent{},
+		defaults: map[string]string{},
+	}
+}
+
+func (s *rdlScope) lookup(name string) *rdlComponent {
+	for ; s != nil; s = s.parent {
+		if c, ok := s.types[name]; ok {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *rdlScope) getDefaults() map[string]string {
+	props := map[string]string{}
+	if s.parent != nil {
+		props = s.parent.getDefaults()
+	}
+	for k, v := range s.defaults {
+		props[k] = v
+	}
+	return props
+}
+
+var rdlComponentKinds = map[string]bool{
+	"addrmap": true,
+	"regfile": true,
+	"reg":     true,
+	"field":   true,
+	"mem":     true,
+	"signal":  true,
+}
+
+// ImportSystemRDL reads the registers of a SystemRDL description and makes
+// a register mode Definition of each. Registers are named after the
+// register files and address maps they sit in below the top address map.
+func ImportSystemRDL(r io.Reader) ([]*Definition, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := tokenizeSystemRDL(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &rdlParser{toks: toks, instantiated: map[*rdlComponent]bool{}}
+	root := &rdlComponent{kind: "root", props: map[string]string{}}
+	scope := newRDLScope(nil)
+	err = p.parseBody(scope, root)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, p.errorf("unexpected `%s`", p.peek().text)
+	}
+
+	// the tops are the address maps (or, lacking them, registers) defined
+	// but never instantiated, and anything instantiated at the root
+	tops := append([]rdlInstance(nil), root.insts...)
+	for _, kind := range []string{"addrmap", "reg"} {
+		for _, c := range p.defined {
+			if c.kind == kind && !p.instantiated[c] {
+				tops = append(tops, rdlInstance{comp: c, name: c.name})
+			}
+		}
+		if len(tops) > 0 {
+			break
+		}
+	}
+
+	defs := make([]*Definition, 0)
+	for _, top := range tops {
+		if top.comp.kind == "reg" {
+			def, err := importSystemRDLRegister(top.name, top.comp)
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, def)
+			continue
+		}
+		d, err := importSystemRDLComponent(nil, top.comp)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, d...)
+	}
+	if len(defs) == 0 {
+		return nil, errors.New("no registers found")
+	}
+	return defs, nil
+}
+
+func importSystemRDLComponent(path []string, c *rdlComponent) ([]*Definition, error) {
+	defs := make([]*Definition, 0)
+	for _, inst := range c.insts {
+		p := append(append([]string(nil), path...), inst.name)
+		switch inst.comp.kind {
+		case "addrmap", "regfile":
+			d, err := importSystemRDLComponent(p, inst.comp)
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d...)
+		case "reg":
+			def, err := importSystemRDLRegister(strings.Join(p, "."), inst.comp)
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+func importSystemRDLRegister(name string, reg *rdlComponent) (*Definition, error) {
+	width := uint64(defaultSystemRDLRegWidth)
+	if v, ok := reg.props["regwidth"]; ok {
+		w, err := parseHDLNumber(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "register %s: regwidth", name)
+		}
+		width = w
+	}
+
+	fields := make([]registerField, 0, len(reg.insts))
+	next := uint64(0) // where a field without a position goes
+	for _, inst := range reg.insts {
+		if inst.comp.kind != "field" {
+			continue
+		}
+
+		f := registerField{name: inst.name, width: 1}
+		if v, ok := inst.comp.props["fieldwidth"]; ok {
+			w, err := parseHDLNumber(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "register %s: field %s: fieldwidth", name, inst.name)
+			}
+			f.width = uint(w)
+		}
+		lsb := next
+
+		switch {
+		case len(inst.dims) == 1 && len(inst.dims[0]) == 2:
+			hi, err := parseHDLNumber(inst.dims[0][0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "register %s: field %s", name, inst.name)
+			}
+			lo, err := parseHDLNumber(inst.dims[0][1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "register %s: field %s", name, inst.name)
+			}
+			if hi < lo {
+				hi, lo = lo, hi
+			}
+			lsb = lo
+			f.width = uint(hi - lo + 1)
+		case len(inst.dims) == 1:
+			w, err := parseHDLNumber(inst.dims[0][0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "register %s: field %s", name, inst.name)
+			}
+			f.width = uint(w)
+		case len(inst.dims) > 1:
+			return nil, errors.Errorf("register %s: field %s: arrays of fields are not supported", name, inst.name)
+		}
+		if inst.at != "" {
+			v, err := parseHDLNumber(inst.at)
+			if err != nil {
+				return nil, errors.Wrapf(err, "register %s: field %s", name, inst.name)
+			}
+			lsb = v
+		}
+		f.lsb = uint(lsb)
+		next = lsb + uint64(f.width)
+
+		reset := inst.init
+		if reset == "" {
+			reset = inst.comp.props["reset"]
+		}
+		if reset != "" {
+			v, err := parseHDLNumber(reset)
+			if err != nil {
+				return nil, errors.Wrapf(err, "register %s: field %s: reset", name, inst.name)
+			}
+			f.reset = &v
+		}
+		f.access = systemRDLAccessType(inst.comp.props)
+
+		fields = append(fields, f)
+	}
+
+	return newRegisterDefinition(name, uint(width), fields)
+}
+
+func systemRDLAccessType(props map[string]string) *AccessType {
+	flag := func(name string) bool {
+		v, ok := props[name]
+		return ok && v != "false"
+	}
+
+	var t AccessType
+	switch {
+	case props["onread"] == "rclr" || flag("rclr"):
+		t = AccessTypeRC
+	case props["onread"] == "rset" || flag("rset"):
+		t = AccessTypeRS
+	case props["onwrite"] == "woclr" || flag("woclr"):
+		t = AccessTypeW1C
+	case props["onwrite"] == "woset" || flag("woset"):
+		t = AccessTypeW1S
+	case props["onwrite"] == "wot":
+		t = AccessTypeW1T
+	}
+	if t != "" {
+		return &t
+	}
+
+	switch props["sw"] {
+	case "r":
+		t = AccessTypeRO
+	case "w", "w1":
+		t = AccessTypeWO
+	case "", "rw", "wr", "rw1":
+		t = AccessTypeRW
+	default:
+		return nil
+	}
+	return &t
+}
+
+type rdlParser struct {
+	toks []rdlToken
+	pos  int
+
+	defined      []*rdlComponent // named components, in order
+	instantiated map[*rdlComponent]bool
+}
+
+func (p *rdlParser) peek() rdlToken {
+	if p.pos >= len(p.toks) {
+		return rdlToken{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *rdlParser) peekAt(n int) rdlToken {
+	if p.pos+n >= len(p.toks) {
+		return rdlToken{}
+	}
+	return p.toks[p.pos+n]
+}
+
+func (p *rdlParser) next() rdlToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *rdlParser) expect(text string) error {
+	t := p.next()
+	if t.text != text || t.str {
+		if t.text == "" {
+			return p.errorf("expected `%s` but the input ended", text)
+		}
+		return errors.Errorf("line %d: expected `%s` but found `%s`", t.line, text, t.text)
+	}
+	return nil
+}
+
+func (p *rdlParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if p.pos < len(p.toks) {
+		line = p.toks[p.pos].line
+	} else if len(p.toks) > 0 {
+		line = p.toks[len(p.toks)-1].line
+	}
+	return errors.Errorf("line %d: "+format, append([]interface{}{line}, args...)...)
+}
+
+// parseBody reads the items of a component body up to its closing brace, or
+// to the end of input at the root.
+func (p *rdlParser) parseBody(scope *rdlScope, comp *rdlComponent) error {
+	for p.pos < len(p.toks) {
+		t := p.peek()
+		if t.str {
+			return p.errorf("unexpected string")
+		}
+
+		switch {
+		case t.text == "}":
+			return nil
+		case t.text == ";":
+			p.next()
+		case rdlComponentKinds[t.text]:
+			err := p.parseComponent(scope, comp)
+			if err != nil {
+				return err
+			}
+		case t.text == "enum" || t.text == "property" || t.text == "constraint":
+			p.skipStatement()
+		case t.text == "external" || t.text == "internal":
+			p.next()
+		case t.text == "default":
+			p.next()
+			err := p.parseProperty(scope.defaults)
+			if err != nil {
+				return err
+			}
+		case p.peekAt(1).text == "->" || p.peekAt(1).text == "." || p.peekAt(1).text == "[":
+			// dynamic assignments to instances are not followed
+			p.skipStatement()
+		case isRDLIdentifier(t.text) && isRDLIdentifier(p.peekAt(1).text) && !p.peekAt(1).str:
+			p.next()
+			c := scope.lookup(t.text)
+			if c == nil {
+				return errors.Errorf("line %d: unknown component type %s", t.line, t.text)
+			}
+			p.instantiated[c] = true
+			err := p.parseInstances(comp, c)
+			if err != nil {
+				return err
+			}
+		default:
+			err := p.parseProperty(comp.props)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *rdlParser) parseComponent(scope *rdlScope, parent *rdlComponent) error {
+	c := &rdlComponent{kind: p.next().text}
+	if t := p.peek(); isRDLIdentifier(t.text) && !t.str {
+		c.name = p.next().text
+	}
+	if p.peek().text == "#" {
+		// parameters
+		p.next()
+		p.skipBalanced("(", ")")
+	}
+
+	inner := newRDLScope(scope)
+	c.props = scope.getDefaults()
+	err := p.expect("{")
+	if err != nil {
+		return err
+	}
+	err = p.parseBody(inner, c)
+	if err != nil {
+		return err
+	}
+	err = p.expect("}")
+	if err != nil {
+		return err
+	}
+
+	if c.name != "" {
+		scope.types[c.name] = c
+		p.defined = append(p.defined, c)
+	}
+	if p.peek().text == ";" {
+		p.next()
+		return nil
+	}
+	p.instantiated[c] = true
+	return p.parseInstances(parent, c)
+}
+
+func (p *rdlParser) parseInstances(parent, c *rdlComponent) error {
+	for {
+		t := p.next()
+		if !isRDLIdentifier(t.text) || t.str {
+			return errors.Errorf("line %d: expected an instance name but found `%s`", t.line, t.text)
+		}
+		inst := rdlInstance{comp: c, name: t.text}
+
+		for p.peek().text == "[" {
+			p.next()
+			dim := []string{p.parseValue()}
+			if p.peek().text == ":" {
+				p.next()
+				dim = append(dim, p.parseValue())
+			}
+			err := p.expect("]")
+			if err != nil {
+				return err
+			}
+			inst.dims = append(inst.dims, dim)
+		}
+
+		for {
+			switch p.peek().text {
+			case "=":
+				p.next()
+				inst.init = p.parseValue()
+				continue
+			case "@":
+				p.next()
+				inst.at = p.parseValue()
+				continue
+			case "+=", "%=":
+				p.next()
+				p.parseValue()
+				continue
+			}
+			break
+		}
+		parent.insts = append(parent.insts, inst)
+
+		if p.peek().text == "," {
+			p.next()
+			continue
+		}
+		return p.expect(";")
+	}
+}
+
+// parseProperty reads `name;` or `name = value;` into props.
+func (p *rdlParser) parseProperty(props map[string]string) error {
+	t := p.next()
+	if !isRDLIdentifier(t.text) {
+		return errors.Errorf("line %d: unexpected `%s`", t.line, t.text)
+	}
+
+	value := "true"
+	if p.peek().text == "=" {
+		p.next()
+		value = p.parseValue()
+	}
+	props[t.text] = value
+	return p.expect(";")
+}
+
+// parseValue reads the tokens of a value up to the punctuation ending it,
+// keeping expressions as text.
+func (p *rdlParser) parseValue() string {
+	parts := make([]string, 0, 1)
+	depth := 0
+	for p.pos < len(p.toks) {
+		t := p.peek()
+		if !t.str && depth == 0 {
+			switch t.text {
+			case ";", ",", "]", ":", "@", "+=", "%=", "=", "{", "}":
+				return strings.Join(parts, "")
+			}
+		}
+		if !t.str {
+			switch t.text {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+			}
+		}
+		parts = append(parts, t.text)
+		p.next()
+	}
+	return strings.Join(parts, "")
+}
+
+func (p *rdlParser) skipStatement() {
+	depth := 0
+	for p.pos < len(p.toks) {
+		t := p.next()
+		if t.str {
+			continue
+		}
+		switch t.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *rdlParser) skipBalanced(open, close string) {
+	depth := 0
+	for p.pos < len(p.toks) {
+		t := p.next()
+		if t.str {
+			continue
+		}
+		switch t.text {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func isRDLIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func tokenizeSystemRDL(src string) ([]rdlToken, error) {
+	toks := make([]rdlToken, 0)
+	line := 1
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			start := line
+			i += 2
+			for ; i+1 < len(rs) && !(rs[i] == '*' && rs[i+1] == '/'); i++ {
+				if rs[i] == '\n' {
+					line++
+				}
+			}
+			if i+1 >= len(rs) {
+				return nil, errors.Errorf("line %d: unterminated comment", start)
+			}
+			i += 2
+		case r == '"':
+			start := line
+			var b strings.Builder
+			i++
+			for ; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				if rs[i] == '\n' {
+					line++
+				}
+				b.WriteRune(rs[i])
+			}
+			if i >= len(rs) {
+				return nil, errors.Errorf("line %d: unterminated string", start)
+			}
+			i++
+			toks = append(toks, rdlToken{text: b.String(), line: start, str: true})
+		case unicode.IsDigit(r) || r == '\'':
+			j := i + 1
+			for j < len(rs) && (rs[j] == '_' || rs[j] == '\'' || unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+			toks = append(toks, rdlToken{text: string(rs[i:j]), line: line})
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i + 1
+			for j < len(rs) && (rs[j] == '_' || unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+			toks = append(toks, rdlToken{text: string(rs[i:j]), line: line})
+			i = j
+		default:
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "+=", "%=", "->", "::":
+					toks = append(toks, rdlToken{text: two, line: line})
+					i += 2
+					continue
+				}
+			}
+			toks = append(toks, rdlToken{text: string(r), line: line})
+			i++
+		}
+	}
+	return toks, nil
+}
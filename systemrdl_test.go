@@ -0,0 +1,126 @@
+package packetdiagram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestImportSystemRDL(t *testing.T) {
+	t.Parallel()
+	src := `
+// a UART block
+addrmap uart {
+    default sw = rw;
+
+    field status_f { sw = r; };
+
+    reg ctrl_t {
+        field { desc = "enable"; } EN[0:0] = 1'b1;
+        field {} MODE[3:1] = 3'h2;
+        field { onwrite = woclr; } IRQ[8:8] = 0;
+    };
+
+    reg {
+        status_f BUSY;
+        status_f LEVEL[4];
+        field { rclr; } OVERRUN @ 31;
+    } STATUS @ 0x4;
+
+    regfile {
+        ctrl_t CTRL @ 0x0;
+    } chan[2] @ 0x100 += 0x10;
+};
+`
+	defs, err := ImportSystemRDL(strings.NewReader(src))
+	assert.NoError(t, err)
+
+	reset := func(v uint64) *uint64 { return &v }
+	access := func(a AccessType) *AccessType { return &a }
+	mode := ModeRegister
+	assert.Equal(t, []*Definition{
+		{
+			Name:          "STATUS",
+			Mode:          &mode,
+			OctetsPerLine: uintp(4),
+			XAxis:         XAxisSpec{Bits: &XAxisBitsSpec{}},
+			Placements: []Placement{
+				{Label: "OVERRUN", Bits: uintp(1), Access: access(AccessTypeRC)},
+				{Label: "Reserved", Bits: uintp(26)},
+				{Label: "LEVEL", Bits: uintp(4), Access: access(AccessTypeRO)},
+				{Label: "BUSY", Bits: uintp(1), Access: access(AccessTypeRO)},
+			},
+		},
+		{
+			Name:          "chan.CTRL",
+			Mode:          &mode,
+			OctetsPerLine: uintp(4),
+			XAxis:         XAxisSpec{Bits: &XAxisBitsSpec{}},
+			Placements: []Placement{
+				{Label: "Reserved", Bits: uintp(23)},
+				{Label: "IRQ", Bits: uintp(1), Access: access(AccessTypeW1C), Reset: reset(0)},
+				{Label: "Reserved", Bits: uintp(4)},
+				{Label: "MODE", Bits: uintp(3), Access: access(AccessTypeRW), Reset: reset(2)},
+				{Label: "EN", Bits: uintp(1), Access: access(AccessTypeRW), Reset: reset(1)},
+			},
+		},
+	}, defs)
+}
+
+func TestImportSystemRDLErrors(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "unknown type",
+			Source: "addrmap top { ctrl_t CTRL; };",
+			Error:  "unknown component type ctrl_t",
+		},
+		{
+			Name:   "overlapping fields",
+			Source: "addrmap top { reg { field {} A[7:0]; field {} B[3:0]; } R; };",
+			Error:  "overlaps",
+		},
+		{
+			Name:   "unterminated",
+			Source: "addrmap top { reg { field {} A[7:0];",
+			Error:  "expected `}`",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ImportSystemRDL(strings.NewReader(tt.Source))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.Error)
+		})
+	}
+}
+
+func TestParseHDLNumber(t *testing.T) {
+	testData := []struct {
+		Source   string
+		Expected uint64
+	}{
+		{Source: "42", Expected: 42},
+		{Source: "0x1F", Expected: 0x1f},
+		{Source: "32'h0000_001F", Expected: 0x1f},
+		{Source: "'b101", Expected: 5},
+		{Source: "8'd12", Expected: 12},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Source, func(t *testing.T) {
+			t.Parallel()
+			actual, err := parseHDLNumber(tt.Source)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.Expected, actual)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// IPv4 Header, generated by packet-diagram.
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct IPv4Header {
+    pub version: u8,
+    pub ihl: u8,
+    pub dscp: u8,
+    pub ecn: u8,
+    pub total_length: u16,
+    pub identification: u16,
+    pub flags: u8,
+    pub fragment_offset: u16,
+    pub time_to_live: u8,
+    pub protocol: u8,
+    pub header_checksum: u16,
+    pub source_address: u32,
+    pub destination_address: u32,
+    pub options: Vec<u8>,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Error {
+    /// The input is shorter than the fixed-size fields.
+    Truncated,
+    /// A variable-length field is longer than it may be.
+    TooLong(&'static str),
+    /// A variable-length field is shorter than it must be.
+    TooShort(&'static str),
+    /// A variable-length field is not as long as its length field says.
+    LengthMismatch(&'static str),
+    /// A field does not fit in its bits.
+    OutOfRange(&'static str),
+}
+
+impl std::fmt::Display for Error {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Error::Truncated => write!(f, "the input is too short"),
+            Error::TooLong(field) => write!(f, "{} is longer than it may be", field),
+            Error::TooShort(field) => write!(f, "{} is shorter than it must be", field),
+            Error::LengthMismatch(field) => write!(f, "{} is not as long as its length field says", field),
+            Error::OutOfRange(field) => write!(f, "{} does not fit in its bits", field),
+        }
+    }
+}
+
+impl std::error::Error for Error {}
+
+impl Default for IPv4Header {
+    fn default() -> Self {
+        IPv4Header {
+            version: 0,
+            ihl: 0,
+            dscp: 0,
+            ecn: 0,
+            total_length: 0,
+            identification: 0,
+            flags: 0,
+            fragment_offset: 0,
+            time_to_live: 0,
+            protocol: 0,
+            header_checksum: 0,
+            source_address: 0,
+            destination_address: 0,
+            options: Vec::new(),
+        }
+    }
+}
+
+impl IPv4Header {
+    /// The length of the fixed-size fields in bytes.
+    pub const MIN_LEN: usize = 20;
+    pub const ECN_NOT_ECT: u8 = 0;
+    pub const ECN_CE: u8 = 3;
+    pub const PROTOCOL_TCP: u8 = 6;
+    pub const PROTOCOL_UDP: u8 = 0x11;
+    pub const OPTIONS_MAX_LEN: usize = 40;
+
+    pub fn parse(buf: &[u8]) -> Result<Self, Error> {
+        if buf.len() < Self::MIN_LEN {
+            return Err(Error::Truncated);
+        }
+        let version = buf[0] >> 4;
+        let ihl = buf[0] & 0xf;
+        let dscp = buf[1] >> 2;
+        let ecn = buf[1] & 0x3;
+        let total_length = u16::from_be_bytes([buf[2], buf[3]]);
+        let identification = u16::from_be_bytes([buf[4], buf[5]]);
+        let flags = buf[6] >> 5;
+        let fragment_offset = (u16::from(buf[6]) << 8 | u16::from(buf[7])) & 0x1fff;
+        let time_to_live = buf[8];
+        let protocol = buf[9];
+        let header_checksum = u16::from_be_bytes([buf[10], buf[11]]);
+        let source_address = u32::from_be_bytes([buf[12], buf[13], buf[14], buf[15]]);
+        let destination_address = u32::from_be_bytes([buf[16], buf[17], buf[18], buf[19]]);
+        let pos = 20;
+        let options_len = buf.len() - pos;
+        if options_len > Self::OPTIONS_MAX_LEN {
+            return Err(Error::TooLong("options"));
+        }
+        let options = buf[pos..pos + options_len].to_vec();
+        Ok(IPv4Header {
+            version,
+            ihl,
+            dscp,
+            ecn,
+            total_length,
+            identification,
+            flags,
+            fragment_offset,
+            time_to_live,
+            protocol,
+            header_checksum,
+            source_address,
+            destination_address,
+            options,
+        })
+    }
+
+    /// Checks that the fields fit in their bits and variable-length fields
+    /// are as long as they may be and their length fields say, as write
+    /// assumes.
+    pub fn validate(&self) -> Result<(), Error> {
+        if self.version >> 4 != 0 {
+            return Err(Error::OutOfRange("version"));
+        }
+        if self.ihl >> 4 != 0 {
+            return Err(Error::OutOfRange("ihl"));
+        }
+        if self.dscp >> 6 != 0 {
+            return Err(Error::OutOfRange("dscp"));
+        }
+        if self.ecn >> 2 != 0 {
+            return Err(Error::OutOfRange("ecn"));
+        }
+        if self.flags >> 3 != 0 {
+            return Err(Error::OutOfRange("flags"));
+        }
+        if self.fragment_offset >> 13 != 0 {
+            return Err(Error::OutOfRange("fragment_offset"));
+        }
+        if self.options.len() > Self::OPTIONS_MAX_LEN {
+            return Err(Error::TooLong("options"));
+        }
+        Ok(())
+    }
+
+    /// Appends the packet to out. Bits of fields beyond their width are
+    /// dropped and variable-length fields are written whole; see validate.
+    pub fn write(&self, out: &mut Vec<u8>) {
+        let at = out.len();
+        out.resize(at + 20, 0);
+        out[at] |= (self.version & 0xf) << 4;
+        out[at] |= self.ihl & 0xf;
+        out[at + 1] |= (self.dscp & 0x3f) << 2;
+        out[at + 1] |= self.ecn & 0x3;
+        out[at + 2..at + 4].copy_from_slice(&self.total_length.to_be_bytes());
+        out[at + 4..at + 6].copy_from_slice(&self.identification.to_be_bytes());
+        out[at + 6] |= (self.flags & 0x7) << 5;
+        let v = self.fragment_offset & 0x1fff;
+        out[at + 6] |= (v >> 8) as u8;
+        out[at + 7] |= v as u8;
+        out[at + 8] = self.time_to_live;
+        out[at + 9] = self.protocol;
+        out[at + 10..at + 12].copy_from_slice(&self.header_checksum.to_be_bytes());
+        out[at + 12..at + 16].copy_from_slice(&self.source_address.to_be_bytes());
+        out[at + 16..at + 20].copy_from_slice(&self.destination_address.to_be_bytes());
+        out.extend_from_slice(&self.options);
+    }
+}
@@ -0,0 +1,112 @@
+// Length Field, generated by packet-diagram.
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct LengthField {
+    pub type_field: u8,
+    pub length: u8,
+    pub value: Vec<u8>,
+    pub checksum: u16,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Error {
+    /// The input is shorter than the fixed-size fields.
+    Truncated,
+    /// A variable-length field is longer than it may be.
+    TooLong(&'static str),
+    /// A variable-length field is shorter than it must be.
+    TooShort(&'static str),
+    /// A variable-length field is not as long as its length field says.
+    LengthMismatch(&'static str),
+    /// A field does not fit in its bits.
+    OutOfRange(&'static str),
+}
+
+impl std::fmt::Display for Error {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Error::Truncated => write!(f, "the input is too short"),
+            Error::TooLong(field) => write!(f, "{} is longer than it may be", field),
+            Error::TooShort(field) => write!(f, "{} is shorter than it must be", field),
+            Error::LengthMismatch(field) => write!(f, "{} is not as long as its length field says", field),
+            Error::OutOfRange(field) => write!(f, "{} does not fit in its bits", field),
+        }
+    }
+}
+
+impl std::error::Error for Error {}
+
+impl Default for LengthField {
+    fn default() -> Self {
+        LengthField {
+            type_field: 0,
+            length: 0,
+            value: Vec::new(),
+            checksum: 0,
+        }
+    }
+}
+
+impl LengthField {
+    /// The length of the fixed-size fields in bytes.
+    pub const MIN_LEN: usize = 4;
+    pub const VALUE_MIN_LEN: usize = 1;
+    pub const VALUE_MAX_LEN: usize = 255;
+
+    pub fn parse(buf: &[u8]) -> Result<Self, Error> {
+        if buf.len() < Self::MIN_LEN {
+            return Err(Error::Truncated);
+        }
+        let type_field = buf[0];
+        let length = buf[1];
+        let mut pos = 2;
+        let value_len = length as usize;
+        if value_len > Self::VALUE_MAX_LEN {
+            return Err(Error::TooLong("value"));
+        }
+        if value_len < Self::VALUE_MIN_LEN {
+            return Err(Error::TooShort("value"));
+        }
+        if buf.len() < pos + value_len + 2 {
+            return Err(Error::Truncated);
+        }
+        let value = buf[pos..pos + value_len].to_vec();
+        pos += value_len;
+        let checksum = u16::from_be_bytes([buf[pos], buf[pos + 1]]);
+        Ok(LengthField {
+            type_field,
+            length,
+            value,
+            checksum,
+        })
+    }
+
+    /// Checks that the fields fit in their bits and variable-length fields
+    /// are as long as they may be and their length fields say, as write
+    /// assumes.
+    pub fn validate(&self) -> Result<(), Error> {
+        if self.value.len() > Self::VALUE_MAX_LEN {
+            return Err(Error::TooLong("value"));
+        }
+        if self.value.len() < Self::VALUE_MIN_LEN {
+            return Err(Error::TooShort("value"));
+        }
+        if self.length as usize != self.value.len() {
+            return Err(Error::LengthMismatch("value"));
+        }
+        Ok(())
+    }
+
+    /// Appends the packet to out. Bits of fields beyond their width are
+    /// dropped and variable-length fields are written whole; see validate.
+    pub fn write(&self, out: &mut Vec<u8>) {
+        let at = out.len();
+        out.resize(at + 2, 0);
+        out[at] = self.type_field;
+        out[at + 1] = self.length;
+        out.extend_from_slice(&self.value);
+        let at = out.len();
+        out.resize(at + 2, 0);
+        out[at..at + 2].copy_from_slice(&self.checksum.to_be_bytes());
+    }
+}
@@ -0,0 +1,185 @@
+// TLV Frame, generated by packet-diagram.
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct TLVFrame {
+    pub flag: u8,
+    pub kind: u8,
+    pub type_field: u16,
+    pub sequence: u64,
+    pub wide: u64,
+    pub pad: u8,
+    pub address: [u8; 16],
+    pub value: Vec<u8>,
+    pub check: u16,
+    pub marker: u8,
+    pub trailer: Vec<u8>,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Error {
+    /// The input is shorter than the fixed-size fields.
+    Truncated,
+    /// A variable-length field is longer than it may be.
+    TooLong(&'static str),
+    /// A variable-length field is shorter than it must be.
+    TooShort(&'static str),
+    /// A variable-length field is not as long as its length field says.
+    LengthMismatch(&'static str),
+    /// A field does not fit in its bits.
+    OutOfRange(&'static str),
+}
+
+impl std::fmt::Display for Error {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Error::Truncated => write!(f, "the input is too short"),
+            Error::TooLong(field) => write!(f, "{} is longer than it may be", field),
+            Error::TooShort(field) => write!(f, "{} is shorter than it must be", field),
+            Error::LengthMismatch(field) => write!(f, "{} is not as long as its length field says", field),
+            Error::OutOfRange(field) => write!(f, "{} does not fit in its bits", field),
+        }
+    }
+}
+
+impl std::error::Error for Error {}
+
+impl Default for TLVFrame {
+    fn default() -> Self {
+        TLVFrame {
+            flag: 1,
+            kind: 0,
+            type_field: 0x800,
+            sequence: 0,
+            wide: 0,
+            pad: 0,
+            address: [0; 16],
+            value: Vec::new(),
+            check: 0,
+            marker: 0,
+            trailer: Vec::new(),
+        }
+    }
+}
+
+impl TLVFrame {
+    /// The length of the fixed-size fields in bytes.
+    pub const MIN_LEN: usize = 34;
+    pub const KIND_DATA: u8 = 1;
+    pub const KIND_ACK: u8 = 2;
+    pub const VALUE_MAX_LEN: usize = 8;
+    pub const TRAILER_MAX_LEN: usize = 4;
+
+    pub fn parse(buf: &[u8]) -> Result<Self, Error> {
+        if buf.len() < Self::MIN_LEN {
+            return Err(Error::Truncated);
+        }
+        let flag = buf[0] >> 7;
+        let kind = buf[0] & 0x7f;
+        let type_field = u16::from_be_bytes([buf[1], buf[2]]);
+        let sequence = ((u64::from(buf[3]) << 32 | u64::from(buf[4]) << 24 | u64::from(buf[5]) << 16 | u64::from(buf[6]) << 8 | u64::from(buf[7])) >> 1) & 0x7fffffffff;
+        let wide = (((u128::from(buf[7]) << 64 | u128::from(buf[8]) << 56 | u128::from(buf[9]) << 48 | u128::from(buf[10]) << 40 | u128::from(buf[11]) << 32 | u128::from(buf[12]) << 24 | u128::from(buf[13]) << 16 | u128::from(buf[14]) << 8 | u128::from(buf[15])) >> 5) & 0xfffffffffffffff) as u64;
+        let pad = buf[15] & 0x1f;
+        let mut address = [0; 16];
+        address.copy_from_slice(&buf[16..32]);
+        let mut pos = 32;
+        // what the fields after value leave of the input; change this to read
+        // its length from the input instead
+        let value_len = buf.len().saturating_sub(pos + 2);
+        if value_len > Self::VALUE_MAX_LEN {
+            return Err(Error::TooLong("value"));
+        }
+        let value = buf[pos..pos + value_len].to_vec();
+        pos += value_len;
+        let check = (u16::from(buf[pos]) << 8 | u16::from(buf[pos + 1])) >> 4;
+        let marker = buf[pos + 1] & 0xf;
+        pos += 2;
+        let trailer_len = buf.len() - pos;
+        if trailer_len > Self::TRAILER_MAX_LEN {
+            return Err(Error::TooLong("trailer"));
+        }
+        let trailer = buf[pos..pos + trailer_len].to_vec();
+        Ok(TLVFrame {
+            flag,
+            kind,
+            type_field,
+            sequence,
+            wide,
+            pad,
+            address,
+            value,
+            check,
+            marker,
+            trailer,
+        })
+    }
+
+    /// Checks that the fields fit in their bits and variable-length fields
+    /// are as long as they may be and their length fields say, as write
+    /// assumes.
+    pub fn validate(&self) -> Result<(), Error> {
+        if self.flag >> 1 != 0 {
+            return Err(Error::OutOfRange("flag"));
+        }
+        if self.kind >> 7 != 0 {
+            return Err(Error::OutOfRange("kind"));
+        }
+        if self.sequence >> 39 != 0 {
+            return Err(Error::OutOfRange("sequence"));
+        }
+        if self.wide >> 60 != 0 {
+            return Err(Error::OutOfRange("wide"));
+        }
+        if self.pad >> 5 != 0 {
+            return Err(Error::OutOfRange("pad"));
+        }
+        if self.value.len() > Self::VALUE_MAX_LEN {
+            return Err(Error::TooLong("value"));
+        }
+        if self.check >> 12 != 0 {
+            return Err(Error::OutOfRange("check"));
+        }
+        if self.marker >> 4 != 0 {
+            return Err(Error::OutOfRange("marker"));
+        }
+        if self.trailer.len() > Self::TRAILER_MAX_LEN {
+            return Err(Error::TooLong("trailer"));
+        }
+        Ok(())
+    }
+
+    /// Appends the packet to out. Bits of fields beyond their width are
+    /// dropped and variable-length fields are written whole; see validate.
+    pub fn write(&self, out: &mut Vec<u8>) {
+        let at = out.len();
+        out.resize(at + 32, 0);
+        out[at] |= (self.flag & 0x1) << 7;
+        out[at] |= self.kind & 0x7f;
+        out[at + 1..at + 3].copy_from_slice(&self.type_field.to_be_bytes());
+        let v = (self.sequence & 0x7fffffffff) << 1;
+        out[at + 3] |= (v >> 32) as u8;
+        out[at + 4] |= (v >> 24) as u8;
+        out[at + 5] |= (v >> 16) as u8;
+        out[at + 6] |= (v >> 8) as u8;
+        out[at + 7] |= v as u8;
+        let v = (u128::from(self.wide) & 0xfffffffffffffff) << 5;
+        out[at + 7] |= (v >> 64) as u8;
+        out[at + 8] |= (v >> 56) as u8;
+        out[at + 9] |= (v >> 48) as u8;
+        out[at + 10] |= (v >> 40) as u8;
+        out[at + 11] |= (v >> 32) as u8;
+        out[at + 12] |= (v >> 24) as u8;
+        out[at + 13] |= (v >> 16) as u8;
+        out[at + 14] |= (v >> 8) as u8;
+        out[at + 15] |= v as u8;
+        out[at + 15] |= self.pad & 0x1f;
+        out[at + 16..at + 32].copy_from_slice(&self.address);
+        out.extend_from_slice(&self.value);
+        let at = out.len();
+        out.resize(at + 2, 0);
+        let v = (self.check & 0xfff) << 4;
+        out[at] |= (v >> 8) as u8;
+        out[at + 1] |= v as u8;
+        out[at + 1] |= self.marker & 0xf;
+        out.extend_from_slice(&self.trailer);
+    }
+}
@@ -0,0 +1,162 @@
+// USB Setup, generated by packet-diagram.
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct USBSetup {
+    pub recipient: u8,
+    pub type_field: u8,
+    pub direction: u8,
+    pub b_request: u8,
+    pub w_value: u16,
+    pub w_index: u16,
+    pub w_length: u16,
+    pub signal: u16,
+    pub flags: u8,
+    pub wide: u32,
+    pub magic: u32,
+    pub data: Vec<u8>,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Error {
+    /// The input is shorter than the fixed-size fields.
+    Truncated,
+    /// A variable-length field is longer than it may be.
+    TooLong(&'static str),
+    /// A variable-length field is shorter than it must be.
+    TooShort(&'static str),
+    /// A variable-length field is not as long as its length field says.
+    LengthMismatch(&'static str),
+    /// A field does not fit in its bits.
+    OutOfRange(&'static str),
+}
+
+impl std::fmt::Display for Error {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Error::Truncated => write!(f, "the input is too short"),
+            Error::TooLong(field) => write!(f, "{} is longer than it may be", field),
+            Error::TooShort(field) => write!(f, "{} is shorter than it must be", field),
+            Error::LengthMismatch(field) => write!(f, "{} is not as long as its length field says", field),
+            Error::OutOfRange(field) => write!(f, "{} does not fit in its bits", field),
+        }
+    }
+}
+
+impl std::error::Error for Error {}
+
+impl Default for USBSetup {
+    fn default() -> Self {
+        USBSetup {
+            recipient: 0,
+            type_field: 0,
+            direction: 0,
+            b_request: 0,
+            w_value: 0,
+            w_index: 0,
+            w_length: 0,
+            signal: 0,
+            flags: 0,
+            wide: 0,
+            magic: 0,
+            data: Vec::new(),
+        }
+    }
+}
+
+impl USBSetup {
+    /// The length of the fixed-size fields in bytes.
+    pub const MIN_LEN: usize = 17;
+    pub const TYPE_FIELD_STANDARD: u8 = 0;
+    pub const TYPE_FIELD_CLASS: u8 = 1;
+    pub const TYPE_FIELD_VENDOR: u8 = 2;
+    pub const DATA_MAX_LEN: usize = 64;
+
+    pub fn parse(buf: &[u8]) -> Result<Self, Error> {
+        if buf.len() < Self::MIN_LEN {
+            return Err(Error::Truncated);
+        }
+        let recipient = buf[0] & 0x1f;
+        let type_field = (buf[0] >> 5) & 0x3;
+        let direction = buf[0] >> 7;
+        let b_request = buf[1];
+        let w_value = u16::from_le_bytes([buf[2], buf[3]]);
+        let w_index = u16::from_le_bytes([buf[4], buf[5]]);
+        let w_length = u16::from_le_bytes([buf[6], buf[7]]);
+        let signal = (u16::from(buf[8]) | u16::from(buf[9]) << 8) & 0xfff;
+        let flags = buf[9] >> 4;
+        let wide = (u32::from(buf[10]) | u32::from(buf[11]) << 8 | u32::from(buf[12]) << 16) & 0xffffff;
+        let magic = u32::from_be_bytes([buf[13], buf[14], buf[15], buf[16]]);
+        let pos = 17;
+        let data_len = buf.len() - pos;
+        if data_len > Self::DATA_MAX_LEN {
+            return Err(Error::TooLong("data"));
+        }
+        let data = buf[pos..pos + data_len].to_vec();
+        Ok(USBSetup {
+            recipient,
+            type_field,
+            direction,
+            b_request,
+            w_value,
+            w_index,
+            w_length,
+            signal,
+            flags,
+            wide,
+            magic,
+            data,
+        })
+    }
+
+    /// Checks that the fields fit in their bits and variable-length fields
+    /// are as long as they may be and their length fields say, as write
+    /// assumes.
+    pub fn validate(&self) -> Result<(), Error> {
+        if self.recipient >> 5 != 0 {
+            return Err(Error::OutOfRange("recipient"));
+        }
+        if self.type_field >> 2 != 0 {
+            return Err(Error::OutOfRange("type_field"));
+        }
+        if self.direction >> 1 != 0 {
+            return Err(Error::OutOfRange("direction"));
+        }
+        if self.signal >> 12 != 0 {
+            return Err(Error::OutOfRange("signal"));
+        }
+        if self.flags >> 4 != 0 {
+            return Err(Error::OutOfRange("flags"));
+        }
+        if self.wide >> 24 != 0 {
+            return Err(Error::OutOfRange("wide"));
+        }
+        if self.data.len() > Self::DATA_MAX_LEN {
+            return Err(Error::TooLong("data"));
+        }
+        Ok(())
+    }
+
+    /// Appends the packet to out. Bits of fields beyond their width are
+    /// dropped and variable-length fields are written whole; see validate.
+    pub fn write(&self, out: &mut Vec<u8>) {
+        let at = out.len();
+        out.resize(at + 17, 0);
+        out[at] |= self.recipient & 0x1f;
+        out[at] |= (self.type_field & 0x3) << 5;
+        out[at] |= (self.direction & 0x1) << 7;
+        out[at + 1] = self.b_request;
+        out[at + 2..at + 4].copy_from_slice(&self.w_value.to_le_bytes());
+        out[at + 4..at + 6].copy_from_slice(&self.w_index.to_le_bytes());
+        out[at + 6..at + 8].copy_from_slice(&self.w_length.to_le_bytes());
+        let v = self.signal & 0xfff;
+        out[at + 8] |= v as u8;
+        out[at + 9] |= (v >> 8) as u8;
+        out[at + 9] |= (self.flags & 0xf) << 4;
+        let v = self.wide & 0xffffff;
+        out[at + 10] |= v as u8;
+        out[at + 11] |= (v >> 8) as u8;
+        out[at + 12] |= (v >> 16) as u8;
+        out[at + 13..at + 17].copy_from_slice(&self.magic.to_be_bytes());
+        out.extend_from_slice(&self.data);
+    }
+}
@@ -0,0 +1,24 @@
+# Scapy layer for IPv4 Header, generated by packet-diagram.
+
+from scapy.fields import BitEnumField, BitField, ByteEnumField, ByteField, IntField, ShortField, StrField
+from scapy.packet import Packet
+
+
+class IPv4Header(Packet):
+    name = "IPv4 Header"
+    fields_desc = [
+        BitField("version", 0, 4),
+        BitField("ihl", 0, 4),
+        BitField("dscp", 0, 6),
+        BitEnumField("ecn", 0, 2, {0: "Not-ECT", 3: "CE"}),
+        ShortField("total_length", 0),
+        ShortField("identification", 0),
+        BitField("flags", 0, 3),
+        BitField("fragment_offset", 0, 13),
+        ByteField("time_to_live", 0),
+        ByteEnumField("protocol", 0, {6: "TCP", 17: "UDP"}),
+        ShortField("header_checksum", 0),
+        IntField("source_address", 0),
+        IntField("destination_address", 0),
+        StrField("options", b""),
+    ]
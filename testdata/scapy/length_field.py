@@ -0,0 +1,14 @@
+# Scapy layer for Length Field, generated by packet-diagram.
+
+from scapy.fields import ByteField, ShortField, StrLenField
+from scapy.packet import Packet
+
+
+class LengthField(Packet):
+    name = "Length Field"
+    fields_desc = [
+        ByteField("type", 0),
+        ByteField("length", 0),
+        StrLenField("value", b"", length_from=lambda pkt: pkt.length, max_length=255),
+        ShortField("checksum", 0),
+    ]
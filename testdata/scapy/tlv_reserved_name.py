@@ -0,0 +1,17 @@
+# Scapy layer for TLV Frame, generated by packet-diagram.
+
+from scapy.fields import BitEnumField, BitField, LongField, ShortField, StrField, StrFixedLenField, StrLenField
+from scapy.packet import Packet
+
+
+class TLVFrame(Packet):
+    name = "TLV Frame"
+    fields_desc = [
+        BitField("flag", 1, 1),
+        BitEnumField("kind", 0, 7, {1: "Data", 2: "Ack"}),
+        ShortField("payload_field", 2048),
+        LongField("sequence", 0),
+        StrFixedLenField("address", b"", length=16),
+        StrLenField("value", b"", length_from=lambda pkt: 0, max_length=8),  # give the length in bytes
+        StrField("trailer", b""),
+    ]
@@ -0,0 +1,22 @@
+# Scapy layer for USB Setup, generated by packet-diagram.
+
+from scapy.fields import ByteField, IntField, LEBitEnumField, LEBitField, LEShortField, StrField
+from scapy.packet import Packet
+
+
+class USBSetup(Packet):
+    name = "USB Setup"
+    fields_desc = [
+        LEBitField("recipient", 0, 5),
+        LEBitEnumField("type", 0, 2, {0: "Standard", 1: "Class", 2: "Vendor"}),
+        LEBitField("direction_field", 0, 1),
+        ByteField("b_request", 0),
+        LEShortField("w_value", 0),
+        LEShortField("w_index", 0),
+        LEShortField("w_length", 0),
+        LEBitField("signal", 0, 12),
+        LEBitField("flags", 0, 4),
+        LEBitField("wide", 0, 24),
+        IntField("magic", 0),
+        StrField("data", b""),
+    ]
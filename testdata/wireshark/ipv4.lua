@@ -0,0 +1,70 @@
+-- Wireshark dissector for IPv4 Header, generated by packet-diagram.
+
+local proto = Proto("ipv4_header", "IPv4 Header")
+
+local ecn_values = {
+    [0] = "Not-ECT",
+    [3] = "CE",
+}
+
+local protocol_values = {
+    [6] = "TCP",
+    [17] = "UDP",
+}
+
+local f = proto.fields
+f.version = ProtoField.uint8("ipv4_header.version", "Version", base.DEC, nil, 0xF0)
+f.ihl = ProtoField.uint8("ipv4_header.ihl", "IHL", base.DEC, nil, 0x0F)
+f.dscp = ProtoField.uint8("ipv4_header.dscp", "DSCP", base.DEC, nil, 0xFC)
+f.ecn = ProtoField.uint8("ipv4_header.ecn", "ECN", base.DEC, ecn_values, 0x03)
+f.total_length = ProtoField.uint16("ipv4_header.total_length", "Total Length", base.DEC, nil)
+f.identification = ProtoField.uint16("ipv4_header.identification", "Identification", base.DEC, nil)
+f.flags = ProtoField.uint8("ipv4_header.flags", "Flags", base.DEC, nil, 0xE0)
+f.fragment_offset = ProtoField.uint16("ipv4_header.fragment_offset", "Fragment Offset", base.DEC, nil, 0x1FFF)
+f.time_to_live = ProtoField.uint8("ipv4_header.time_to_live", "Time To Live", base.DEC, nil)
+f.protocol = ProtoField.uint8("ipv4_header.protocol", "Protocol", base.DEC, protocol_values)
+f.header_checksum = ProtoField.uint16("ipv4_header.header_checksum", "Header Checksum", base.DEC, nil)
+f.source_address = ProtoField.uint32("ipv4_header.source_address", "Source Address", base.DEC, nil)
+f.destination_address = ProtoField.uint32("ipv4_header.destination_address", "Destination Address", base.DEC, nil)
+f.options = ProtoField.bytes("ipv4_header.options", "Options")
+
+function proto.dissector(buffer, pinfo, tree)
+    if buffer:len() < 20 then
+        return 0
+    end
+    pinfo.cols.protocol = proto.name
+    local subtree = tree:add(proto, buffer())
+    subtree:add(f.version, buffer(0, 1))
+    subtree:add(f.ihl, buffer(0, 1))
+    subtree:add(f.dscp, buffer(1, 1))
+    subtree:add(f.ecn, buffer(1, 1))
+    subtree:add(f.total_length, buffer(2, 2))
+    subtree:add(f.identification, buffer(4, 2))
+    subtree:add(f.flags, buffer(6, 1))
+    subtree:add(f.fragment_offset, buffer(6, 2))
+    subtree:add(f.time_to_live, buffer(8, 1))
+    subtree:add(f.protocol, buffer(9, 1))
+    subtree:add(f.header_checksum, buffer(10, 2))
+    subtree:add(f.source_address, buffer(12, 4))
+    subtree:add(f.destination_address, buffer(16, 4))
+    if buffer:len() > 20 then
+        subtree:add(f.options, buffer(20))
+    end
+    return buffer:len()
+end
+
+-- Pick the protocol in "Decode As..." for the ports it runs on, or list
+-- them here, e.g. DissectorTable.get("udp.port"):add(9000, proto).
+DissectorTable.get("udp.port"):add_for_decode_as(proto)
+DissectorTable.get("tcp.port"):add_for_decode_as(proto)
+
+-- To find the protocol on any port, make this check what sets its packets
+-- apart and uncomment the registration.
+local function heuristic(buffer, pinfo, tree)
+    if buffer:len() < 20 then
+        return false
+    end
+    proto.dissector(buffer, pinfo, tree)
+    return true
+end
+-- proto:register_heuristic("udp", heuristic)
@@ -0,0 +1,44 @@
+-- Wireshark dissector for Length Field, generated by packet-diagram.
+
+local proto = Proto("length_field", "Length Field")
+
+local f = proto.fields
+f.type = ProtoField.uint8("length_field.type", "Type", base.DEC, nil)
+f.length = ProtoField.uint8("length_field.length", "Length", base.DEC, nil)
+f.value = ProtoField.bytes("length_field.value", "Value")
+f.checksum = ProtoField.uint16("length_field.checksum", "Checksum", base.DEC, nil)
+
+function proto.dissector(buffer, pinfo, tree)
+    if buffer:len() < 4 then
+        return 0
+    end
+    pinfo.cols.protocol = proto.name
+    local subtree = tree:add(proto, buffer())
+    subtree:add(f.type, buffer(0, 1))
+    subtree:add(f.length, buffer(1, 1))
+    local length_value = buffer(1, 1):uint()
+    local value_len = length_value
+    if value_len > 255 or value_len < 1 or buffer:len() < 2 + value_len + 2 then
+        return 0
+    end
+    subtree:add(f.value, buffer(2, value_len))
+    local offset = 2 + value_len
+    subtree:add(f.checksum, buffer(offset, 2))
+    return buffer:len()
+end
+
+-- Pick the protocol in "Decode As..." for the ports it runs on, or list
+-- them here, e.g. DissectorTable.get("udp.port"):add(9000, proto).
+DissectorTable.get("udp.port"):add_for_decode_as(proto)
+DissectorTable.get("tcp.port"):add_for_decode_as(proto)
+
+-- To find the protocol on any port, make this check what sets its packets
+-- apart and uncomment the registration.
+local function heuristic(buffer, pinfo, tree)
+    if buffer:len() < 4 then
+        return false
+    end
+    proto.dissector(buffer, pinfo, tree)
+    return true
+end
+-- proto:register_heuristic("udp", heuristic)
@@ -0,0 +1,54 @@
+-- Wireshark dissector for Lua Keywords, generated by packet-diagram.
+
+local proto = Proto("lua_keywords", "Lua Keywords")
+
+local local_field_values = {
+    [1] = "Near",
+}
+
+local f = proto.fields
+f.end_field = ProtoField.uint8("lua_keywords.end_field", "End", base.DEC, nil)
+f.function_field = ProtoField.uint8("lua_keywords.function_field", "Function", base.DEC, nil, 0xF0)
+f.local_field = ProtoField.uint8("lua_keywords.local_field", "Local", base.DEC, local_field_values, 0x0F)
+f.until_field = ProtoField.uint8("lua_keywords.until_field", "Until", base.DEC, nil)
+f.repeat_field = ProtoField.bytes("lua_keywords.repeat_field", "Repeat")
+f.then_field = ProtoField.bytes("lua_keywords.then_field", "Then")
+
+function proto.dissector(buffer, pinfo, tree)
+    if buffer:len() < 3 then
+        return 0
+    end
+    pinfo.cols.protocol = proto.name
+    local subtree = tree:add(proto, buffer())
+    subtree:add(f.end_field, buffer(0, 1))
+    subtree:add(f.function_field, buffer(1, 1))
+    subtree:add(f.local_field, buffer(1, 1))
+    subtree:add(f.until_field, buffer(2, 1))
+    local until_field_value = buffer(2, 1):uint()
+    local repeat_field_len = until_field_value
+    if repeat_field_len > 8 or buffer:len() < 3 + repeat_field_len then
+        return 0
+    end
+    subtree:add(f.repeat_field, buffer(3, repeat_field_len))
+    local offset = 3 + repeat_field_len
+    if buffer:len() > offset then
+        subtree:add(f.then_field, buffer(offset))
+    end
+    return buffer:len()
+end
+
+-- Pick the protocol in "Decode As..." for the ports it runs on, or list
+-- them here, e.g. DissectorTable.get("udp.port"):add(9000, proto).
+DissectorTable.get("udp.port"):add_for_decode_as(proto)
+DissectorTable.get("tcp.port"):add_for_decode_as(proto)
+
+-- To find the protocol on any port, make this check what sets its packets
+-- apart and uncomment the registration.
+local function heuristic(buffer, pinfo, tree)
+    if buffer:len() < 3 then
+        return false
+    end
+    proto.dissector(buffer, pinfo, tree)
+    return true
+end
+-- proto:register_heuristic("udp", heuristic)
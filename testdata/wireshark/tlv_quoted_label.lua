@@ -0,0 +1,50 @@
+-- Wireshark dissector for TLV Frame, generated by packet-diagram.
+
+local proto = Proto("tlv_frame", "TLV Frame")
+
+local f = proto.fields
+f.flag = ProtoField.uint8("tlv_frame.flag", "Flag", base.DEC, nil, 0x80)
+f.sequence = ProtoField.uint64("tlv_frame.sequence", "Sequence", base.DEC, nil, UInt64.fromhex("7FFFFFFFFF"))
+f.type = ProtoField.uint8("tlv_frame.type", "Type", base.DEC, nil)
+f.value = ProtoField.bytes("tlv_frame.value", "Value")
+f.tag = ProtoField.bytes("tlv_frame.tag", "Tag")
+f.check_sum = ProtoField.uint24("tlv_frame.check_sum", "Check \"sum\"", base.DEC, nil)
+
+-- The length of Value in bytes. It is taken to be what the fields after it
+-- leave of the packet; change this to read it from the packet instead.
+local function value_length(buffer, offset)
+    return math.max(buffer:len() - offset - 15, 0)
+end
+
+function proto.dissector(buffer, pinfo, tree)
+    if buffer:len() < 21 then
+        return 0
+    end
+    pinfo.cols.protocol = proto.name
+    local subtree = tree:add(proto, buffer())
+    subtree:add(f.flag, buffer(0, 1))
+    subtree:add(f.sequence, buffer(0, 5))
+    subtree:add(f.type, buffer(5, 1))
+    local value_len = value_length(buffer, 6)
+    subtree:add(f.value, buffer(6, value_len))
+    local offset = 6 + value_len
+    subtree:add(f.tag, buffer(offset, 12))
+    subtree:add(f.check_sum, buffer(offset + 12, 3))
+    return buffer:len()
+end
+
+-- Pick the protocol in "Decode As..." for the ports it runs on, or list
+-- them here, e.g. DissectorTable.get("udp.port"):add(9000, proto).
+DissectorTable.get("udp.port"):add_for_decode_as(proto)
+DissectorTable.get("tcp.port"):add_for_decode_as(proto)
+
+-- To find the protocol on any port, make this check what sets its packets
+-- apart and uncomment the registration.
+local function heuristic(buffer, pinfo, tree)
+    if buffer:len() < 21 then
+        return false
+    end
+    proto.dissector(buffer, pinfo, tree)
+    return true
+end
+-- proto:register_heuristic("udp", heuristic)
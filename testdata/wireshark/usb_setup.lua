@@ -0,0 +1,62 @@
+-- Wireshark dissector for USB Setup, generated by packet-diagram.
+
+local proto = Proto("usb_setup", "USB Setup")
+
+local type_values = {
+    [0] = "Standard",
+    [1] = "Class",
+    [2] = "Vendor",
+}
+
+local f = proto.fields
+f.recipient = ProtoField.uint8("usb_setup.recipient", "Recipient", base.DEC, nil, 0x1F)
+f.type = ProtoField.uint8("usb_setup.type", "Type", base.DEC, type_values, 0x60)
+f.direction = ProtoField.uint8("usb_setup.direction", "Direction", base.DEC, nil, 0x80)
+f.b_request = ProtoField.uint8("usb_setup.b_request", "bRequest", base.DEC, nil)
+f.w_value = ProtoField.uint16("usb_setup.w_value", "wValue", base.DEC, nil)
+f.w_index = ProtoField.uint16("usb_setup.w_index", "wIndex", base.DEC, nil)
+f.w_length = ProtoField.uint16("usb_setup.w_length", "wLength", base.DEC, nil)
+f.signal = ProtoField.uint16("usb_setup.signal", "Signal", base.DEC, nil, 0x0FFF)
+f.flags = ProtoField.uint8("usb_setup.flags", "Flags", base.DEC, nil, 0xF0)
+f.wide = ProtoField.uint24("usb_setup.wide", "Wide", base.DEC, nil)
+f.magic = ProtoField.uint32("usb_setup.magic", "Magic", base.DEC, nil)
+f.data = ProtoField.bytes("usb_setup.data", "Data")
+
+function proto.dissector(buffer, pinfo, tree)
+    if buffer:len() < 17 then
+        return 0
+    end
+    pinfo.cols.protocol = proto.name
+    local subtree = tree:add(proto, buffer())
+    subtree:add_le(f.recipient, buffer(0, 1))
+    subtree:add_le(f.type, buffer(0, 1))
+    subtree:add_le(f.direction, buffer(0, 1))
+    subtree:add_le(f.b_request, buffer(1, 1))
+    subtree:add_le(f.w_value, buffer(2, 2))
+    subtree:add_le(f.w_index, buffer(4, 2))
+    subtree:add_le(f.w_length, buffer(6, 2))
+    subtree:add_le(f.signal, buffer(8, 2))
+    subtree:add_le(f.flags, buffer(9, 1))
+    subtree:add_le(f.wide, buffer(10, 3))
+    subtree:add(f.magic, buffer(13, 4))
+    if buffer:len() > 17 then
+        subtree:add(f.data, buffer(17))
+    end
+    return buffer:len()
+end
+
+-- Pick the protocol in "Decode As..." for the ports it runs on, or list
+-- them here, e.g. DissectorTable.get("udp.port"):add(9000, proto).
+DissectorTable.get("udp.port"):add_for_decode_as(proto)
+DissectorTable.get("tcp.port"):add_for_decode_as(proto)
+
+-- To find the protocol on any port, make this check what sets its packets
+-- apart and uncomment the registration.
+local function heuristic(buffer, pinfo, tree)
+    if buffer:len() < 17 then
+        return false
+    end
+    proto.dissector(buffer, pinfo, tree)
+    return true
+end
+-- proto:register_heuristic("udp", heuristic)
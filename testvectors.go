@@ -0,0 +1,195 @@
+package packetdiagram
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+
+	"github.com/pkg/errors"
+)
+
+// TestVectors are field assignments of a definition with the bytes they
+// encode to, for checking code generated from it.
+type TestVectors struct {
+	Definition string       `json:"definition"`
+	Vectors    []TestVector `json:"vectors"`
+}
+
+type TestVector struct {
+	Name   string            `json:"name"`
+	Fields []TestVectorField `json:"fields"`
+	// Bits is the length of the encoding, whose last byte is padded with
+	// zeros when it is not a whole number of bytes.
+	Bits uint   `json:"bits"`
+	Hex  string `json:"hex"`
+}
+
+// TestVectorField is the value of a field, given as a number when it has up
+// to 64 bits and always as big-endian hex of the bytes holding it.
+type TestVectorField struct {
+	ID    string  `json:"id"`
+	Label string  `json:"label"`
+	Bits  uint    `json:"bits"`
+	Value *uint64 `json:"value,omitempty"`
+	Hex   string  `json:"hex"`
+}
+
+// GenerateTestVectors makes vectors named "zeros" with every bit clear,
+// "one" with every field of value 1, "ones" with every bit set, "max" with
+// every bit set and variable-length fields as long as they may be, and then
+// count random ones, drawn from seed so that they are the same every time.
+// Variable-length fields are otherwise as short as they may be, at least a
+// byte for "one" and "ones", or of random length, and their length fields
+// give their length in bytes.
+func GenerateTestVectors(def *Definition, count int, seed int64) (*TestVectors, error) {
+	for _, p := range def.Placements {
+		if p.Bits == nil && p.VariableLength == nil {
+			return nil, errors.Errorf("placement %s has no size", p.GetKey())
+		}
+	}
+
+	vectors := &TestVectors{Definition: def.Name, Vectors: make([]TestVector, 0, count+4)}
+	vectors.Vectors = append(vectors.Vectors,
+		newTestVector(def, "zeros", func(p Placement, bits uint) []byte {
+			if p.VariableLength != nil {
+				bits = getMinTestVectorBits(p)
+			}
+			return make([]byte, (bits+7)/8)
+		}),
+		newTestVector(def, "one", func(p Placement, bits uint) []byte {
+			if p.VariableLength != nil {
+				bits = getShortTestVectorBits(p)
+			}
+			v := make([]byte, (bits+7)/8)
+			if len(v) > 0 {
+				v[len(v)-1] = 1
+			}
+			return v
+		}),
+		newTestVector(def, "ones", func(p Placement, bits uint) []byte {
+			if p.VariableLength != nil {
+				bits = getShortTestVectorBits(p)
+			}
+			return maskBits(bytesOf(0xff, (bits+7)/8), bits)
+		}),
+		newTestVector(def, "max", func(p Placement, bits uint) []byte {
+			if p.VariableLength != nil {
+				bits = getMaxTestVectorBits(p)
+			}
+			return maskBits(bytesOf(0xff, (bits+7)/8), bits)
+		}),
+	)
+
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < count; i++ {
+		vectors.Vectors = append(vectors.Vectors, newTestVector(def, fmt.Sprintf("random-%d", i+1), func(p Placement, bits uint) []byte {
+			if p.VariableLength != nil {
+				min, max := getMinTestVectorBits(p)/8, getMaxTestVectorBits(p)/8
+				bits = (min + uint(r.Intn(int(max-min)+1))) * 8
+			}
+			v := make([]byte, (bits+7)/8)
+			r.Read(v)
+			return maskBits(v, bits)
+		}))
+	}
+	return vectors, nil
+}
+
+// newTestVector assigns each field the value returned for it, given the
+// width of fixed-size fields or 0 for variable-length ones, whose values
+// are the bytes they hold. Length fields are then given the length of theirs.
+func newTestVector(def *Definition, name string, value func(p Placement, bits uint) []byte) TestVector {
+	ids := identifierSet{}
+	orders := def.getFieldOrders()
+	v := TestVector{Name: name, Fields: make([]TestVectorField, 0, len(def.Placements))}
+	values := make([][]byte, len(def.Placements))
+	for i, p := range def.Placements {
+		bits := uint(0)
+		if p.Bits != nil {
+			bits = *p.Bits
+		}
+		values[i] = value(p, bits)
+	}
+	for i, p := range def.Placements {
+		if j := def.getLengthField(p); j >= 0 {
+			n := *def.Placements[j].Bits
+			values[j] = maskBits(bigEndianBytes(uint64(len(values[i])), (n+7)/8), n)
+		}
+	}
+
+	var w bitWriter
+	for i, p := range def.Placements {
+		b := values[i]
+		bits := uint(len(b)) * 8
+		if p.Bits != nil {
+			bits = *p.Bits
+		}
+		w.writeField(b, bits, orders[i])
+
+		label := p.Label
+		if p.Name != "" {
+			label = p.Name
+		}
+		f := TestVectorField{ID: ids.add(label), Label: p.Label, Bits: bits, Hex: hex.EncodeToString(b)}
+		if p.Bits != nil && bits <= 64 {
+			n := uint64(0)
+			for _, c := range b {
+				n = n<<8 | uint64(c)
+			}
+			f.Value = &n
+		}
+		v.Fields = append(v.Fields, f)
+	}
+	v.Bits = w.bits
+	v.Hex = hex.EncodeToString(w.buf)
+	return v
+}
+
+// getMinTestVectorBits returns the fewest whole bytes, in bits, a
+// variable-length field may hold.
+func getMinTestVectorBits(p Placement) uint {
+	return (p.VariableLength.GetMinBits() + 7) / 8 * 8
+}
+
+// getMaxTestVectorBits returns the most whole bytes, in bits, a
+// variable-length field may hold. Both bounds are rounded up, so that a
+// field whose bounds are no whole number of bytes still has room for its
+// minimum.
+func getMaxTestVectorBits(p Placement) uint {
+	return (p.VariableLength.MaxBits + 7) / 8 * 8
+}
+
+// getShortTestVectorBits returns the fewest whole bytes, in bits, a
+// variable-length field may hold, but at least one so that it holds a value.
+func getShortTestVectorBits(p Placement) uint {
+	if bits := getMinTestVectorBits(p); bits > 0 {
+		return bits
+	}
+	return 8
+}
+
+// bigEndianBytes returns the last n bytes of v, most significant first.
+func bigEndianBytes(v uint64, n uint) []byte {
+	b := make([]byte, n)
+	for i := len(b) - 1; i >= 0 && v != 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+func bytesOf(c byte, n uint) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = c
+	}
+	return b
+}
+
+// maskBits clears the bits of the big-endian value above its low n bits.
+func maskBits(v []byte, n uint) []byte {
+	if extra := uint(len(v))*8 - n; extra > 0 && len(v) > 0 {
+		v[0] &= 0xff >> extra
+	}
+	return v
+}
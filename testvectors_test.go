@@ -0,0 +1,142 @@
+package packetdiagram
+
+import (
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestBitWriter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		writes [][2]uint // value, bits
+		hex    []byte
+		bits   uint
+	}{
+		{"nothing", nil, nil, 0},
+		{"a byte", [][2]uint{{0xab, 8}}, []byte{0xab}, 8},
+		{"nibbles", [][2]uint{{4, 4}, {5, 4}}, []byte{0x45}, 8},
+		{"across bytes", [][2]uint{{1, 1}, {0xff, 8}}, []byte{0xff, 0x80}, 9},
+		{"high bits dropped", [][2]uint{{0xff, 3}}, []byte{0xe0}, 3},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var w bitWriter
+			for _, write := range tt.writes {
+				w.write([]byte{byte(write[0])}, write[1])
+			}
+			assert.Equal(t, tt.hex, w.buf)
+			assert.Equal(t, tt.bits, w.bits)
+		})
+	}
+}
+
+func TestGenerateTestVectors(t *testing.T) {
+	t.Parallel()
+	def := &Definition{Name: "Header", Placements: []Placement{
+		{Label: "Version", Bits: uintp(4)},
+		{Label: "Flags", Bits: uintp(12)},
+		{Label: "Address", Bits: uintp(80)},
+		{Label: "Options", VariableLength: &VariableLengthPlacementSpec{MaxBits: 24}},
+	}}
+
+	vectors, err := GenerateTestVectors(def, 2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Header", vectors.Definition)
+	assert.Len(t, vectors.Vectors, 6)
+
+	tests := []struct {
+		name string
+		bits uint
+		hex  string
+	}{
+		{"zeros", 96, "000000000000000000000000"},
+		{"one", 104, "10010000000000000000000101"},
+		{"ones", 104, "ffffffffffffffffffffffffff"},
+		{"max", 120, "ffffffffffffffffffffffffffffff"},
+	}
+	for i, tt := range tests {
+		v := vectors.Vectors[i]
+		assert.Equal(t, tt.name, v.Name)
+		assert.Equal(t, tt.bits, v.Bits, tt.name)
+		assert.Equal(t, tt.hex, v.Hex, tt.name)
+	}
+
+	one := vectors.Vectors[1].Fields
+	assert.Equal(t, []string{"version", "flags", "address", "options"}, []string{one[0].ID, one[1].ID, one[2].ID, one[3].ID})
+	assert.Equal(t, uint64(1), *one[1].Value)
+	assert.Nil(t, one[2].Value, "fields wider than 64 bits have only hex")
+	assert.Equal(t, "00000000000000000001", one[2].Hex)
+	assert.Equal(t, "01", one[3].Hex)
+
+	ones := vectors.Vectors[2].Fields
+	assert.Equal(t, uint64(0xf), *ones[0].Value)
+	assert.Equal(t, uint64(0xfff), *ones[1].Value)
+	assert.Equal(t, "ff", ones[3].Hex)
+
+	again, err := GenerateTestVectors(def, 2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, vectors, again, "the same seed gives the same vectors")
+
+	other, err := GenerateTestVectors(def, 2, 2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, vectors.Vectors[4], other.Vectors[4], "another seed gives other vectors")
+}
+
+func TestGenerateTestVectorsOrders(t *testing.T) {
+	t.Parallel()
+	def := &Definition{BitOrder: bitOrderp(BitOrderLSBFirst), Placements: []Placement{
+		{Label: "a", Bits: uintp(3)},
+		{Label: "b", Bits: uintp(13)},
+		{Label: "c", Bits: uintp(16)},
+		{Label: "d", Bits: uintp(16), ByteOrder: byteOrderp(ByteOrderBigEndian)},
+	}}
+
+	vectors, err := GenerateTestVectors(def, 0, 1)
+	assert.NoError(t, err)
+	// b starts at bit 3 of the first byte and c and d are 0x0001
+	assert.Equal(t, "09000100"+"0001", vectors.Vectors[1].Hex)
+}
+
+func TestGenerateTestVectorsLengthField(t *testing.T) {
+	t.Parallel()
+	def := &Definition{Placements: []Placement{
+		{Label: "Length", Bits: uintp(8)},
+		{Label: "Value", VariableLength: &VariableLengthPlacementSpec{MaxBits: 64, MinBits: uintp(16), LengthField: "Length"}},
+	}}
+
+	vectors, err := GenerateTestVectors(def, 5, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "020000", vectors.Vectors[0].Hex, "zeros are as short as the value may be")
+	assert.Equal(t, "020001", vectors.Vectors[1].Hex)
+	assert.Equal(t, "02ffff", vectors.Vectors[2].Hex)
+	assert.Equal(t, "08ffffffffffffffff", vectors.Vectors[3].Hex)
+	for _, v := range vectors.Vectors {
+		n := *v.Fields[0].Value
+		assert.Equal(t, uint(8+n*8), v.Bits, v.Name)
+		assert.True(t, n >= 2 && n <= 8, v.Name)
+	}
+}
+
+func TestGenerateTestVectorsPartialBytes(t *testing.T) {
+	t.Parallel()
+	def := &Definition{Placements: []Placement{
+		{Label: "Value", VariableLength: &VariableLengthPlacementSpec{MaxBits: 12, MinBits: uintp(12)}},
+	}}
+	assert.NoError(t, def.validate())
+
+	vectors, err := GenerateTestVectors(def, 5, 1)
+	assert.NoError(t, err)
+	for _, v := range vectors.Vectors {
+		assert.Equal(t, uint(16), v.Bits, v.Name)
+	}
+}
+
+func TestGenerateTestVectorsErrors(t *testing.T) {
+	t.Parallel()
+	_, err := GenerateTestVectors(&Definition{Placements: []Placement{{Label: "a"}}}, 1, 1)
+	assert.EqualError(t, err, "placement a has no size")
+}
@@ -0,0 +1,232 @@
+package packetdiagram
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const noOwner = -1
+
+// DrawText renders the definition as plain text in the style of the
+// diagrams found in RFCs, two characters per bit.
+func DrawText(def *Definition, out io.Writer) error {
+	owners := getBitOwners(def)
+
+	w := bufio.NewWriter(out)
+	if caption := getOrderCaption(def); caption != "" {
+		writeTextLine(w, []rune(caption))
+		writeTextLine(w, nil)
+	}
+	if def.ShouldShowXAxisBits() {
+		for _, line := range getTextXAxisBitLines(def) {
+			writeTextLine(w, line)
+		}
+	}
+
+	for r := 0; r <= len(owners); r++ {
+		writeTextLine(w, getTextBorderLine(def, owners, r))
+		if r < len(owners) {
+			writeTextLine(w, getTextContentLine(def, owners, r))
+		}
+	}
+
+	return w.Flush()
+}
+
+func writeTextLine(w *bufio.Writer, line []rune) {
+	w.WriteString(strings.TrimRight(string(line), " "))
+	w.WriteString("\n")
+}
+
+// getBitOwners lays the placements out on rows the same way drawPlacements
+// does and returns, for every bit of every row, the index of the placement
+// occupying it.
+func getBitOwners(def *Definition) [][]int {
+	layout := def.getLayout()
+	owners := make([][]int, len(layout.rows))
+	for r := range owners {
+		owners[r] = make([]int, def.GetBitsPerLine())
+		for j := range owners[r] {
+			owners[r][j] = noOwner
+		}
+	}
+
+	for i, pl := range layout.placements {
+		for _, s := range pl.segments {
+			for j := s.x; j < s.x+s.bits; j++ {
+				owners[s.row][j] = i
+			}
+		}
+	}
+
+	return owners
+}
+
+func getOwner(owners [][]int, row, bit int) int {
+	if row < 0 || row >= len(owners) || bit < 0 || bit >= len(owners[row]) {
+		return noOwner
+	}
+	return owners[row][bit]
+}
+
+func getTextXAxisBitLines(def *Definition) [][]rune {
+	labels := getXAxisBitLabels(def)
+	if def.GetXAxisBitsFormat() == AxisLabelFormatHex {
+		// the digits read as hex on their own, stacked as they are
+		for i := range labels {
+			labels[i] = strings.TrimPrefix(labels[i], "0x")
+		}
+	}
+
+	width := 0
+	for _, l := range labels {
+		if n := utf8.RuneCountInString(l); n > width {
+			width = n
+		}
+	}
+
+	lines := make([][]rune, width)
+	for i := range lines {
+		lines[i] = []rune(strings.Repeat(" ", len(labels)*2+1))
+	}
+
+	for b, l := range labels {
+		padded := []rune(strings.Repeat(" ", width-utf8.RuneCountInString(l)) + l)
+		for i := range lines {
+			// Upper digits are only printed where the lower ones are all
+			// zeros, which gives the familiar "0 1 2 3" decade header.
+			if i < width-1 && strings.Trim(string(padded[i+1:]), "0") != "" {
+				continue
+			}
+			c := padded[i]
+			if c == ' ' {
+				c = '0'
+			}
+			lines[i][b*2+1] = c
+		}
+	}
+
+	return lines
+}
+
+func getTextBorderLine(def *Definition, owners [][]int, row int) []rune {
+	bitsPerLine := int(def.GetBitsPerLine())
+	line := []rune(strings.Repeat(" ", bitsPerLine*2+1))
+
+	for b := 0; b < bitsPerLine; b++ {
+		above := getOwner(owners, row-1, b)
+		below := getOwner(owners, row, b)
+		if above != below {
+			line[b*2+1] = '-'
+		}
+	}
+
+	for b := 0; b <= bitsPerLine; b++ {
+		dashed := (b > 0 && line[b*2-1] == '-') || (b < bitsPerLine && line[b*2+1] == '-')
+		if dashed || isTextBoundary(owners, row-1, b) || isTextBoundary(owners, row, b) {
+			line[b*2] = '+'
+		}
+	}
+
+	return line
+}
+
+func isTextBoundary(owners [][]int, row, bit int) bool {
+	if row < 0 || row >= len(owners) {
+		return false
+	}
+	return getOwner(owners, row, bit-1) != getOwner(owners, row, bit)
+}
+
+func getTextContentLine(def *Definition, owners [][]int, row int) []rune {
+	bitsPerLine := int(def.GetBitsPerLine())
+	line := []rune(strings.Repeat(" ", bitsPerLine*2+1))
+
+	for b := 0; b <= bitsPerLine; b++ {
+		if !isTextBoundary(owners, row, b) {
+			continue
+		}
+		// Variable-length placements are open on the sides of the diagram,
+		// as RFCs do.
+		edge := getOwner(owners, row, b)
+		if b == bitsPerLine {
+			edge = getOwner(owners, row, b-1)
+		}
+		if (b == 0 || b == bitsPerLine) && edge != noOwner && def.Placements[edge].VariableLength != nil {
+			line[b*2] = '/'
+		} else {
+			line[b*2] = '|'
+		}
+	}
+
+	for start := 0; start < bitsPerLine; {
+		owner := owners[row][start]
+		end := start
+		for end < bitsPerLine && owners[row][end] == owner {
+			end++
+		}
+		if owner == noOwner {
+			start = end
+			continue
+		}
+		label := def.Placements[owner].Label
+		if _, pattern := def.getPlacementFill(def.Placements[owner]); pattern != FillPatternNone {
+			c := getTextPatternRune(pattern)
+			for i := start*2 + 1; i < end*2; i++ {
+				line[i] = c
+			}
+			if label != "" {
+				label = " " + label + " "
+			}
+		}
+		if getTextLabelRow(owners, owner) == row {
+			putTextLabel(line, label, start*2+1, end*2)
+		}
+		start = end
+	}
+
+	return line
+}
+
+// getTextLabelRow picks the row a placement's label is printed on: the one
+// where the placement is widest, preferring the middle of the placement.
+func getTextLabelRow(owners [][]int, owner int) int {
+	rows := make([]int, 0)
+	widths := make([]int, 0)
+	for r := range owners {
+		w := 0
+		for _, o := range owners[r] {
+			if o == owner {
+				w++
+			}
+		}
+		if w > 0 {
+			rows = append(rows, r)
+			widths = append(widths, w)
+		}
+	}
+	if len(rows) == 0 {
+		return noOwner
+	}
+
+	best := len(rows) / 2
+	for i := range rows {
+		if widths[i] > widths[best] {
+			best = i
+		}
+	}
+	return rows[best]
+}
+
+func putTextLabel(line []rune, label string, from, to int) {
+	room := to - from
+	text := []rune(label)
+	if len(text) > room {
+		text = text[:room]
+	}
+
+	offset := from + (room-len(text))/2
+	copy(line[offset:], text)
+}
@@ -0,0 +1,82 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestDrawText(t *testing.T) {
+	testData := []struct {
+		Name       string
+		Definition *Definition
+		Expected   string
+	}{
+		{
+			Name: "fields spanning and sharing rows",
+			Definition: &Definition{
+				OctetsPerLine: uintp(1),
+				Placements: []Placement{
+					{Label: "A", Bits: uintp(4)},
+					{Label: "B", Bits: uintp(4)},
+					{Label: "Long", Bits: uintp(16)},
+				},
+			},
+			Expected: "" +
+				"+-+-+-+-+-+-+-+-+\n" +
+				"|   A   |   B   |\n" +
+				"+-+-+-+-+-+-+-+-+\n" +
+				"|               |\n" +
+				"+               +\n" +
+				"|     Long      |\n" +
+				"+-+-+-+-+-+-+-+-+\n",
+		},
+		{
+			Name: "x-axis bits",
+			Definition: &Definition{
+				OctetsPerLine: uintp(2),
+				XAxis: XAxisSpec{
+					Bits: &XAxisBitsSpec{},
+				},
+				Placements: []Placement{
+					{Label: "Port", Bits: uintp(16)},
+				},
+			},
+			Expected: "" +
+				" 0                   1\n" +
+				" 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5\n" +
+				"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n" +
+				"|             Port              |\n" +
+				"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n",
+		},
+		{
+			Name: "byte and bit order",
+			Definition: &Definition{
+				OctetsPerLine: uintp(2),
+				ByteOrder:     byteOrderp(ByteOrderLittleEndian),
+				Placements: []Placement{
+					{Label: "Port", Bits: uintp(16), ByteOrder: byteOrderp(ByteOrderBigEndian)},
+				},
+			},
+			Expected: "" +
+				"byte order: little-endian, bit order: msb-first (Port big-endian)\n" +
+				"\n" +
+				"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n" +
+				"|             Port              |\n" +
+				"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n",
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			err := DrawText(data.Definition, &buf)
+			assert.NoError(t, err)
+			assert.Equal(t, data.Expected, buf.String())
+		})
+	}
+}
@@ -8,23 +8,57 @@ const (
 	defaultTextFontFamily                 = "Helvetica"
 	defaultAxisTitleTextSize              = "8pt"
 	defaultAxisTitleTextSizeInPixels uint = 6
+	defaultBreakMarkStroke                = "black"
+	defaultBreakMarkGap              uint = 6
+	defaultPatternColor                   = "silver"
+	defaultPatternSpacing            uint = 6
 )
 
 type ThemeSpec struct {
-	Predefined *string         `yaml:"predefined,omitempty"`
-	Background *BackgroundSpec `yaml:"background,omitempty"`
-	Text       *TextSpec       `yaml:"text,omitempty"`
+	Predefined *string         `yaml:"predefined,omitempty" json:"predefined,omitempty" toml:"predefined,omitempty"`
+	Background *BackgroundSpec `yaml:"background,omitempty" json:"background,omitempty" toml:"background,omitempty"`
+	Text       *TextSpec       `yaml:"text,omitempty" json:"text,omitempty" toml:"text,omitempty"`
+	BreakMark  *BreakMarkTheme `yaml:"break-mark,omitempty" json:"break-mark,omitempty" toml:"break-mark,omitempty"`
+	Pattern    *PatternTheme   `yaml:"pattern,omitempty" json:"pattern,omitempty" toml:"pattern,omitempty"`
+	Kinds      *KindsTheme     `yaml:"kinds,omitempty" json:"kinds,omitempty" toml:"kinds,omitempty"`
 }
 
 type BackgroundSpec struct {
-	Color *string `yaml:"color,omitempty"`
+	Color *string `yaml:"color,omitempty" json:"color,omitempty" toml:"color,omitempty"`
+}
+
+// BreakMarkTheme styles break marks: the color they are drawn in and the
+// space between their pairs of lines, dashes or dots.
+type BreakMarkTheme struct {
+	Stroke *string `yaml:"stroke,omitempty" json:"stroke,omitempty" toml:"stroke,omitempty"`
+	Gap    *uint   `yaml:"gap,omitempty" json:"gap,omitempty" toml:"gap,omitempty"`
+}
+
+// PatternTheme styles fill patterns: the color they are drawn in and the
+// spacing of their lines and dots.
+type PatternTheme struct {
+	Color   *string `yaml:"color,omitempty" json:"color,omitempty" toml:"color,omitempty"`
+	Spacing *uint   `yaml:"spacing,omitempty" json:"spacing,omitempty" toml:"spacing,omitempty"`
+}
+
+// KindsTheme is the look of placements of each kind that have none of their
+// own.
+type KindsTheme struct {
+	Reserved *KindTheme `yaml:"reserved,omitempty" json:"reserved,omitempty" toml:"reserved,omitempty"`
+	Padding  *KindTheme `yaml:"padding,omitempty" json:"padding,omitempty" toml:"padding,omitempty"`
+	Data     *KindTheme `yaml:"data,omitempty" json:"data,omitempty" toml:"data,omitempty"`
+}
+
+type KindTheme struct {
+	Fill    *string      `yaml:"fill,omitempty" json:"fill,omitempty" toml:"fill,omitempty"`
+	Pattern *FillPattern `yaml:"pattern,omitempty" json:"pattern,omitempty" toml:"pattern,omitempty"`
 }
 
 type TextSpec struct {
-	Color         *string `yaml:"color,omitempty"`
-	Size          *string `yaml:"size,omitempty"`
-	FontFamily    *string `yaml:"font-family,omitempty"`
-	AxisTitleSize *string `yaml:"axis-title-size,omitempty"`
+	Color         *string `yaml:"color,omitempty" json:"color,omitempty" toml:"color,omitempty"`
+	Size          *string `yaml:"size,omitempty" json:"size,omitempty" toml:"size,omitempty"`
+	FontFamily    *string `yaml:"font-family,omitempty" json:"font-family,omitempty" toml:"font-family,omitempty"`
+	AxisTitleSize *string `yaml:"axis-title-size,omitempty" json:"axis-title-size,omitempty" toml:"axis-title-size,omitempty"`
 }
 
 func (t ThemeSpec) GetBackgroundColor() string {
@@ -62,6 +96,58 @@ func (t ThemeSpec) GetAxisTitleTextSize() string {
 	return *t.Text.AxisTitleSize
 }
 
+func (t ThemeSpec) GetBreakMarkStroke() string {
+	if t.BreakMark == nil || t.BreakMark.Stroke == nil {
+		return defaultBreakMarkStroke
+	}
+	return *t.BreakMark.Stroke
+}
+
+func (t ThemeSpec) GetBreakMarkGap() uint {
+	if t.BreakMark == nil || t.BreakMark.Gap == nil {
+		return defaultBreakMarkGap
+	}
+	return *t.BreakMark.Gap
+}
+
+func (t ThemeSpec) GetPatternColor() string {
+	if t.Pattern == nil || t.Pattern.Color == nil {
+		return defaultPatternColor
+	}
+	return *t.Pattern.Color
+}
+
+func (t ThemeSpec) GetPatternSpacing() uint {
+	if t.Pattern == nil || t.Pattern.Spacing == nil || *t.Pattern.Spacing == 0 {
+		return defaultPatternSpacing
+	}
+	return *t.Pattern.Spacing
+}
+
+// GetKindTheme returns the look of placements of kind, falling back to the
+// default one for anything the theme leaves out.
+func (t ThemeSpec) GetKindTheme(kind PlacementKind) KindTheme {
+	look := defaultKindThemes[kind]
+	var k *KindTheme
+	if t.Kinds != nil {
+		switch kind {
+		case PlacementKindReserved:
+			k = t.Kinds.Reserved
+		case PlacementKindPadding:
+			k = t.Kinds.Padding
+		case PlacementKindData:
+			k = t.Kinds.Data
+		}
+	}
+	if k != nil && k.Fill != nil {
+		look.Fill = k.Fill
+	}
+	if k != nil && k.Pattern != nil {
+		look.Pattern = k.Pattern
+	}
+	return look
+}
+
 var defaultTheme = &ThemeSpec{
 	Background: &BackgroundSpec{
 		Color: stringp(defaultBackgroundColor),
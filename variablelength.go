@@ -0,0 +1,69 @@
+package packetdiagram
+
+import (
+	"github.com/pkg/errors"
+)
+
+const defaultRowsBeforeBreak = 1
+
+// GetMinBits returns the fewest bits a variable-length placement takes up,
+// none unless min-bits says otherwise.
+func (s VariableLengthPlacementSpec) GetMinBits() uint {
+	if s.MinBits == nil {
+		return 0
+	}
+	return *s.MinBits
+}
+
+// GetRowsBeforeBreak returns how many rows a variable-length placement
+// starting x bits into a row is drawn with before the rows left out. They
+// default to the rows its first min-bits take up, or a single one.
+func (s VariableLengthPlacementSpec) GetRowsBeforeBreak(bitsPerLine, x uint) uint {
+	if s.RowsBeforeBreak != nil {
+		return *s.RowsBeforeBreak
+	}
+	if s.GetMinBits() == 0 {
+		return defaultRowsBeforeBreak
+	}
+	return (x + s.GetMinBits() + bitsPerLine - 1) / bitsPerLine
+}
+
+// getLengthField returns the index of the placement giving the length of
+// the variable-length placement p, or -1 when it has none.
+func (d *Definition) getLengthField(p Placement) int {
+	if p.VariableLength == nil || p.VariableLength.LengthField == "" {
+		return -1
+	}
+	for i, q := range d.Placements {
+		if q.GetKey() == p.VariableLength.LengthField {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d *Definition) validateVariableLengths() error {
+	for _, p := range d.Placements {
+		s := p.VariableLength
+		if s == nil {
+			continue
+		}
+		if s.GetMinBits() > s.MaxBits {
+			return errors.Errorf("placement %s: min-bits %d is more than max-bits %d", p.GetKey(), s.GetMinBits(), s.MaxBits)
+		}
+		if s.RowsBeforeBreak != nil && *s.RowsBeforeBreak == 0 {
+			return errors.Errorf("placement %s: at least one row must be drawn before the break", p.GetKey())
+		}
+		if s.LengthField == "" {
+			continue
+		}
+		i := d.getLengthField(p)
+		if i < 0 {
+			return errors.Errorf("placement %s: length field %s not found", p.GetKey(), s.LengthField)
+		}
+		if d.Placements[i].Bits == nil {
+			return errors.Errorf("placement %s: length field %s must be a field with `bits`", p.GetKey(), s.LengthField)
+		}
+	}
+	return nil
+}
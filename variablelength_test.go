@@ -0,0 +1,92 @@
+package packetdiagram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestGetRowsBeforeBreak(t *testing.T) {
+	testData := []struct {
+		Name     string
+		Spec     VariableLengthPlacementSpec
+		X        uint
+		Expected uint
+	}{
+		{"default", VariableLengthPlacementSpec{MaxBits: 320}, 0, 1},
+		{"given", VariableLengthPlacementSpec{MaxBits: 320, RowsBeforeBreak: uintp(3)}, 0, 3},
+		{"fewest bits", VariableLengthPlacementSpec{MaxBits: 320, MinBits: uintp(64)}, 0, 2},
+		{"fewest bits from within a row", VariableLengthPlacementSpec{MaxBits: 320, MinBits: uintp(64)}, 8, 3},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, data.Expected, data.Spec.GetRowsBeforeBreak(32, data.X))
+		})
+	}
+}
+
+func TestValidateVariableLengths(t *testing.T) {
+	testData := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{
+			Name:   "fewest bits over the most",
+			Source: "placements:\n  - label: a\n    variable-length:\n      max-bits: 8\n      min-bits: 16\n",
+			Error:  "placement a: min-bits 16 is more than max-bits 8",
+		},
+		{
+			Name:   "no rows before the break",
+			Source: "placements:\n  - label: a\n    variable-length:\n      max-bits: 8\n      rows-before-break: 0\n",
+			Error:  "placement a: at least one row must be drawn before the break",
+		},
+		{
+			Name:   "unknown length field",
+			Source: "placements:\n  - label: a\n    variable-length:\n      max-bits: 8\n      length-field: len\n",
+			Error:  "placement a: length field len not found",
+		},
+		{
+			Name:   "variable-length length field",
+			Source: "placements:\n  - label: len\n    variable-length:\n      max-bits: 8\n  - label: a\n    variable-length:\n      max-bits: 8\n      length-field: len\n",
+			Error:  "placement a: length field len must be a field with `bits`",
+		},
+	}
+
+	for _, data := range testData {
+		data := data // capture
+		t.Run(data.Name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadDefinition(strings.NewReader(data.Source))
+			assert.EqualError(t, err, data.Error)
+		})
+	}
+}
+
+func TestDrawVariableLengthSplit(t *testing.T) {
+	t.Parallel()
+	def := &Definition{Placements: []Placement{
+		{Label: "Kind", Bits: uintp(24)},
+		{Label: "Short", VariableLength: &VariableLengthPlacementSpec{MaxBits: 16}},
+		{Label: "After", Bits: uintp(24)},
+	}}
+
+	var svg strings.Builder
+	err := Draw(def, &svg)
+	assert.NoError(t, err)
+
+	var buf strings.Builder
+	err = DrawText(def, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"|                     Kind                      |               /\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"/     Short     |                     After                     |\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n", buf.String())
+}
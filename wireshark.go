@@ -0,0 +1,326 @@
+package packetdiagram
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// luaKeywords are the reserved words of Lua, which fields cannot be called.
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true,
+	"or": true, "repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+// wiresharkField is how a dissector reads a placement: as an unsigned integer
+// of the bytes holding it, masked when it does not fill them, or as bytes.
+type wiresharkField struct {
+	id     string
+	label  string
+	typ    string
+	mask   string
+	values string
+	le     bool // whether the bytes holding the field are little-endian
+
+	offset uint // in bytes, from the end of the last variable-length field
+	bit    uint // where the field starts within its first byte
+	bits   uint
+	length uint // in bytes, 0 for variable-length fields
+	vary   bool
+	last   bool
+	min    uint   // in bytes, for variable-length fields
+	max    uint   // in bytes, for variable-length fields
+	from   string // the field giving the length of a variable-length field
+	after  uint   // bytes of fixed size that follow a variable-length field
+	gives  bool   // whether the field gives the length of another one
+}
+
+// ExportWiresharkLua writes a Wireshark dissector in Lua for what def
+// describes: a ProtoField per placement, masked when the field does not fill
+// the bytes holding it, with the values of a field as its value string.
+// Fields are added little-endian where the byte and bit order of the
+// definition make them so. The dissector is registered for "Decode As" on UDP
+// and TCP ports and comes with a heuristic stub to fill in. Variable-length
+// fields with a length field are as long as it says, and the dissector gives
+// up on packets where that is out of their bounds. Other ones that do not
+// run to the end of the packet get a length function to fill in.
+func ExportWiresharkLua(def *Definition, w io.Writer) error {
+	abbrev := "packet"
+	title := "Packet"
+	if def.Name != "" {
+		abbrev = snakeCaseIdentifier(def.Name)
+		title = def.Name
+	}
+
+	fields, err := wiresharkFields(def)
+	if err != nil {
+		return err
+	}
+
+	fixedBits := uint(0)
+	for _, p := range def.Placements {
+		if p.Bits != nil {
+			fixedBits += *p.Bits
+		}
+	}
+	minLength := (fixedBits + 7) / 8
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Wireshark dissector for %s, generated by packet-diagram.\n\n", title)
+	fmt.Fprintf(&b, "local proto = Proto(%s, %s)\n", luaString(abbrev), luaString(title))
+
+	for _, f := range fields {
+		if f.values == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\nlocal %s_values = {\n", f.id)
+		b.WriteString(f.values)
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\nlocal f = proto.fields\n")
+	for _, f := range fields {
+		args := []string{luaString(abbrev + "." + f.id), luaString(f.label)}
+		if f.typ != "bytes" {
+			values := "nil"
+			if f.values != "" {
+				values = f.id + "_values"
+			}
+			args = append(args, "base.DEC", values)
+			if f.mask != "" {
+				args = append(args, f.mask)
+			}
+		}
+		fmt.Fprintf(&b, "f.%s = ProtoField.%s(%s)\n", f.id, f.typ, strings.Join(args, ", "))
+	}
+
+	for _, f := range fields {
+		if !f.vary || f.last || f.from != "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n-- The length of %s in bytes. It is taken to be what the fields after it\n", f.label)
+		b.WriteString("-- leave of the packet; change this to read it from the packet instead.\n")
+		fmt.Fprintf(&b, "local function %s_length(buffer, offset)\n", f.id)
+		fmt.Fprintf(&b, "    return math.max(buffer:len() - offset - %d, 0)\n", f.after)
+		b.WriteString("end\n")
+	}
+
+	b.WriteString("\nfunction proto.dissector(buffer, pinfo, tree)\n")
+	fmt.Fprintf(&b, "    if buffer:len() < %d then\n", minLength)
+	b.WriteString("        return 0\n")
+	b.WriteString("    end\n")
+	b.WriteString("    pinfo.cols.protocol = proto.name\n")
+	b.WriteString("    local subtree = tree:add(proto, buffer())\n")
+
+	dynamic := false
+	for _, f := range fields {
+		at := fmt.Sprint(f.offset)
+		if dynamic {
+			at = "offset"
+			if f.offset > 0 {
+				at = fmt.Sprintf("offset + %d", f.offset)
+			}
+		}
+		switch {
+		case f.vary && f.from != "":
+			fmt.Fprintf(&b, "    local %s_len = %s\n", f.id, f.from)
+			bounds := []string{fmt.Sprintf("%s_len > %d", f.id, f.max)}
+			if f.min > 0 {
+				bounds = append(bounds, fmt.Sprintf("%s_len < %d", f.id, f.min))
+			}
+			end := fmt.Sprintf("%s + %s_len", at, f.id)
+			if f.after > 0 {
+				end = fmt.Sprintf("%s + %d", end, f.after)
+			}
+			bounds = append(bounds, fmt.Sprintf("buffer:len() < %s", end))
+			fmt.Fprintf(&b, "    if %s then\n", strings.Join(bounds, " or "))
+			b.WriteString("        return 0\n")
+			b.WriteString("    end\n")
+			fmt.Fprintf(&b, "    subtree:add(f.%s, buffer(%s, %s_len))\n", f.id, at, f.id)
+			if f.last {
+				break
+			}
+			if dynamic {
+				fmt.Fprintf(&b, "    offset = %s + %s_len\n", at, f.id)
+			} else {
+				fmt.Fprintf(&b, "    local offset = %s + %s_len\n", at, f.id)
+			}
+			dynamic = true
+		case f.vary && f.last:
+			fmt.Fprintf(&b, "    if buffer:len() > %s then\n", at)
+			fmt.Fprintf(&b, "        subtree:add(f.%s, buffer(%s))\n", f.id, at)
+			b.WriteString("    end\n")
+		case f.vary:
+			fmt.Fprintf(&b, "    local %s_len = %s_length(buffer, %s)\n", f.id, f.id, at)
+			fmt.Fprintf(&b, "    subtree:add(f.%s, buffer(%s, %s_len))\n", f.id, at, f.id)
+			if dynamic {
+				fmt.Fprintf(&b, "    offset = %s + %s_len\n", at, f.id)
+			} else {
+				fmt.Fprintf(&b, "    local offset = %s + %s_len\n", at, f.id)
+			}
+			dynamic = true
+		case f.le:
+			fmt.Fprintf(&b, "    subtree:add_le(f.%s, buffer(%s, %d))\n", f.id, at, f.length)
+		default:
+			fmt.Fprintf(&b, "    subtree:add(f.%s, buffer(%s, %d))\n", f.id, at, f.length)
+		}
+		if f.gives {
+			fmt.Fprintf(&b, "    local %s_value = %s\n", f.id, wiresharkValue(f, at))
+		}
+	}
+	b.WriteString("    return buffer:len()\n")
+	b.WriteString("end\n")
+
+	b.WriteString("\n-- Pick the protocol in \"Decode As...\" for the ports it runs on, or list\n")
+	b.WriteString("-- them here, e.g. DissectorTable.get(\"udp.port\"):add(9000, proto).\n")
+	b.WriteString("DissectorTable.get(\"udp.port\"):add_for_decode_as(proto)\n")
+	b.WriteString("DissectorTable.get(\"tcp.port\"):add_for_decode_as(proto)\n")
+
+	b.WriteString("\n-- To find the protocol on any port, make this check what sets its packets\n")
+	b.WriteString("-- apart and uncomment the registration.\n")
+	b.WriteString("local function heuristic(buffer, pinfo, tree)\n")
+	fmt.Fprintf(&b, "    if buffer:len() < %d then\n", minLength)
+	b.WriteString("        return false\n")
+	b.WriteString("    end\n")
+	b.WriteString("    proto.dissector(buffer, pinfo, tree)\n")
+	b.WriteString("    return true\n")
+	b.WriteString("end\n")
+	b.WriteString("-- proto:register_heuristic(\"udp\", heuristic)\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func wiresharkFields(def *Definition) ([]wiresharkField, error) {
+	ids := identifierSet{}
+	orders := def.getFieldOrders()
+	fields := make([]wiresharkField, 0, len(def.Placements))
+
+	bit := uint(0) // from the end of the last variable-length field
+	lastVary := -1 // the field after which offsets are counted
+	for i, p := range def.Placements {
+		label := p.Label
+		if p.Name != "" {
+			label = p.Name
+		}
+		id := ids.add(label)
+		if luaKeywords[id] {
+			id = ids.add(id + " field")
+		}
+		f := wiresharkField{id: id, label: p.Label}
+
+		switch {
+		case p.VariableLength != nil:
+			if bit%8 != 0 {
+				return nil, errors.Errorf("placement %s: variable-length fields must start on a byte boundary", p.GetKey())
+			}
+			f.typ, f.offset, f.vary = "bytes", bit/8, true
+			f.last = i == len(def.Placements)-1
+			f.min = (p.VariableLength.GetMinBits() + 7) / 8
+			f.max = (p.VariableLength.MaxBits + 7) / 8
+			if j := def.getLengthField(p); j >= 0 {
+				if j > i {
+					return nil, errors.Errorf("placement %s: Wireshark reads the length field %s after it", p.GetKey(), p.VariableLength.LengthField)
+				}
+				g := &fields[j]
+				if g.typ == "bytes" || g.le && g.mask != "" || g.mask != "" && g.bits > 32 {
+					return nil, errors.Errorf("placement %s: length field %s must be whole bytes of at most 64 bits, or at most 32 bits msb-first", p.GetKey(), p.VariableLength.LengthField)
+				}
+				g.gives = true
+				f.from = g.id + "_value"
+			}
+			if lastVary >= 0 {
+				fields[lastVary].after = bit / 8
+			}
+			lastVary = len(fields)
+			bit = 0
+		case p.Bits != nil:
+			n := *p.Bits
+			start, in := bit/8, bit%8
+			size := (in + n + 7) / 8
+			f.offset, f.bit, f.bits, f.length = start, in, n, size
+			switch {
+			case size <= 4:
+				f.typ = fmt.Sprintf("uint%d", size*8)
+			case size <= 8:
+				f.typ = "uint64"
+			default:
+				f.typ = "bytes"
+			}
+			if f.typ != "bytes" {
+				f.le = orders[i].littleEndian
+			}
+			if f.typ != "bytes" && (in != 0 || n%8 != 0) {
+				// the field within the bytes read as an integer, which has its
+				// first bit at the bottom when they are little-endian
+				shift := size*8 - in - n
+				if f.le {
+					shift = in
+				}
+				mask := (uint64(1)<<n - 1) << shift
+				if size <= 4 {
+					f.mask = fmt.Sprintf("0x%0*X", size*2, mask)
+				} else {
+					f.mask = fmt.Sprintf("UInt64.fromhex(%s)", luaString(fmt.Sprintf("%0*X", size*2, mask)))
+				}
+			}
+			if len(p.Values) > 0 && f.typ != "bytes" {
+				var values strings.Builder
+				for _, v := range p.Values {
+					fmt.Fprintf(&values, "    [%d] = %s,\n", v.Value, luaString(v.Label))
+				}
+				f.values = values.String()
+			}
+			bit += n
+		default:
+			return nil, errors.Errorf("placement %s has no size", p.GetKey())
+		}
+		fields = append(fields, f)
+	}
+	if lastVary >= 0 {
+		fields[lastVary].after = (bit + 7) / 8
+	}
+
+	return fields, nil
+}
+
+// wiresharkValue is the Lua expression reading the value of the field at
+// at, for fields giving the length of another one.
+func wiresharkValue(f wiresharkField, at string) string {
+	r := fmt.Sprintf("buffer(%s, %d)", at, f.length)
+	switch {
+	case f.mask != "":
+		return fmt.Sprintf("%s:bitfield(%d, %d)", r, f.bit, f.bits)
+	case f.typ == "uint64" && f.le:
+		return r + ":le_uint64():tonumber()"
+	case f.typ == "uint64":
+		return r + ":uint64():tonumber()"
+	case f.le:
+		return r + ":le_uint()"
+	}
+	return r + ":uint()"
+}
+
+// luaString quotes s as a Lua string literal.
+func luaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range []byte(s) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
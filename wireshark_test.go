@@ -0,0 +1,60 @@
+package packetdiagram
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestExportWiresharkLua(t *testing.T) {
+	testGolden(t, goldenDefinitions, "testdata/wireshark", ".lua", ExportWiresharkLua)
+}
+
+func TestExportWiresharkLuaErrors(t *testing.T) {
+	length := func(field string) *VariableLengthPlacementSpec {
+		return &VariableLengthPlacementSpec{MaxBits: 32, LengthField: field}
+	}
+	lsb := BitOrderLSBFirst
+	testData := []struct {
+		Name       string
+		Placements []Placement
+		Error      string
+	}{
+		{
+			Name:       "unaligned variable-length",
+			Placements: []Placement{{Label: "a", Bits: uintp(3)}, {Label: "b", VariableLength: &VariableLengthPlacementSpec{MaxBits: 32}}},
+			Error:      "placement b: variable-length fields must start on a byte boundary",
+		},
+		{
+			Name:       "length field after",
+			Placements: []Placement{{Label: "b", VariableLength: length("a")}, {Label: "a", Bits: uintp(8)}},
+			Error:      "placement b: Wireshark reads the length field a after it",
+		},
+		{
+			Name:       "wide length field",
+			Placements: []Placement{{Label: "a", Bits: uintp(72)}, {Label: "b", VariableLength: length("a")}},
+			Error:      "placement b: length field a must be whole bytes of at most 64 bits, or at most 32 bits msb-first",
+		},
+		{
+			Name:       "lsb-first length field",
+			Placements: []Placement{{Label: "a", Bits: uintp(12), BitOrder: &lsb}, {Label: "c", Bits: uintp(4)}, {Label: "b", VariableLength: length("a")}},
+			Error:      "placement b: length field a must be whole bytes of at most 64 bits, or at most 32 bits msb-first",
+		},
+	}
+
+	for _, tt := range testData {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			err := ExportWiresharkLua(&Definition{Placements: tt.Placements}, &buf)
+			assert.EqualError(t, err, tt.Error)
+		})
+	}
+}
+
+func TestLuaString(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, `"Check \"sum\" \\ \008"`, luaString("Check \"sum\" \\ \b"))
+}